@@ -3,56 +3,124 @@ package main
 import (
 	"context"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/gostructure/app/internal/app"
 	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/server"
+)
+
+// Set at link time by scripts/build.sh
+var (
+	Version   = "dev"
+	BuildTime = ""
+)
+
+// Process exit codes
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitConfigError = 2
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		log.Printf("Failed to load configuration: %v", err)
+		return exitConfigError
 	}
+	cfg.App.BuildVersion = Version
+	cfg.App.BuildTime = BuildTime
 
 	// Initialize application
-	application := app.New(cfg)
+	application, err := app.Open(cfg)
+	if err != nil {
+		log.Printf("Failed to open application stores: %v", err)
+		return exitError
+	}
+	lifecycle := application.Lifecycle()
 
-	// Create HTTP server
-	server := &http.Server{
-		Addr:         cfg.Server.Address,
-		Handler:      application.Router(),
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  cfg.Server.IdleTimeout,
+	// Create HTTP server on all configured listeners
+	srv, err := server.New(cfg, application.Router())
+	if err != nil {
+		log.Printf("Invalid server configuration: %v", err)
+		return exitConfigError
 	}
+	serverErrs := []<-chan error{srv.Err()}
 
-	// Start server in goroutine
-	go func() {
-		log.Printf("Starting server on %s", cfg.Server.Address)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
+	// The admin listener starts first and stops last so health and
+	// metrics stay observable while the public listener drains
+	if cfg.Server.AdminAddress != "" {
+		admin := server.NewAdmin(cfg, application.AdminRouter())
+		lifecycle.Append(app.Hook{
+			Name:    "admin server",
+			Order:   app.OrderAdminServer,
+			OnStart: func(context.Context) error { return admin.Start() },
+			OnStop:  admin.Shutdown,
+		})
+		serverErrs = append(serverErrs, admin.Err())
+	}
 
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
+	lifecycle.Append(app.Hook{
+		Name:    "http server",
+		Order:   app.OrderPublicServer,
+		OnStart: func(context.Context) error { return srv.Start() },
+		OnStop:  srv.Shutdown,
+	})
+
+	quit := make(chan os.Signal, 2)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+
+	if err := lifecycle.Start(context.Background()); err != nil {
+		log.Printf("Failed to start: %v", err)
+		return exitError
+	}
+
+	code := exitOK
+	select {
+	case <-quit:
+	case err := <-merge(serverErrs):
+		log.Printf("Server error: %v", err)
+		code = exitError
+	}
 
 	log.Println("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// A second signal skips the remaining drain
+	go func() {
+		<-quit
+		log.Println("Forced exit")
+		os.Exit(exitError)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	if err := lifecycle.Stop(ctx); err != nil {
+		log.Printf("Shutdown incomplete: %v", err)
+		return exitError
 	}
 
 	log.Println("Server exited properly")
+	return code
+}
+
+// merge fans in the first error from any server
+func merge(chans []<-chan error) <-chan error {
+	out := make(chan error, len(chans))
+	for _, c := range chans {
+		go func(c <-chan error) {
+			if err, ok := <-c; ok {
+				out <- err
+			}
+		}(c)
+	}
+	return out
 }
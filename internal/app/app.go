@@ -1,40 +1,358 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"net/http/pprof"
 
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/blob"
 	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/events"
 	"github.com/gostructure/app/internal/handler"
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/metrics"
 	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/notify"
+	"github.com/gostructure/app/internal/outbox"
+	"github.com/gostructure/app/internal/scheduler"
+	"github.com/gostructure/app/internal/search"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/internal/webhook"
 )
 
 // App represents the application
 type App struct {
-	config  *config.Config
-	router  *http.ServeMux
-	handler *handler.Handler
+	config    *config.Config
+	router    *http.ServeMux
+	admin     *http.ServeMux
+	handler   *handler.Handler
+	metrics   *metrics.Registry
+	inFlight  *middleware.InFlightTracker
+	lifecycle *Lifecycle
+	store     *store.Store
+	auditLog  *audit.Log
+	events    *events.Bus
+	webhooks  *webhook.Dispatcher
+	jobs      *jobs.Manager
+	scheduler *scheduler.Scheduler
+	search    *search.Index
+	lease     scheduler.Lease
+	blobs     blob.Store
+	sinks     []outbox.Sink
 }
 
-// New creates a new application instance
-func New(cfg *config.Config) *App {
+// Option customizes the application's dependencies
+type Option func(*App)
+
+// WithStore replaces the default in-memory store
+func WithStore(s *store.Store) Option {
+	return func(a *App) {
+		a.store = s
+	}
+}
+
+// WithOutboxSink adds a sink the outbox relay publishes events to, besides
+// the change feed and webhooks. Sinks implementing io.Closer are closed on
+// shutdown.
+func WithOutboxSink(s outbox.Sink) Option {
+	return func(a *App) {
+		a.sinks = append(a.sinks, s)
+	}
+}
+
+// WithSchedulerLease sets the lease that elects which replica runs each
+// scheduled task
+func WithSchedulerLease(l scheduler.Lease) Option {
+	return func(a *App) {
+		a.lease = l
+	}
+}
+
+// WithBlobStore replaces the default in-memory store of attachment contents
+func WithBlobStore(s blob.Store) Option {
+	return func(a *App) {
+		a.blobs = s
+	}
+}
+
+// WithAuditLog replaces the default in-memory audit log
+func WithAuditLog(l *audit.Log) Option {
+	return func(a *App) {
+		a.auditLog = l
+	}
+}
+
+// WithEventBus replaces the default change feed bus
+func WithEventBus(b *events.Bus) Option {
+	return func(a *App) {
+		a.events = b
+	}
+}
+
+// New creates a new application instance. Dependencies not supplied as
+// options are kept in memory.
+func New(cfg *config.Config, opts ...Option) *App {
 	app := &App{
-		config: cfg,
-		router: http.NewServeMux(),
+		config:   cfg,
+		router:   http.NewServeMux(),
+		admin:    http.NewServeMux(),
+		metrics:  metrics.NewRegistry(),
+		inFlight: middleware.NewInFlightTracker(),
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
+	if app.store == nil {
+		app.store = store.New()
+	}
+	if app.auditLog == nil {
+		app.auditLog = audit.NewLog()
+	}
+	if app.events == nil {
+		app.events = events.NewBus(cfg.Events.BufferSize, cfg.Events.SubscriberBuffer)
+	}
+	if app.blobs == nil {
+		app.blobs = blob.NewMemoryStore()
+	}
+	app.lifecycle = NewLifecycle(cfg.Shutdown, app.inFlight)
+	app.lifecycle.Append(Hook{
+		Name:   "store",
+		Order:  OrderStorage,
+		OnStop: func(context.Context) error { return app.store.Close() },
+	})
+	app.lifecycle.Append(Hook{
+		Name:   "audit log",
+		Order:  OrderStorage,
+		OnStop: func(context.Context) error { return app.auditLog.Close() },
+	})
+	// Audit entries are committed with their changes and moved to the log
+	// at startup, periodically and once the workers have stopped
+	app.lifecycle.Append(Hook{
+		Name:    "audit flush",
+		Order:   OrderStorage,
+		OnStart: func(context.Context) error { return app.auditLog.Flush(app.store) },
+		OnStop:  func(context.Context) error { return app.auditLog.Flush(app.store) },
+	})
+	for _, sink := range app.sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			app.lifecycle.Append(Hook{
+				Name:   "outbox sink " + sink.Name(),
+				Order:  OrderStorage,
+				OnStop: func(context.Context) error { return closer.Close() },
+			})
+		}
+	}
+
+	// The index is rebuilt before the relay starts applying new changes
+	app.search = search.NewIndex()
+	app.lifecycle.Append(Hook{
+		Name:  "search index",
+		Order: OrderStorage,
+		OnStart: func(context.Context) error {
+			app.search.Rebuild(app.store)
+			return nil
+		},
+	})
+
+	app.webhooks = webhook.NewDispatcher(cfg.Webhooks, app.store, nil)
+	app.lifecycle.Append(backgroundHook("webhook dispatcher", OrderWorkers, app.webhooks.Run))
+
+	// The relay stops before the dispatcher so its final pass is queued
+	sinks := append([]outbox.Sink{
+		outbox.BusSink{Bus: app.events},
+		outbox.WebhookSink{Dispatcher: app.webhooks},
+		outbox.SearchSink{Index: app.search},
+	}, app.sinks...)
+	relay := outbox.NewRelay(cfg.Outbox, app.store, sinks...)
+	app.lifecycle.Append(backgroundHook("outbox relay", OrderWorkers, relay.Run))
+
+	app.jobs = jobs.NewManager(cfg.Jobs, app.store)
+	app.lifecycle.Append(backgroundHook("job workers", OrderWorkers, app.jobs.Run))
+
+	app.scheduler = scheduler.New(cfg.Scheduler, app.lease)
+	app.scheduleMaintenance()
+	app.lifecycle.Append(backgroundHook("scheduler", OrderWorkers, app.scheduler.Run))
+	app.lifecycle.Append(Hook{
+		Name:  "event streams",
+		Order: OrderStreams,
+		OnStop: func(context.Context) error {
+			app.events.Close()
+			return nil
+		},
+	})
+
+	notifiers, err := notify.New(cfg.Alerts)
+	if err != nil {
+		log.Printf("Skipping alert notification channels: %v", err)
 	}
 
-	app.handler = handler.New(cfg)
+	app.handler = handler.New(cfg, handler.Services{
+		Store:     app.store,
+		Audit:     app.auditLog,
+		Events:    app.events,
+		Webhooks:  app.webhooks,
+		Jobs:      app.jobs,
+		Scheduler: app.scheduler,
+		Search:    app.search,
+		Blobs:     app.blobs,
+		Notifiers: notifiers,
+	})
+	app.handler.RegisterJobs(app.jobs)
+	app.handler.AddReadinessCheck("lifecycle", app.lifecycle.ReadinessCheck)
 	app.setupRoutes()
+	app.setupAdminRoutes()
 
 	return app
 }
 
+// Open creates an application with the persistent stores named in cfg
+func Open(cfg *config.Config) (*App, error) {
+	var opts []Option
+	var closers []io.Closer
+	fail := func(err error) (*App, error) {
+		for _, c := range closers {
+			err = errors.Join(err, c.Close())
+		}
+		return nil, err
+	}
+
+	if cfg.Store.File != "" {
+		s, err := store.OpenFile(cfg.Store.File)
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, s)
+		opts = append(opts, WithStore(s))
+	}
+
+	if cfg.Audit.File != "" {
+		auditLog, err := audit.OpenFile(cfg.Audit.File)
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, auditLog)
+		opts = append(opts, WithAuditLog(auditLog))
+	}
+
+	if cfg.Outbox.FileSink != "" {
+		sink, err := outbox.OpenFileSink(cfg.Outbox.FileSink)
+		if err != nil {
+			return fail(err)
+		}
+		closers = append(closers, sink)
+		opts = append(opts, WithOutboxSink(sink))
+	}
+
+	if cfg.Attachments.Dir != "" {
+		blobs, err := blob.NewFSStore(cfg.Attachments.Dir)
+		if err != nil {
+			return fail(err)
+		}
+		opts = append(opts, WithBlobStore(blobs))
+	}
+
+	if cfg.Scheduler.LeaseDir != "" {
+		lease, err := scheduler.NewFileLease(cfg.Scheduler.LeaseDir)
+		if err != nil {
+			return fail(err)
+		}
+		opts = append(opts, WithSchedulerLease(lease))
+	}
+
+	return New(cfg, opts...), nil
+}
+
+// scheduleMaintenance registers the recurring maintenance tasks. Their
+// schedules can be overridden by name in the scheduler config.
+func (a *App) scheduleMaintenance() {
+	tasks := []struct {
+		name string
+		spec string
+		task scheduler.Task
+	}{
+		{"store.compact", "@daily", func(context.Context) error {
+			return a.store.Compact()
+		}},
+		{"trash.purge", "@daily", func(context.Context) error {
+			n, err := a.handler.PurgeDeleted(a.config.Store.TrashRetention)
+			if n > 0 {
+				log.Printf("Purged %d deleted records", n)
+			}
+			return err
+		}},
+		{"audit.flush", "@every 1m", func(context.Context) error {
+			return a.auditLog.Flush(a.store)
+		}},
+		{"jobs.purge", "@hourly", func(context.Context) error {
+			n, err := a.jobs.Purge()
+			if n > 0 {
+				log.Printf("Purged %d finished jobs", n)
+			}
+			return err
+		}},
+		{"reservations.expire", "@every 1m", func(context.Context) error {
+			n, err := a.handler.ExpireReservations()
+			if n > 0 {
+				log.Printf("Expired %d reservations", n)
+			}
+			return err
+		}},
+		{"prices.apply", "@every 1m", func(context.Context) error {
+			n, err := a.handler.ApplyScheduledPrices()
+			if n > 0 {
+				log.Printf("Applied %d scheduled prices", n)
+			}
+			return err
+		}},
+		{"alerts.evaluate", "@every 1m", func(ctx context.Context) error {
+			n, err := a.handler.EvaluateStockAlerts(ctx)
+			if n > 0 {
+				log.Printf("Raised %d low-stock alerts", n)
+			}
+			return err
+		}},
+	}
+	for _, t := range tasks {
+		if err := a.scheduler.Add(t.name, t.spec, t.task); err != nil {
+			log.Printf("Not scheduling maintenance task: %v", err)
+		}
+	}
+}
+
 // Router returns the HTTP router with middleware
 func (a *App) Router() http.Handler {
 	// Apply middleware chain
 	var h http.Handler = a.router
+	h = middleware.Metrics(a.metrics)(h)
+	// Tenant replaces the request, so it stays outside Metrics, which reads
+	// the matched pattern from the request the router saw
+	h = middleware.Tenant(a.config.Tenancy)(h)
+	h = middleware.RequestLimits(a.config.Security)(h)
 	h = middleware.Logging(h)
 	h = middleware.Recovery(h)
-	h = middleware.CORS(h)
+	h = middleware.CORS(a.config.CORS)(h)
+	h = middleware.SecurityHeaders(a.config.Security)(h)
+	h = a.inFlight.Track(h)
+	h = middleware.Authenticate(a.config.Auth)(h)
+	h = middleware.ClientCert(h)
+	h = middleware.RequestID(h)
+
+	return h
+}
+
+// Lifecycle returns the manager components register start/stop hooks with
+func (a *App) Lifecycle() *Lifecycle {
+	return a.lifecycle
+}
+
+// AdminRouter returns the operational router served on the admin listener
+func (a *App) AdminRouter() http.Handler {
+	var h http.Handler = a.admin
+	h = middleware.Recovery(h)
 	h = middleware.RequestID(h)
 
 	return h
@@ -42,9 +360,11 @@ func (a *App) Router() http.Handler {
 
 // setupRoutes configures all application routes
 func (a *App) setupRoutes() {
-	// Health check endpoints
-	a.router.HandleFunc("GET /health", a.handler.Health)
-	a.router.HandleFunc("GET /ready", a.handler.Ready)
+	// Health check endpoints move to the admin listener when it is enabled
+	if a.config.Server.AdminAddress == "" {
+		a.router.HandleFunc("GET /health", a.handler.Health)
+		a.router.HandleFunc("GET /ready", a.handler.Ready)
+	}
 
 	// API v1 routes
 	a.router.HandleFunc("GET /api/v1/info", a.handler.Info)
@@ -52,9 +372,11 @@ func (a *App) setupRoutes() {
 	// User routes
 	a.router.HandleFunc("GET /api/v1/users", a.handler.ListUsers)
 	a.router.HandleFunc("GET /api/v1/users/{id}", a.handler.GetUser)
+	a.router.HandleFunc("GET /api/v1/users/{id}/items", a.handler.ListUserItems)
 	a.router.HandleFunc("POST /api/v1/users", a.handler.CreateUser)
 	a.router.HandleFunc("PUT /api/v1/users/{id}", a.handler.UpdateUser)
 	a.router.HandleFunc("DELETE /api/v1/users/{id}", a.handler.DeleteUser)
+	a.router.HandleFunc("POST /api/v1/users/{id}", a.handler.UserAction)
 
 	// Item routes
 	a.router.HandleFunc("GET /api/v1/items", a.handler.ListItems)
@@ -62,4 +384,89 @@ func (a *App) setupRoutes() {
 	a.router.HandleFunc("POST /api/v1/items", a.handler.CreateItem)
 	a.router.HandleFunc("PUT /api/v1/items/{id}", a.handler.UpdateItem)
 	a.router.HandleFunc("DELETE /api/v1/items/{id}", a.handler.DeleteItem)
+	a.router.HandleFunc("POST /api/v1/items/{id}", a.handler.ItemAction)
+	a.router.HandleFunc("POST /api/v1/items/{id}/adjustments", a.handler.AdjustStock)
+	a.router.HandleFunc("GET /api/v1/items/{id}/movements", a.handler.ListStockMovements)
+	a.router.HandleFunc("PUT /api/v1/items/{id}/category", a.handler.SetItemCategory)
+	a.router.HandleFunc("PUT /api/v1/items/{id}/tags", a.handler.SetItemTags)
+	a.router.HandleFunc("POST /api/v1/items/{id}/tags", a.handler.AddItemTags)
+	a.router.HandleFunc("DELETE /api/v1/items/{id}/tags/{tag}", a.handler.RemoveItemTag)
+	a.router.HandleFunc("GET /api/v1/items/{id}/prices", a.handler.ListPrices)
+	a.router.HandleFunc("POST /api/v1/items/{id}/prices", a.handler.SchedulePrice)
+	a.router.HandleFunc("DELETE /api/v1/items/{id}/prices/{pid}", a.handler.CancelPriceChange)
+	a.router.HandleFunc("GET /api/v1/items/{id}/attachments", a.handler.ListAttachments)
+	a.router.HandleFunc("POST /api/v1/items/{id}/attachments", a.handler.UploadAttachment)
+	a.router.HandleFunc("GET /api/v1/items/{id}/attachments/{aid}", a.handler.DownloadAttachment)
+	a.router.HandleFunc("GET /api/v1/items/{id}/attachments/{aid}/thumbnail", a.handler.AttachmentThumbnail)
+	a.router.HandleFunc("DELETE /api/v1/items/{id}/attachments/{aid}", a.handler.DeleteAttachment)
+	a.router.HandleFunc("POST /api/v1/items/export", a.handler.ExportItems)
+	a.router.HandleFunc("POST /api/v1/items/import", a.handler.ImportItems)
+
+	// Category routes
+	a.router.HandleFunc("GET /api/v1/categories", a.handler.ListCategories)
+	a.router.HandleFunc("GET /api/v1/categories/{id}", a.handler.GetCategory)
+	a.router.HandleFunc("POST /api/v1/categories", a.handler.CreateCategory)
+	a.router.HandleFunc("PUT /api/v1/categories/{id}", a.handler.UpdateCategory)
+	a.router.HandleFunc("DELETE /api/v1/categories/{id}", a.handler.DeleteCategory)
+	a.router.HandleFunc("POST /api/v1/categories/{id}", a.handler.CategoryAction)
+
+	// Reservation routes
+	a.router.HandleFunc("GET /api/v1/reservations", a.handler.ListReservations)
+	a.router.HandleFunc("GET /api/v1/reservations/{id}", a.handler.GetReservation)
+	a.router.HandleFunc("POST /api/v1/reservations", a.handler.CreateReservation)
+	a.router.HandleFunc("POST /api/v1/reservations/{id}", a.handler.ReservationAction)
+
+	// Order routes
+	a.router.HandleFunc("GET /api/v1/orders", a.handler.ListOrders)
+	a.router.HandleFunc("GET /api/v1/orders/{id}", a.handler.GetOrder)
+	a.router.HandleFunc("POST /api/v1/orders", a.handler.CreateOrder)
+	a.router.HandleFunc("PUT /api/v1/orders/{id}", a.handler.UpdateOrder)
+	a.router.HandleFunc("DELETE /api/v1/orders/{id}", a.handler.DeleteOrder)
+	a.router.HandleFunc("POST /api/v1/orders/{id}", a.handler.OrderAction)
+
+	// Alert routes
+	a.router.HandleFunc("GET /api/v1/alerts", a.handler.ListAlerts)
+	a.router.HandleFunc("GET /api/v1/alerts/{id}", a.handler.GetAlert)
+	a.router.HandleFunc("POST /api/v1/alerts/{id}", a.handler.AlertAction)
+
+	// Search routes
+	a.router.HandleFunc("GET /api/v1/search", a.handler.Search)
+
+	// Job routes
+	a.router.HandleFunc("GET /api/v1/jobs/{id}", a.handler.GetJob)
+
+	// Audit routes
+	a.router.HandleFunc("GET /api/v1/audit", a.handler.ListAudit)
+	a.router.HandleFunc("GET /api/v1/audit/verify", a.handler.VerifyAudit)
+
+	// Change feed routes
+	a.router.HandleFunc("GET /api/v1/events", a.handler.StreamEvents)
+	a.router.HandleFunc("GET /api/v1/events/ws", a.handler.EventsWebSocket)
+
+	// Webhook routes
+	a.router.HandleFunc("GET /api/v1/webhooks", a.handler.ListWebhooks)
+	a.router.HandleFunc("GET /api/v1/webhooks/{id}", a.handler.GetWebhook)
+	a.router.HandleFunc("POST /api/v1/webhooks", a.handler.CreateWebhook)
+	a.router.HandleFunc("PUT /api/v1/webhooks/{id}", a.handler.UpdateWebhook)
+	a.router.HandleFunc("DELETE /api/v1/webhooks/{id}", a.handler.DeleteWebhook)
+	a.router.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", a.handler.ListWebhookDeliveries)
+	a.router.HandleFunc("POST /api/v1/webhooks/{id}/deliveries/{deliveryID}/redeliver", a.handler.RedeliverWebhook)
+}
+
+// setupAdminRoutes configures operational routes for the admin listener
+func (a *App) setupAdminRoutes() {
+	a.admin.HandleFunc("GET /health", a.handler.Health)
+	a.admin.HandleFunc("GET /ready", a.handler.Ready)
+	a.admin.Handle("GET /metrics", a.metrics.Handler())
+	a.admin.HandleFunc("GET /buildinfo", a.handler.BuildInfo)
+	a.admin.HandleFunc("GET /config", a.handler.ConfigDump)
+	a.admin.HandleFunc("GET /schedules", a.handler.ListSchedules)
+
+	// Runtime profiling
+	a.admin.HandleFunc("GET /debug/pprof/", pprof.Index)
+	a.admin.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	a.admin.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	a.admin.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	a.admin.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	a.admin.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
 }
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/middleware"
+)
+
+// Hook orders. Storage starts first and stops last so every other
+// component can still use it; background workers stop after the servers
+// drain so work queued by the last requests is still picked up; event
+// streams stop before the servers drain because long-lived connections
+// would otherwise hold shutdown open.
+const (
+	OrderStorage      = -100
+	OrderWorkers      = -50
+	OrderAdminServer  = 0
+	OrderPublicServer = 100
+	OrderStreams      = 200
+)
+
+// ErrDraining is reported by the readiness check while shutting down
+var ErrDraining = errors.New("shutting down")
+
+// Hook is a component managed by the Lifecycle. Hooks start in ascending
+// Order and stop in reverse, so lower orders outlive higher ones.
+type Hook struct {
+	Name    string
+	Order   int
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle starts and stops application components in order and drains
+// traffic before stopping them
+type Lifecycle struct {
+	cfg      config.ShutdownConfig
+	inFlight *middleware.InFlightTracker
+
+	mu       sync.Mutex
+	hooks    []Hook
+	started  []Hook
+	draining atomic.Bool
+}
+
+// NewLifecycle creates a lifecycle manager reporting on inFlight requests
+func NewLifecycle(cfg config.ShutdownConfig, inFlight *middleware.InFlightTracker) *Lifecycle {
+	return &Lifecycle{
+		cfg:      cfg,
+		inFlight: inFlight,
+	}
+}
+
+// Append registers a hook. Hooks with equal Order keep registration order.
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start runs OnStart for every hook. If one fails, hooks already started
+// are stopped in reverse order and the error is returned.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	hooks := make([]Hook, len(l.hooks))
+	copy(hooks, l.hooks)
+	l.mu.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Order < hooks[j].Order
+	})
+
+	for _, hook := range hooks {
+		if hook.OnStart != nil {
+			if err := hook.OnStart(ctx); err != nil {
+				stopErr := l.stopStarted(ctx)
+				return errors.Join(fmt.Errorf("start %s: %w", hook.Name, err), stopErr)
+			}
+		}
+		l.mu.Lock()
+		l.started = append(l.started, hook)
+		l.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Stop marks the service as draining, waits the pre-stop delay so load
+// balancers observe the failing readiness check, then stops hooks in
+// reverse order. If ctx expires, the requests still in flight are logged.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.draining.Store(true)
+
+	if l.cfg.PreStopDelay > 0 {
+		log.Printf("Draining for %v before stopping", l.cfg.PreStopDelay)
+		select {
+		case <-time.After(l.cfg.PreStopDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	err := l.stopStarted(ctx)
+
+	if ctx.Err() != nil && l.inFlight != nil {
+		l.reportInFlight()
+	}
+
+	return err
+}
+
+// Draining reports whether Stop has been called
+func (l *Lifecycle) Draining() bool {
+	return l.draining.Load()
+}
+
+// ReadinessCheck fails once the lifecycle starts draining
+func (l *Lifecycle) ReadinessCheck() error {
+	if l.Draining() {
+		return ErrDraining
+	}
+	return nil
+}
+
+func (l *Lifecycle) stopStarted(ctx context.Context) error {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		hook := started[i]
+		if hook.OnStop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := hook.OnStop(ctx); err != nil {
+			log.Printf("Stopping %s failed after %v: %v", hook.Name, time.Since(start), err)
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+			continue
+		}
+		log.Printf("Stopped %s in %v", hook.Name, time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l *Lifecycle) reportInFlight() {
+	requests := l.inFlight.Snapshot()
+	if len(requests) == 0 {
+		return
+	}
+
+	log.Printf("Shutdown deadline reached with %d request(s) in flight:", len(requests))
+	for _, req := range requests {
+		log.Printf("  %s %s request_id=%s running=%v", req.Method, req.Path, req.RequestID, req.Duration.Round(time.Millisecond))
+	}
+}
+
+// backgroundHook runs fn in a goroutine between start and stop. Stopping
+// cancels fn's context and waits for it to return.
+func backgroundHook(name string, order int, fn func(ctx context.Context)) Hook {
+	var cancel context.CancelFunc
+	done := make(chan struct{})
+
+	return Hook{
+		Name:  name,
+		Order: order,
+		OnStart: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(done)
+				fn(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
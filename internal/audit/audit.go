@@ -0,0 +1,289 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gostructure/app/pkg/uuid"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// ErrTampered is returned when the hash chain does not verify
+var ErrTampered = errors.New("audit log hash chain broken")
+
+// Change is the before and after value of one changed field
+type Change struct {
+	From json.RawMessage `json:"from,omitempty"`
+	To   json.RawMessage `json:"to,omitempty"`
+}
+
+// Entry is one audited mutation. Each entry's hash covers its content and
+// the previous entry's hash, so any edit or removal breaks the chain.
+type Entry struct {
+	Seq        uint64            `json:"seq"`
+	ID         string            `json:"id"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Actor      string            `json:"actor"`
+	Tenant     string            `json:"tenant,omitempty"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Action     string            `json:"action"`
+	Resource   string            `json:"resource"`
+	ResourceID string            `json:"resource_id"`
+	Before     json.RawMessage   `json:"before,omitempty"`
+	After      json.RawMessage   `json:"after,omitempty"`
+	Changes    map[string]Change `json:"changes,omitempty"`
+	PrevHash   string            `json:"prev_hash"`
+	Hash       string            `json:"hash"`
+}
+
+// Filter selects entries in Query. Zero fields match everything.
+type Filter struct {
+	Actor      string
+	Tenant     string
+	Resource   string
+	ResourceID string
+	Since      time.Time
+	Until      time.Time
+	AfterSeq   uint64
+	Limit      int
+}
+
+// Log is an append-only, hash-chained audit log kept in memory and
+// optionally mirrored to a JSON-lines file
+type Log struct {
+	mu      sync.RWMutex
+	entries []Entry
+	ids     map[string]struct{}
+	file    *os.File
+	// flushMu serializes Flush so staged entries are appended once
+	flushMu sync.Mutex
+}
+
+// NewLog creates an in-memory audit log
+func NewLog() *Log {
+	return &Log{ids: make(map[string]struct{})}
+}
+
+// OpenFile opens or creates a file-backed audit log, verifying the chain
+// of any existing entries
+func OpenFile(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	l := &Log{ids: make(map[string]struct{}), file: f}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("read audit entry %d: %w", len(l.entries)+1, err)
+		}
+		l.entries = append(l.entries, e)
+		l.ids[e.ID] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+
+	if err := l.Verify(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append assigns the entry its sequence number and hash and stores it. An
+// entry whose ID is already in the log is returned as stored.
+func (l *Log) Append(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e.ID == "" {
+		e.ID = uuid.New()
+	}
+	if _, ok := l.ids[e.ID]; ok {
+		for i := len(l.entries) - 1; i >= 0; i-- {
+			if l.entries[i].ID == e.ID {
+				return l.entries[i], nil
+			}
+		}
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	e.Seq = uint64(len(l.entries)) + 1
+	e.PrevHash = ""
+	if n := len(l.entries); n > 0 {
+		e.PrevHash = l.entries[n-1].Hash
+	}
+
+	hash, err := hashEntry(e)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Hash = hash
+
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return Entry{}, err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return Entry{}, fmt.Errorf("write audit entry: %w", err)
+		}
+		if err := l.file.Sync(); err != nil {
+			return Entry{}, fmt.Errorf("sync audit log: %w", err)
+		}
+	}
+
+	l.entries = append(l.entries, e)
+	l.ids[e.ID] = struct{}{}
+	return e, nil
+}
+
+// Query returns entries matching f in sequence order
+func (l *Log) Query(f Filter) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// Entries are ordered by sequence, so skip straight past the cursor
+	start := sort.Search(len(l.entries), func(i int) bool {
+		return l.entries[i].Seq > f.AfterSeq
+	})
+
+	result := make([]Entry, 0)
+	for _, e := range l.entries[start:] {
+		if f.Actor != "" && e.Actor != f.Actor {
+			continue
+		}
+		if f.Tenant != "" && e.Tenant != f.Tenant {
+			continue
+		}
+		if f.Resource != "" && e.Resource != f.Resource {
+			continue
+		}
+		if f.ResourceID != "" && e.ResourceID != f.ResourceID {
+			continue
+		}
+		if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && !e.Timestamp.Before(f.Until) {
+			continue
+		}
+		result = append(result, e)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// Verify recomputes the hash chain and reports the first broken entry
+func (l *Log) Verify() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	prev := ""
+	for i, e := range l.entries {
+		if e.Seq != uint64(i)+1 {
+			return fmt.Errorf("%w: entry %d has sequence %d", ErrTampered, i+1, e.Seq)
+		}
+		if e.PrevHash != prev {
+			return fmt.Errorf("%w: entry %d does not follow its predecessor", ErrTampered, e.Seq)
+		}
+		hash, err := hashEntry(e)
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return fmt.Errorf("%w: entry %d content does not match its hash", ErrTampered, e.Seq)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Len returns the number of entries
+func (l *Log) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.entries)
+}
+
+// Close releases the backing file, if any
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// hashEntry hashes the entry's JSON encoding with the hash field cleared
+func hashEntry(e Entry) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("encode audit entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Diff returns the top-level fields that differ between the JSON encodings
+// of before and after
+func Diff(before, after json.RawMessage) map[string]Change {
+	var b, a map[string]json.RawMessage
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &b); err != nil {
+			return nil
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &a); err != nil {
+			return nil
+		}
+	}
+
+	changes := make(map[string]Change)
+	for k, v := range a {
+		if old, ok := b[k]; !ok || !bytes.Equal(old, v) {
+			changes[k] = Change{From: b[k], To: v}
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			changes[k] = Change{From: v}
+		}
+	}
+	return changes
+}
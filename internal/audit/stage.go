@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/uuid"
+)
+
+// staged is an audit entry committed with the change it records and not
+// yet appended to the log
+type staged struct {
+	Key   int64 `json:"key"`
+	Entry Entry `json:"entry"`
+}
+
+func (s staged) TenantID() string { return s.Entry.Tenant }
+
+func (s *staged) SetTenant(tenant string) { s.Entry.Tenant = tenant }
+
+var stagedTable = store.NewTable[staged]("audit_staged")
+
+// Stage writes e in tx so it is committed or rolled back together with the
+// change it records. Staged entries reach the log on the next Flush.
+func Stage(tx *store.Tx, e Entry) error {
+	if e.ID == "" {
+		e.ID = uuid.New()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	_, err := stagedTable.Insert(tx, func(id int64) staged {
+		return staged{Key: id, Entry: e}
+	})
+	return err
+}
+
+// Flush appends the entries staged in s to the log in commit order and
+// removes them from the store. Entries already in the log, left behind by a
+// flush that was interrupted, are not appended again.
+func (l *Log) Flush(s *store.Store) error {
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+
+	var pending []staged
+	s.View(func(tx *store.Tx) error {
+		pending = stagedTable.List(tx)
+		return nil
+	})
+	if len(pending) == 0 {
+		return nil
+	}
+
+	for i, p := range pending {
+		if _, err := l.Append(p.Entry); err != nil {
+			pending = pending[:i]
+			if removeErr := removeStaged(s, pending); removeErr != nil {
+				return removeErr
+			}
+			return fmt.Errorf("flush audit entry %s: %w", p.Entry.ID, err)
+		}
+	}
+	return removeStaged(s, pending)
+}
+
+func removeStaged(s *store.Store, flushed []staged) error {
+	if len(flushed) == 0 {
+		return nil
+	}
+	return s.Update(func(tx *store.Tx) error {
+		for _, p := range flushed {
+			stagedTable.Delete(tx, p.Key)
+		}
+		return nil
+	})
+}
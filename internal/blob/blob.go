@@ -0,0 +1,191 @@
+// Package blob stores immutable binary objects, such as uploaded files,
+// under content-derived keys.
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound is returned when opening a key that is not stored
+var ErrNotFound = errors.New("blob not found")
+
+// Store holds blobs by key. Keys are lowercase hexadecimal strings, such as
+// checksums, and a blob is never changed once written.
+type Store interface {
+	// Put stores the contents of r under key, replacing nothing if the key
+	// already exists
+	Put(key string, r io.Reader) error
+	// Open returns the blob stored under key
+	Open(key string) (io.ReadSeekCloser, error)
+	// Exists reports whether key is stored
+	Exists(key string) (bool, error)
+	// Delete removes key if it is stored
+	Delete(key string) error
+}
+
+func validKey(key string) error {
+	if len(key) < 4 {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, r := range key {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
+
+// MemoryStore is a Store for a single process
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string][]byte
+}
+
+// NewMemoryStore creates an in-memory blob store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: make(map[string][]byte)}
+}
+
+// Put implements Store
+func (s *MemoryStore) Put(key string, r io.Reader) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blobs[key]; !ok {
+		s.blobs[key] = data
+	}
+	return nil
+}
+
+// Open implements Store
+func (s *MemoryStore) Open(key string) (io.ReadSeekCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// Exists implements Store
+func (s *MemoryStore) Exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.blobs[key]
+	return ok, nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// FSStore stores blobs as files in a directory, spread over subdirectories
+// named by the first two characters of the key
+type FSStore struct {
+	dir string
+}
+
+// NewFSStore creates a blob store backed by files in dir
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create blob directory: %w", err)
+	}
+	return &FSStore{dir: dir}, nil
+}
+
+func (s *FSStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+// Put implements Store. The blob is written to a temporary file and renamed
+// into place, so a crash never leaves a partial blob under key.
+func (s *FSStore) Put(key string, r io.Reader) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write blob: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open implements Store
+func (s *FSStore) Open(key string) (io.ReadSeekCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Exists implements Store
+func (s *FSStore) Exists(key string) (bool, error) {
+	if err := validKey(key); err != nil {
+		return false, nil
+	}
+	_, err := os.Stat(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete implements Store
+func (s *FSStore) Delete(key string) error {
+	if err := validKey(key); err != nil {
+		return nil
+	}
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -1,24 +1,86 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	App      AppConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	App         AppConfig
+	CORS        CORSConfig
+	Security    SecurityConfig
+	Shutdown    ShutdownConfig
+	Auth        AuthConfig
+	Audit       AuditConfig
+	Events      EventsConfig
+	Webhooks    WebhookConfig
+	Store       StoreConfig
+	Outbox      OutboxConfig
+	Jobs        JobsConfig
+	Scheduler   SchedulerConfig
+	Inventory   InventoryConfig
+	Attachments AttachmentsConfig
+	Alerts      AlertsConfig
+	Tenancy     TenancyConfig
+	Users       UsersConfig
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Address      string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	IdleTimeout  time.Duration
+	Address           string
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	TLS               TLSConfig
+	// Listeners overrides Address when set
+	Listeners []ListenerConfig
+	// AdminAddress enables a separate plain-HTTP listener for health,
+	// metrics, profiling and config endpoints
+	AdminAddress string
+}
+
+// ListenerConfig describes one socket the server accepts connections on
+type ListenerConfig struct {
+	// Network is "tcp", "unix" or "fd" for a systemd-activated socket
+	Network string
+	// Address is host:port, a socket path, or an inherited fd number or name
+	Address string
+	// SocketMode sets the permissions of unix socket files
+	SocketMode os.FileMode
+	// H2C serves HTTP/2 over cleartext connections
+	H2C bool
+	// TLS serves the listener over HTTPS using ServerConfig.TLS
+	TLS bool
+}
+
+// TLSConfig holds HTTPS and client-certificate configuration.
+// TLS is enabled when both CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	MinVersion   string
+	CipherSuites []string
+	// ClientCAFile enables client-certificate verification against the bundle
+	ClientCAFile string
+	// ClientAuth is "none", "optional" or "require" (default when a CA is set)
+	ClientAuth      string
+	ReloadInterval  time.Duration
+	RedirectAddress string
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
 }
 
 // DatabaseConfig holds database configuration
@@ -37,16 +99,299 @@ type AppConfig struct {
 	Version     string
 	Environment string
 	Debug       bool
+	// BuildVersion and BuildTime are set at link time by cmd/app
+	BuildVersion string
+	BuildTime    string
+}
+
+// CORSConfig holds the cross-origin resource sharing policy.
+// AllowedOrigins entries may be "*" to allow any origin, or contain a
+// "*" wildcard such as "https://*.example.com" to match a pattern.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+	Routes           []CORSRouteConfig
+}
+
+// CORSRouteConfig overrides the CORS policy for paths under PathPrefix.
+// Empty lists inherit the base policy; credentials must be enabled explicitly.
+type CORSRouteConfig struct {
+	PathPrefix       string
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+}
+
+// SecurityConfig holds response hardening headers and request limits.
+// Zero values disable the corresponding header or limit.
+type SecurityConfig struct {
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+	FrameOptions          string
+	ContentTypeNosniff    bool
+
+	MaxBodyBytes    int64
+	MaxHeaderCount  int
+	MaxHeaderBytes  int
+	BodyReadTimeout time.Duration
+	// AllowedContentTypes lists the media types accepted on POST, PUT and
+	// PATCH requests that carry a body
+	AllowedContentTypes []string
+	// MaxUploadBytes caps multipart/form-data bodies, which are accepted
+	// besides AllowedContentTypes; zero rejects them
+	MaxUploadBytes int64
+}
+
+// ShutdownConfig holds graceful shutdown timing
+type ShutdownConfig struct {
+	// Timeout bounds the whole shutdown, including the pre-stop delay
+	Timeout time.Duration
+	// PreStopDelay keeps serving while readiness reports 503 so load
+	// balancers stop routing new traffic before listeners close
+	PreStopDelay time.Duration
+}
+
+// AuthConfig holds how request principals are identified. Verified client
+// certificates are always trusted; identity headers only when TrustHeaders
+// is set because the service sits behind an authenticating proxy.
+type AuthConfig struct {
+	TrustHeaders  bool
+	SubjectHeader string
+	RolesHeader   string
+	// TenantHeader carries the tenant claim of the proxy-verified token
+	TenantHeader string
+	// AdminSubjects are granted the admin role regardless of source
+	AdminSubjects []string
+}
+
+// AuditConfig holds audit log persistence configuration
+type AuditConfig struct {
+	// File is the append-only log path; empty keeps the log in memory
+	File string
+}
+
+// EventsConfig holds change feed configuration
+type EventsConfig struct {
+	// BufferSize is how many recent events are kept for resuming clients
+	BufferSize int
+	// SubscriberBuffer is how many undelivered events a subscriber may
+	// queue before it is disconnected
+	SubscriberBuffer  int
+	HeartbeatInterval time.Duration
+}
+
+// StoreConfig holds application state persistence configuration
+type StoreConfig struct {
+	// File is the transaction journal path; empty keeps state in memory
+	File string
+	// TrashRetention is how long deleted users and items can be restored
+	// before they are purged; zero keeps them forever
+	TrashRetention time.Duration
+}
+
+// OutboxConfig holds outbox relay configuration
+type OutboxConfig struct {
+	PollInterval time.Duration
+	RetryBackoff time.Duration
+	// FileSink additionally appends relayed events to this file
+	FileSink string
+}
+
+// JobsConfig holds background job configuration
+type JobsConfig struct {
+	// Workers bounds the jobs running at once across all queues
+	Workers int
+	// Queues limits concurrency per queue name
+	Queues map[string]int
+	// Timeout is the default limit for one attempt
+	Timeout        time.Duration
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	PollInterval   time.Duration
+	// Retention is how long finished jobs are kept before being purged
+	Retention time.Duration
+}
+
+// SchedulerConfig holds recurring task configuration
+type SchedulerConfig struct {
+	// LeaseDir is a directory shared by all replicas used to elect the one
+	// that runs each task; empty runs every task on every instance
+	LeaseDir string
+	// InstanceID identifies this replica as a lease holder
+	InstanceID string
+	LeaseTTL   time.Duration
+	// Jitter delays each run by a random duration up to this value
+	Jitter time.Duration
+	// Schedules overrides the schedule of named tasks; "off" disables one
+	Schedules map[string]string
+}
+
+// InventoryConfig holds stock reservation configuration
+type InventoryConfig struct {
+	// ReservationTTL is how long a reservation holds stock when the request
+	// does not say
+	ReservationTTL time.Duration
+	// MaxReservationTTL bounds the TTL a request may ask for
+	MaxReservationTTL time.Duration
+}
+
+// AttachmentsConfig holds item attachment configuration
+type AttachmentsConfig struct {
+	// Dir is where attachment contents are stored; empty keeps them in
+	// memory
+	Dir string
+	// MaxSize bounds the size of one file
+	MaxSize int64
+	// AllowedTypes lists the sniffed media types that may be uploaded
+	AllowedTypes []string
+	// ThumbnailSize bounds the width and height of image previews
+	ThumbnailSize int
+}
+
+// AlertsConfig holds low-stock alert notification configuration
+type AlertsConfig struct {
+	// Channels names where alerts are sent: log, webhook and smtp
+	Channels []string
+	// WebhookURL receives alerts as JSON, signed with WebhookSecret if set
+	WebhookURL    string
+	WebhookSecret string
+	// SMTPAddr is a mail server accepting unauthenticated mail, such as a
+	// local relay
+	SMTPAddr string
+	SMTPFrom string
+	SMTPTo   []string
+	// Timeout bounds sending one alert through one channel
+	Timeout time.Duration
+}
+
+// UsersConfig holds user management configuration
+type UsersConfig struct {
+	// ItemsOnDelete is what happens to a deleted user's items when the
+	// request does not say: block, orphan or reassign
+	ItemsOnDelete string
+}
+
+// TenancyConfig holds multi-tenant hosting configuration. When enabled,
+// every API request is served for one tenant and only sees its data.
+type TenancyConfig struct {
+	Enabled bool
+	// Header names the request header selecting the tenant
+	Header string
+	// BaseDomain resolves the tenant from the first label of hosts under
+	// it, such as acme.example.com for example.com
+	BaseDomain string
+	// Tenants lists the tenants served; empty serves any valid tenant ID
+	Tenants []string
+	// MaxUsers and MaxItems bound the live records of each tenant; zero is
+	// unlimited
+	MaxUsers int
+	MaxItems int
+	// Overrides replace settings for individual tenants
+	Overrides map[string]TenantOverrides
+}
+
+// TenantOverrides are settings of one tenant; zero values keep the
+// deployment-wide setting
+type TenantOverrides struct {
+	MaxUsers          int
+	MaxItems          int
+	ReservationTTL    time.Duration
+	MaxReservationTTL time.Duration
+	AttachmentMaxSize int64
+	AttachmentTypes   []string
+}
+
+// ForTenant returns the configuration with the overrides of tenant applied
+func (c *Config) ForTenant(tenant string) *Config {
+	o, ok := c.Tenancy.Overrides[tenant]
+	if !ok {
+		return c
+	}
+	cfg := *c
+	if o.MaxUsers > 0 {
+		cfg.Tenancy.MaxUsers = o.MaxUsers
+	}
+	if o.MaxItems > 0 {
+		cfg.Tenancy.MaxItems = o.MaxItems
+	}
+	if o.ReservationTTL > 0 {
+		cfg.Inventory.ReservationTTL = o.ReservationTTL
+	}
+	if o.MaxReservationTTL > 0 {
+		cfg.Inventory.MaxReservationTTL = o.MaxReservationTTL
+	}
+	if o.AttachmentMaxSize > 0 {
+		cfg.Attachments.MaxSize = o.AttachmentMaxSize
+	}
+	if len(o.AttachmentTypes) > 0 {
+		cfg.Attachments.AllowedTypes = o.AttachmentTypes
+	}
+	return &cfg
+}
+
+// WebhookConfig holds outbound webhook delivery configuration
+type WebhookConfig struct {
+	Workers int
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is how many attempts are made before a delivery is
+	// dead-lettered
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
 }
 
 // Load loads configuration from environment variables with defaults
 func Load() (*Config, error) {
+	env := getEnv("APP_ENV", "development")
+	tlsEnabled := getEnv("TLS_CERT_FILE", "") != "" && getEnv("TLS_KEY_FILE", "") != ""
+
+	listeners, err := parseListeners(os.Getenv("SERVER_LISTENERS"), tlsEnabled)
+	if err != nil {
+		return nil, err
+	}
+
+	// Credentials would let any site act on behalf of the user
+	corsOrigins := getSliceEnv("CORS_ALLOWED_ORIGINS", []string{"*"})
+	corsCredentials := getBoolEnv("CORS_ALLOW_CREDENTIALS", false)
+	if corsCredentials && slices.Contains(corsOrigins, "*") {
+		return nil, errors.New("CORS_ALLOW_CREDENTIALS cannot be combined with the \"*\" origin")
+	}
+
+	// HSTS is only meaningful once the service is reachable over HTTPS
+	hstsMaxAge := time.Duration(0)
+	if env == "production" {
+		hstsMaxAge = 365 * 24 * time.Hour
+	}
+
 	return &Config{
 		Server: ServerConfig{
-			Address:      getEnv("SERVER_ADDRESS", ":8080"),
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
-			IdleTimeout:  getDurationEnv("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			Address:           getEnv("SERVER_ADDRESS", ":8080"),
+			ReadTimeout:       getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:      getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			IdleTimeout:       getDurationEnv("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			ReadHeaderTimeout: getDurationEnv("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+			TLS: TLSConfig{
+				CertFile:        getEnv("TLS_CERT_FILE", ""),
+				KeyFile:         getEnv("TLS_KEY_FILE", ""),
+				MinVersion:      getEnv("TLS_MIN_VERSION", "1.2"),
+				CipherSuites:    getSliceEnv("TLS_CIPHER_SUITES", nil),
+				ClientCAFile:    getEnv("TLS_CLIENT_CA_FILE", ""),
+				ClientAuth:      getEnv("TLS_CLIENT_AUTH", ""),
+				ReloadInterval:  getDurationEnv("TLS_RELOAD_INTERVAL", 30*time.Second),
+				RedirectAddress: getEnv("TLS_REDIRECT_ADDRESS", ""),
+			},
+			Listeners:    listeners,
+			AdminAddress: getEnv("SERVER_ADMIN_ADDRESS", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -59,9 +404,116 @@ func Load() (*Config, error) {
 		App: AppConfig{
 			Name:        getEnv("APP_NAME", "GoStructure App"),
 			Version:     getEnv("APP_VERSION", "1.0.0"),
-			Environment: getEnv("APP_ENV", "development"),
+			Environment: env,
 			Debug:       getBoolEnv("APP_DEBUG", true),
 		},
+		CORS: CORSConfig{
+			AllowedOrigins:   corsOrigins,
+			AllowedMethods:   getSliceEnv("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"}),
+			AllowedHeaders:   getSliceEnv("CORS_ALLOWED_HEADERS", []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"}),
+			ExposedHeaders:   getSliceEnv("CORS_EXPOSED_HEADERS", []string{"X-Request-ID"}),
+			AllowCredentials: corsCredentials,
+			MaxAge:           getDurationEnv("CORS_MAX_AGE", 24*time.Hour),
+			Routes:           getCORSRoutesEnv("CORS_ROUTES"),
+		},
+		Shutdown: ShutdownConfig{
+			Timeout:      getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
+			PreStopDelay: getDurationEnv("SHUTDOWN_PRE_STOP_DELAY", 0),
+		},
+		Auth: AuthConfig{
+			TrustHeaders:  getBoolEnv("AUTH_TRUST_HEADERS", false),
+			SubjectHeader: getEnv("AUTH_SUBJECT_HEADER", "X-Auth-Subject"),
+			RolesHeader:   getEnv("AUTH_ROLES_HEADER", "X-Auth-Roles"),
+			TenantHeader:  getEnv("AUTH_TENANT_HEADER", "X-Auth-Tenant"),
+			AdminSubjects: getSliceEnv("AUTH_ADMIN_SUBJECTS", nil),
+		},
+		Audit: AuditConfig{
+			File: getEnv("AUDIT_FILE", ""),
+		},
+		Events: EventsConfig{
+			BufferSize:        getIntEnv("EVENTS_BUFFER_SIZE", 1000),
+			SubscriberBuffer:  getIntEnv("EVENTS_SUBSCRIBER_BUFFER", 64),
+			HeartbeatInterval: getDurationEnv("EVENTS_HEARTBEAT_INTERVAL", 15*time.Second),
+		},
+		Store: StoreConfig{
+			File:           getEnv("STORE_FILE", ""),
+			TrashRetention: getDurationEnv("STORE_TRASH_RETENTION", 30*24*time.Hour),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: getDurationEnv("OUTBOX_POLL_INTERVAL", time.Second),
+			RetryBackoff: getDurationEnv("OUTBOX_RETRY_BACKOFF", time.Second),
+			FileSink:     getEnv("OUTBOX_FILE_SINK", ""),
+		},
+		Jobs: JobsConfig{
+			Workers:        getIntEnv("JOBS_WORKERS", 4),
+			Queues:         getIntMapEnv("JOBS_QUEUES", map[string]int{"default": 2, "exports": 1, "imports": 1}),
+			Timeout:        getDurationEnv("JOBS_TIMEOUT", 5*time.Minute),
+			MaxAttempts:    getIntEnv("JOBS_MAX_ATTEMPTS", 3),
+			InitialBackoff: getDurationEnv("JOBS_INITIAL_BACKOFF", time.Second),
+			MaxBackoff:     getDurationEnv("JOBS_MAX_BACKOFF", 5*time.Minute),
+			PollInterval:   getDurationEnv("JOBS_POLL_INTERVAL", time.Second),
+			Retention:      getDurationEnv("JOBS_RETENTION", 7*24*time.Hour),
+		},
+		Scheduler: SchedulerConfig{
+			LeaseDir:   getEnv("SCHEDULER_LEASE_DIR", ""),
+			InstanceID: getEnv("SCHEDULER_INSTANCE_ID", defaultInstanceID()),
+			LeaseTTL:   getDurationEnv("SCHEDULER_LEASE_TTL", time.Minute),
+			Jitter:     getDurationEnv("SCHEDULER_JITTER", 10*time.Second),
+			Schedules:  getStringMapEnv("SCHEDULER_SCHEDULES"),
+		},
+		Inventory: InventoryConfig{
+			ReservationTTL:    getDurationEnv("INVENTORY_RESERVATION_TTL", 15*time.Minute),
+			MaxReservationTTL: getDurationEnv("INVENTORY_MAX_RESERVATION_TTL", 24*time.Hour),
+		},
+		Attachments: AttachmentsConfig{
+			Dir:           getEnv("ATTACHMENTS_DIR", ""),
+			MaxSize:       getInt64Env("ATTACHMENTS_MAX_SIZE", 8<<20),
+			AllowedTypes:  getSliceEnv("ATTACHMENTS_ALLOWED_TYPES", []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}),
+			ThumbnailSize: getIntEnv("ATTACHMENTS_THUMBNAIL_SIZE", 256),
+		},
+		Alerts: AlertsConfig{
+			Channels:      getSliceEnv("ALERTS_CHANNELS", []string{"log"}),
+			WebhookURL:    getEnv("ALERTS_WEBHOOK_URL", ""),
+			WebhookSecret: getEnv("ALERTS_WEBHOOK_SECRET", ""),
+			SMTPAddr:      getEnv("ALERTS_SMTP_ADDR", "localhost:1025"),
+			SMTPFrom:      getEnv("ALERTS_SMTP_FROM", "alerts@localhost"),
+			SMTPTo:        getSliceEnv("ALERTS_SMTP_TO", nil),
+			Timeout:       getDurationEnv("ALERTS_TIMEOUT", 10*time.Second),
+		},
+		Users: UsersConfig{
+			ItemsOnDelete: getEnv("USERS_ITEMS_ON_DELETE", "block"),
+		},
+		Tenancy: TenancyConfig{
+			Enabled:    getBoolEnv("TENANCY_ENABLED", false),
+			Header:     getEnv("TENANCY_HEADER", "X-Tenant-ID"),
+			BaseDomain: getEnv("TENANCY_BASE_DOMAIN", ""),
+			Tenants:    getSliceEnv("TENANCY_TENANTS", nil),
+			MaxUsers:   getIntEnv("TENANCY_MAX_USERS", 0),
+			MaxItems:   getIntEnv("TENANCY_MAX_ITEMS", 0),
+			Overrides:  getTenantOverridesEnv("TENANCY_OVERRIDES"),
+		},
+		Webhooks: WebhookConfig{
+			Workers:        getIntEnv("WEBHOOK_WORKERS", 4),
+			Timeout:        getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:    getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+			InitialBackoff: getDurationEnv("WEBHOOK_INITIAL_BACKOFF", time.Second),
+			MaxBackoff:     getDurationEnv("WEBHOOK_MAX_BACKOFF", time.Hour),
+		},
+		Security: SecurityConfig{
+			HSTSMaxAge:            getDurationEnv("SECURITY_HSTS_MAX_AGE", hstsMaxAge),
+			HSTSIncludeSubdomains: getBoolEnv("SECURITY_HSTS_INCLUDE_SUBDOMAINS", true),
+			HSTSPreload:           getBoolEnv("SECURITY_HSTS_PRELOAD", false),
+			ContentSecurityPolicy: getEnv("SECURITY_CSP", "default-src 'none'; frame-ancestors 'none'"),
+			ReferrerPolicy:        getEnv("SECURITY_REFERRER_POLICY", "no-referrer"),
+			FrameOptions:          getEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+			ContentTypeNosniff:    getBoolEnv("SECURITY_NOSNIFF", true),
+			MaxBodyBytes:          getInt64Env("SECURITY_MAX_BODY_BYTES", 1<<20),
+			MaxHeaderCount:        getIntEnv("SECURITY_MAX_HEADER_COUNT", 100),
+			MaxHeaderBytes:        getIntEnv("SECURITY_MAX_HEADER_BYTES", 16<<10),
+			BodyReadTimeout:       getDurationEnv("SECURITY_BODY_READ_TIMEOUT", 10*time.Second),
+			AllowedContentTypes:   getSliceEnv("SECURITY_ALLOWED_CONTENT_TYPES", []string{"application/json"}),
+			MaxUploadBytes:        getInt64Env("SECURITY_MAX_UPLOAD_BYTES", 10<<20),
+		},
 	}, nil
 }
 
@@ -83,6 +535,15 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getInt64Env(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if intVal, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intVal
+		}
+	}
+	return defaultValue
+}
+
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
@@ -100,3 +561,163 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getSliceEnv(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		return splitList(value, ",")
+	}
+	return defaultValue
+}
+
+// getCORSRoutesEnv parses per-route origin overrides in the form
+// "/prefix=origin1 origin2;/other=*".
+func getCORSRoutesEnv(key string) []CORSRouteConfig {
+	var routes []CORSRouteConfig
+	for _, entry := range splitList(os.Getenv(key), ";") {
+		prefix, origins, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(prefix) == "" {
+			continue
+		}
+		routes = append(routes, CORSRouteConfig{
+			PathPrefix:     strings.TrimSpace(prefix),
+			AllowedOrigins: strings.Fields(origins),
+		})
+	}
+	return routes
+}
+
+// getIntMapEnv parses "name=value" pairs such as "default=4,imports=1"
+func getIntMapEnv(key string, defaultValue map[string]int) map[string]int {
+	entries := splitList(os.Getenv(key), ",")
+	if len(entries) == 0 {
+		return defaultValue
+	}
+	values := make(map[string]int)
+	for _, entry := range entries {
+		name, raw, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if !ok || err != nil {
+			continue
+		}
+		values[strings.TrimSpace(name)] = n
+	}
+	return values
+}
+
+// getStringMapEnv parses "name=value" pairs separated by semicolons, so
+// values may contain commas and spaces as in cron expressions
+func getStringMapEnv(key string) map[string]string {
+	values := make(map[string]string)
+	for _, entry := range splitList(os.Getenv(key), ";") {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// getTenantOverridesEnv parses per-tenant settings such as
+// "acme=max_items:100,reservation_ttl:30m;globex=max_users:5". List
+// settings separate their values with "|".
+func getTenantOverridesEnv(key string) map[string]TenantOverrides {
+	overrides := make(map[string]TenantOverrides)
+	for tenant, settings := range getStringMapEnv(key) {
+		var o TenantOverrides
+		for _, setting := range splitList(settings, ",") {
+			name, raw, _ := strings.Cut(setting, ":")
+			raw = strings.TrimSpace(raw)
+			switch strings.TrimSpace(name) {
+			case "max_users":
+				o.MaxUsers, _ = strconv.Atoi(raw)
+			case "max_items":
+				o.MaxItems, _ = strconv.Atoi(raw)
+			case "reservation_ttl":
+				o.ReservationTTL, _ = time.ParseDuration(raw)
+			case "max_reservation_ttl":
+				o.MaxReservationTTL, _ = time.ParseDuration(raw)
+			case "attachment_max_size":
+				o.AttachmentMaxSize, _ = strconv.ParseInt(raw, 10, 64)
+			case "attachment_types":
+				o.AttachmentTypes = splitList(raw, "|")
+			}
+		}
+		overrides[tenant] = o
+	}
+	return overrides
+}
+
+// defaultInstanceID combines the host name and process ID so replicas on
+// the same host are distinct
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func splitList(value, sep string) []string {
+	var out []string
+	for _, part := range strings.Split(value, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseListeners parses a comma-separated list of listener URLs such as
+// "tcp://:8080,unix:///run/app.sock?mode=0660&h2c=true,fd://3".
+// TLS applies to tcp and fd listeners by default unless h2c is enabled.
+func parseListeners(value string, tlsEnabled bool) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+	for _, raw := range splitList(value, ",") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listener %q: %w", raw, err)
+		}
+
+		lc := ListenerConfig{Network: u.Scheme, Address: u.Host}
+		q := u.Query()
+
+		switch lc.Network {
+		case "tcp", "fd":
+		case "unix":
+			lc.Address = u.Path
+			lc.SocketMode = 0o660
+			if mode := q.Get("mode"); mode != "" {
+				m, err := strconv.ParseUint(mode, 8, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid socket mode %q for listener %q", mode, raw)
+				}
+				lc.SocketMode = os.FileMode(m)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported listener network %q", lc.Network)
+		}
+		if lc.Address == "" {
+			return nil, fmt.Errorf("listener %q has no address", raw)
+		}
+
+		if h2c := q.Get("h2c"); h2c != "" {
+			if lc.H2C, err = strconv.ParseBool(h2c); err != nil {
+				return nil, fmt.Errorf("invalid h2c flag for listener %q", raw)
+			}
+		}
+
+		lc.TLS = tlsEnabled && lc.Network != "unix" && !lc.H2C
+		if t := q.Get("tls"); t != "" {
+			if lc.TLS, err = strconv.ParseBool(t); err != nil {
+				return nil, fmt.Errorf("invalid tls flag for listener %q", raw)
+			}
+		}
+		if lc.TLS && lc.H2C {
+			return nil, fmt.Errorf("listener %q cannot combine tls and h2c", raw)
+		}
+
+		listeners = append(listeners, lc)
+	}
+	return listeners, nil
+}
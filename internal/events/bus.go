@@ -0,0 +1,247 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event actions
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+)
+
+const (
+	defaultBufferSize       = 1000
+	defaultSubscriberBuffer = 64
+)
+
+var (
+	// ErrSlowConsumer ends subscriptions that stop keeping up
+	ErrSlowConsumer = errors.New("subscriber too slow")
+	// ErrClosed is returned once the bus has shut down
+	ErrClosed = errors.New("event bus closed")
+)
+
+// Event is a change notification for a resource. DedupID is unique per
+// change and stays the same if the event is delivered more than once.
+// Tenant is set for changes to a tenant's resources.
+type Event struct {
+	ID         uint64          `json:"id"`
+	DedupID    string          `json:"dedup_id,omitempty"`
+	Type       string          `json:"type"`
+	Resource   string          `json:"resource"`
+	ResourceID string          `json:"resource_id"`
+	Action     string          `json:"action"`
+	Tenant     string          `json:"tenant,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// NewEvent builds an event for resource id with data encoded as JSON
+func NewEvent(resource string, id int64, action string, data interface{}) Event {
+	e := Event{
+		Type:       resource + "." + action,
+		Resource:   resource,
+		ResourceID: strconv.FormatInt(id, 10),
+		Action:     action,
+	}
+	if data != nil {
+		e.Data, _ = json.Marshal(data)
+	}
+	return e
+}
+
+// Filter selects events for a subscription. Empty sets match everything.
+// A non-empty Tenant only matches that tenant's events.
+type Filter struct {
+	Resources   map[string]bool
+	Actions     map[string]bool
+	ResourceIDs map[string]bool
+	Tenant      string
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Event) bool {
+	if f.Tenant != "" && e.Tenant != f.Tenant {
+		return false
+	}
+	if len(f.Resources) > 0 && !f.Resources[e.Resource] {
+		return false
+	}
+	if len(f.Actions) > 0 && !f.Actions[e.Action] {
+		return false
+	}
+	if len(f.ResourceIDs) > 0 && !f.ResourceIDs[e.ResourceID] {
+		return false
+	}
+	return true
+}
+
+// Subscription delivers events matching its filter. C is closed when the
+// subscriber falls too far behind or the bus shuts down; Err tells which.
+type Subscription struct {
+	// Replay holds buffered events after the requested resume point
+	Replay []Event
+	// Gap is set when the resume point is older than the buffer, so some
+	// events could not be replayed
+	Gap bool
+
+	C <-chan Event
+
+	bus    *Bus
+	ch     chan Event
+	filter Filter
+	err    error
+	once   sync.Once
+}
+
+// Err returns why the subscription ended, or nil while it is active
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.err
+}
+
+// Close unsubscribes
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s, nil)
+}
+
+// Bus fans out events to subscribers and keeps a bounded buffer of recent
+// events so clients can resume after reconnecting
+type Bus struct {
+	mu        sync.Mutex
+	buffer    []Event
+	start     int
+	size      int
+	nextID    uint64
+	subBuffer int
+	subs      map[*Subscription]struct{}
+	closed    bool
+}
+
+// NewBus creates a bus buffering bufferSize events and queueing up to
+// subscriberBuffer undelivered events per subscriber. Event IDs start at the
+// current time in microseconds, so they keep growing across restarts and
+// clients resuming from an earlier process are told about the gap.
+func NewBus(bufferSize, subscriberBuffer int) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	if subscriberBuffer <= 0 {
+		subscriberBuffer = defaultSubscriberBuffer
+	}
+	return &Bus{
+		buffer:    make([]Event, bufferSize),
+		nextID:    uint64(time.Now().UnixMicro()),
+		subBuffer: subscriberBuffer,
+		subs:      make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event its ID and timestamp and delivers it. Events
+// published after Close are dropped.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return e
+	}
+
+	e.ID = b.nextID
+	b.nextID++
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+
+	idx := (b.start + b.size) % len(b.buffer)
+	b.buffer[idx] = e
+	if b.size < len(b.buffer) {
+		b.size++
+	} else {
+		b.start = (b.start + 1) % len(b.buffer)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// Slow consumers are disconnected and resume from the buffer
+			b.remove(sub, ErrSlowConsumer)
+		}
+	}
+
+	return e
+}
+
+// Subscribe registers a subscriber. If lastEventID is non-zero, buffered
+// events after it that match the filter are returned in Replay.
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	ch := make(chan Event, b.subBuffer)
+	sub := &Subscription{C: ch, bus: b, ch: ch, filter: filter}
+
+	if lastEventID > 0 {
+		oldest := b.nextID
+		if b.size > 0 {
+			oldest = b.buffer[b.start].ID
+		}
+		// An ID the bus has not issued yet comes from another process
+		sub.Gap = lastEventID+1 < oldest || lastEventID >= b.nextID
+		for i := 0; i < b.size; i++ {
+			e := b.buffer[(b.start+i)%len(b.buffer)]
+			if e.ID > lastEventID && filter.Match(e) {
+				sub.Replay = append(sub.Replay, e)
+			}
+		}
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// LastID returns the ID of the most recently published event
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextID - 1
+}
+
+// Close ends every subscription and drops later events
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.remove(sub, ErrClosed)
+	}
+}
+
+// remove must be called with b.mu held
+func (b *Bus) remove(sub *Subscription, err error) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	sub.err = err
+	sub.once.Do(func() { close(sub.ch) })
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gostructure/app/pkg/response"
+)
+
+const redacted = "[REDACTED]"
+
+// BuildInfo returns version and toolchain information about the binary
+func (h *Handler) BuildInfo(w http.ResponseWriter, r *http.Request) {
+	info := map[string]interface{}{
+		"version":    h.config.App.BuildVersion,
+		"build_time": h.config.App.BuildTime,
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["module"] = bi.Main.Path
+		settings := make(map[string]string)
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				settings[s.Key] = s.Value
+			}
+		}
+		info["vcs"] = settings
+	}
+
+	response.JSON(w, http.StatusOK, info)
+}
+
+// ConfigDump returns the effective configuration with secrets redacted
+func (h *Handler) ConfigDump(w http.ResponseWriter, r *http.Request) {
+	cfg := *h.config
+	if cfg.Database.Password != "" {
+		cfg.Database.Password = redacted
+	}
+	if cfg.Alerts.WebhookSecret != "" {
+		cfg.Alerts.WebhookSecret = redacted
+	}
+	// Credentials embedded in the alert webhook URL, including the user
+	// name, which may itself be a token
+	if u, err := url.Parse(cfg.Alerts.WebhookURL); err == nil && u.User != nil {
+		u.User = url.User("xxxxx")
+		cfg.Alerts.WebhookURL = u.String()
+	}
+
+	response.JSON(w, http.StatusOK, cfg)
+}
+
+// ListSchedules returns the recurring tasks with their last and next runs
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules := h.scheduler.List()
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"schedules": schedules,
+		"total":     len(schedules),
+	})
+}
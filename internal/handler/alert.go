@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/notify"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+const defaultAlertTimeout = 10 * time.Second
+
+// ListAlerts returns alerts, optionally filtered by status and item
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := query.Get("status")
+	var itemID int64
+	if raw := query.Get("item_id"); raw != "" {
+		var err error
+		if itemID, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid item ID")
+			return
+		}
+	}
+
+	var alerts []model.Alert
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		alerts = store.Alerts.Filter(tx, func(a model.Alert) bool {
+			return (status == "" || a.Status == status) && (itemID == 0 || a.ItemID == itemID)
+		})
+		return nil
+	})
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"alerts": alerts,
+		"total":  len(alerts),
+	})
+}
+
+// GetAlert returns an alert
+func (h *Handler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid alert ID")
+		return
+	}
+
+	var alert model.Alert
+	var exists bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		alert, exists = store.Alerts.Get(tx, id)
+		return nil
+	})
+
+	if !exists {
+		response.Error(w, http.StatusNotFound, "Alert not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, alert)
+}
+
+// AlertAction handles POST /api/v1/alerts/{id}:acknowledge, recording who
+// has seen an open alert. Acknowledged alerts are still resolved once the
+// stock recovers.
+func (h *Handler) AlertAction(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := parseAction(w, r, "Alert")
+	if !ok {
+		return
+	}
+	if action != "acknowledge" {
+		response.Error(w, http.StatusNotFound, "Unknown action")
+		return
+	}
+
+	var before, alert model.Alert
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Alerts.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if before.Status != model.AlertOpen {
+			return errNotActive
+		}
+
+		now := time.Now().UTC()
+		alert = before
+		alert.Status = model.AlertAcknowledged
+		alert.AcknowledgedBy = actor(r)
+		alert.AcknowledgedAt = &now
+		var err error
+		if alert, err = store.Alerts.Save(tx, id, alert); err != nil {
+			return err
+		}
+		tx.Emit("alert", id, events.ActionUpdated, alert)
+		return recordAudit(tx, r, audit.ActionUpdate, "alert", id, before, alert)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Alert")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, alert)
+}
+
+// EvaluateStockAlerts raises an alert for each live item whose quantity is
+// below its reorder threshold and has no unresolved alert, and resolves the
+// alerts of items that recovered. New alerts are sent to the notification
+// channels. It returns how many alerts were raised.
+func (h *Handler) EvaluateStockAlerts(ctx context.Context) (int, error) {
+	var raised []model.Alert
+	var items map[int64]model.Item
+	err := h.store.UpdateAs(systemActor, func(tx *store.Tx) error {
+		unresolved := make(map[int64]model.Alert)
+		for _, a := range store.Alerts.Filter(tx, func(a model.Alert) bool { return a.Status != model.AlertResolved }) {
+			unresolved[a.ItemID] = a
+		}
+
+		items = make(map[int64]model.Item)
+		for _, item := range store.Items.List(tx) {
+			low := item.DeletedAt == nil && item.ReorderThreshold > 0 && item.Quantity < item.ReorderThreshold
+			alert, alerted := unresolved[item.ID]
+			delete(unresolved, item.ID)
+			switch {
+			case low && !alerted:
+				alert, err := store.Alerts.Insert(tx, func(id int64) model.Alert {
+					return model.Alert{
+						ID:        id,
+						ItemID:    item.ID,
+						Type:      model.AlertLowStock,
+						Status:    model.AlertOpen,
+						Quantity:  item.Quantity,
+						Threshold: item.ReorderThreshold,
+						Meta:      model.Meta{Tenant: item.Tenant},
+					}
+				})
+				if err != nil {
+					return err
+				}
+				tx.Emit("alert", alert.ID, events.ActionCreated, alert)
+				raised = append(raised, alert)
+				items[item.ID] = item
+			case !low && alerted:
+				if err := resolveAlert(tx, alert); err != nil {
+					return err
+				}
+			}
+		}
+		// Alerts of purged items have nothing left to recover
+		for _, alert := range unresolved {
+			if err := resolveAlert(tx, alert); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := h.config.Alerts.Timeout
+	if timeout <= 0 {
+		timeout = defaultAlertTimeout
+	}
+	for _, alert := range raised {
+		item := items[alert.ItemID]
+		sendCtx, cancel := context.WithTimeout(ctx, timeout)
+		notify.Send(sendCtx, h.notifiers, notify.Notification{
+			Kind:    "alert." + alert.Type,
+			Subject: fmt.Sprintf("Low stock: %s", item.Name),
+			Text: fmt.Sprintf("Item %d (%s) has %d in stock, below its reorder threshold of %d.",
+				item.ID, item.Name, alert.Quantity, alert.Threshold),
+			Data: alert,
+		})
+		cancel()
+	}
+	return len(raised), nil
+}
+
+func resolveAlert(tx *store.Tx, alert model.Alert) error {
+	now := time.Now().UTC()
+	alert.Status = model.AlertResolved
+	alert.ResolvedAt = &now
+	alert, err := store.Alerts.Save(tx, alert.ID, alert)
+	if err != nil {
+		return err
+	}
+	tx.Emit("alert", alert.ID, events.ActionUpdated, alert)
+	return nil
+}
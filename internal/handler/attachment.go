@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+	"github.com/gostructure/app/pkg/thumbnail"
+)
+
+const (
+	defaultMaxAttachmentSize = 8 << 20
+	defaultThumbnailSize     = 256
+)
+
+var (
+	// defaultAttachmentTypes are accepted when no types are configured
+	defaultAttachmentTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"}
+	// thumbnailTypes are the image types thumbnails can be generated for
+	thumbnailTypes = []string{"image/png", "image/jpeg", "image/gif"}
+)
+
+// ListAttachments returns the attachments of an item
+func (h *Handler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	var item model.Item
+	var exists bool
+	var attachments []model.Attachment
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		item, exists = store.Items.Get(tx, id)
+		attachments = itemAttachments(tx, id)
+		return nil
+	})
+
+	if !exists || item.DeletedAt != nil {
+		response.Error(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"attachments": attachments,
+		"total":       len(attachments),
+	})
+}
+
+// UploadAttachment attaches the file in the multipart field "file" to an
+// item. Uploading contents the item already has returns the existing
+// attachment.
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+	cfg := h.tenantConfig(r).Attachments
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxAttachmentSize
+	}
+
+	filename, data, ok := readUpload(w, r, maxSize)
+	if !ok {
+		return
+	}
+
+	// The content type is sniffed rather than trusted from the client
+	contentType := http.DetectContentType(data)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	allowed := cfg.AllowedTypes
+	if len(allowed) == 0 {
+		allowed = defaultAttachmentTypes
+	}
+	if !slices.Contains(allowed, mediaType) {
+		response.Error(w, http.StatusUnsupportedMediaType, "Unsupported file type "+mediaType)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	attachment := model.Attachment{
+		ItemID:      id,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Checksum:    hex.EncodeToString(sum[:]),
+	}
+	var thumb []byte
+	if slices.Contains(thumbnailTypes, mediaType) {
+		thumb = h.describeImage(&attachment, data)
+	}
+
+	h.blobMu.Lock()
+	defer h.blobMu.Unlock()
+
+	if err := h.blobs.Put(attachment.Checksum, bytes.NewReader(data)); err != nil {
+		log.Printf("Failed to store attachment: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+	if thumb != nil {
+		if err := h.blobs.Put(attachment.ThumbnailChecksum, bytes.NewReader(thumb)); err != nil {
+			log.Printf("Failed to store thumbnail: %v", err)
+			response.Error(w, http.StatusInternalServerError, "Failed to store attachment")
+			return
+		}
+	}
+
+	created := false
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		item, exists := store.Items.Get(tx, id)
+		if !exists || item.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, item); err != nil {
+			return err
+		}
+		existing := itemAttachments(tx, id)
+		if i := slices.IndexFunc(existing, func(a model.Attachment) bool {
+			return a.Checksum == attachment.Checksum
+		}); i >= 0 {
+			attachment = existing[i]
+			return nil
+		}
+
+		var err error
+		attachment, err = store.Attachments.Insert(tx, func(aid int64) model.Attachment {
+			a := attachment
+			a.ID = aid
+			return a
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("attachment", attachment.ID, events.ActionCreated, attachment)
+		created = true
+		return recordAudit(tx, r, audit.ActionCreate, "attachment", attachment.ID, nil, attachment)
+	})
+	if err != nil {
+		h.deleteUnreferencedBlobs(attachment.Checksum, attachment.ThumbnailChecksum)
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	if !created {
+		response.JSON(w, http.StatusOK, attachment)
+		return
+	}
+	response.JSON(w, http.StatusCreated, attachment)
+}
+
+// DownloadAttachment serves the contents of an attachment, honouring range
+// and conditional requests
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	h.serveAttachment(w, r, false)
+}
+
+// AttachmentThumbnail serves the preview of an image attachment
+func (h *Handler) AttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveAttachment(w, r, true)
+}
+
+func (h *Handler) serveAttachment(w http.ResponseWriter, r *http.Request, thumb bool) {
+	attachment, ok := h.findAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	key, contentType := attachment.Checksum, attachment.ContentType
+	if thumb {
+		if attachment.ThumbnailChecksum == "" {
+			response.Error(w, http.StatusNotFound, "Attachment has no thumbnail")
+			return
+		}
+		key, contentType = attachment.ThumbnailChecksum, attachment.ThumbnailContentType
+	}
+
+	f, err := h.blobs.Open(key)
+	if err != nil {
+		log.Printf("Failed to open attachment %d: %v", attachment.ID, err)
+		response.Error(w, http.StatusInternalServerError, "Failed to read attachment")
+		return
+	}
+	defer f.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	header.Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, r, "", attachment.CreatedAt, f)
+}
+
+// DeleteAttachment removes an attachment. Its contents are deleted once no
+// other attachment shares them.
+func (h *Handler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := h.findAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		item, exists := store.Items.Get(tx, attachment.ItemID)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, item); err != nil {
+			return err
+		}
+		if !store.Attachments.Delete(tx, attachment.ID) {
+			return store.ErrNotFound
+		}
+		tx.Emit("attachment", attachment.ID, events.ActionDeleted, nil)
+		return recordAudit(tx, r, audit.ActionDelete, "attachment", attachment.ID, attachment, nil)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Attachment")
+		return
+	}
+	h.removeUnreferencedBlobs(attachment.Checksum, attachment.ThumbnailChecksum)
+
+	response.JSON(w, http.StatusOK, map[string]string{
+		"message": "Attachment deleted successfully",
+	})
+}
+
+// findAttachment looks up the attachment named by the request path, writing
+// a 404 unless it belongs to the item in the path and the item is live
+func (h *Handler) findAttachment(w http.ResponseWriter, r *http.Request) (model.Attachment, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return model.Attachment{}, false
+	}
+	aid, err := strconv.ParseInt(r.PathValue("aid"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid attachment ID")
+		return model.Attachment{}, false
+	}
+
+	var attachment model.Attachment
+	var found bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		item, exists := store.Items.Get(tx, id)
+		attachment, found = store.Attachments.Get(tx, aid)
+		found = found && exists && item.DeletedAt == nil && attachment.ItemID == id
+		return nil
+	})
+
+	if !found {
+		response.Error(w, http.StatusNotFound, "Attachment not found")
+		return model.Attachment{}, false
+	}
+	return attachment, true
+}
+
+// describeImage records the dimensions of an image attachment and returns
+// its thumbnail, or nil if none could be made
+func (h *Handler) describeImage(attachment *model.Attachment, data []byte) []byte {
+	width, height, err := thumbnail.Dimensions(data)
+	if err != nil {
+		return nil
+	}
+	attachment.Width, attachment.Height = width, height
+
+	size := h.config.Attachments.ThumbnailSize
+	if size <= 0 {
+		size = defaultThumbnailSize
+	}
+	thumb, contentType, err := thumbnail.Generate(data, size)
+	if err != nil {
+		log.Printf("Not generating thumbnail for %s: %v", attachment.Filename, err)
+		return nil
+	}
+	sum := sha256.Sum256(thumb)
+	attachment.ThumbnailChecksum = hex.EncodeToString(sum[:])
+	attachment.ThumbnailContentType = contentType
+	return thumb
+}
+
+// removeUnreferencedBlobs deletes the blobs under keys that no attachment
+// refers to
+func (h *Handler) removeUnreferencedBlobs(keys ...string) {
+	h.blobMu.Lock()
+	defer h.blobMu.Unlock()
+	h.deleteUnreferencedBlobs(keys...)
+}
+
+// deleteUnreferencedBlobs is removeUnreferencedBlobs for callers holding
+// blobMu
+func (h *Handler) deleteUnreferencedBlobs(keys ...string) {
+	// Contents are shared by identical uploads of every tenant
+	referenced := make(map[string]bool)
+	h.store.View(func(tx *store.Tx) error {
+		for _, a := range store.Attachments.List(tx) {
+			referenced[a.Checksum] = true
+			referenced[a.ThumbnailChecksum] = true
+		}
+		return nil
+	})
+	for _, key := range keys {
+		if key == "" || referenced[key] {
+			continue
+		}
+		if err := h.blobs.Delete(key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// readUpload reads the part named "file" of a multipart request, writing an
+// error response if there is none or it is larger than maxSize
+func readUpload(w http.ResponseWriter, r *http.Request, maxSize int64) (string, []byte, bool) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Expected a multipart/form-data body")
+		return "", nil, false
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			response.Error(w, http.StatusBadRequest, "File is required")
+			return "", nil, false
+		}
+		if err != nil {
+			writeUploadError(w, err)
+			return "", nil, false
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxSize+1))
+		part.Close()
+		if err != nil {
+			writeUploadError(w, err)
+			return "", nil, false
+		}
+		if int64(len(data)) > maxSize {
+			response.Error(w, http.StatusRequestEntityTooLarge, "File larger than "+strconv.FormatInt(maxSize, 10)+" bytes")
+			return "", nil, false
+		}
+		if len(data) == 0 {
+			response.Error(w, http.StatusBadRequest, "File is empty")
+			return "", nil, false
+		}
+
+		filename := part.FileName()
+		if filename == "" {
+			filename = "file"
+		}
+		return filename, data, true
+	}
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		response.Error(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	response.Error(w, http.StatusBadRequest, "Invalid multipart body")
+}
+
+// itemAttachments returns the attachments of an item
+func itemAttachments(tx *store.Tx, itemID int64) []model.Attachment {
+	return store.Attachments.Filter(tx, func(a model.Attachment) bool { return a.ItemID == itemID })
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// ListAudit returns audit entries filtered by actor, resource and time
+// range. With tenancy enabled only the request tenant's entries are listed.
+func (h *Handler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	q := r.URL.Query()
+	filter := audit.Filter{
+		Actor:      q.Get("actor"),
+		Tenant:     tenant(r),
+		Resource:   q.Get("resource"),
+		ResourceID: q.Get("resource_id"),
+		Limit:      defaultAuditLimit,
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid since timestamp")
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid until timestamp")
+			return
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		if filter.AfterSeq, err = strconv.ParseUint(v, 10, 64); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid after cursor")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxAuditLimit {
+			response.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	h.flushAudit()
+	entries := h.audit.Query(filter)
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
+
+// VerifyAudit checks the audit log hash chain
+func (h *Handler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	h.flushAudit()
+	if err := h.audit.Verify(); err != nil {
+		response.JSON(w, http.StatusConflict, map[string]interface{}{
+			"valid": false,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"valid":   true,
+		"entries": h.audit.Len(),
+	})
+}
+
+// recordAudit stages an audit entry for a mutation in tx, so the entry is
+// committed together with the change. before and after are snapshots of the
+// resource; either may be nil for creates and deletes.
+func recordAudit(tx *store.Tx, r *http.Request, action, resource string, id int64, before, after interface{}) error {
+	return stageAudit(tx, tenant(r), actor(r), middleware.GetRequestID(r.Context()), action, resource, id, before, after)
+}
+
+// appendAudit stages an audit entry for a mutation made outside a request,
+// such as by a job, for the tenant of the changed resource
+func appendAudit(tx *store.Tx, actor, requestID, action, resource string, id int64, before, after interface{}) error {
+	var tenant string
+	for _, row := range []interface{}{after, before} {
+		if t, ok := row.(interface{ TenantID() string }); ok && t.TenantID() != "" {
+			tenant = t.TenantID()
+			break
+		}
+	}
+	return stageAudit(tx, tenant, actor, requestID, action, resource, id, before, after)
+}
+
+func stageAudit(tx *store.Tx, tenant, actor, requestID, action, resource string, id int64, before, after interface{}) error {
+	entry := audit.Entry{
+		Actor:      actor,
+		Tenant:     tenant,
+		RequestID:  requestID,
+		Action:     action,
+		Resource:   resource,
+		ResourceID: strconv.FormatInt(id, 10),
+	}
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		entry.After, _ = json.Marshal(after)
+	}
+	entry.Changes = audit.Diff(entry.Before, entry.After)
+	return audit.Stage(tx, entry)
+}
+
+// flushAudit moves committed audit entries from the store to the audit log
+// before it is read, so reads include every committed change
+func (h *Handler) flushAudit() {
+	if err := h.audit.Flush(h.store); err != nil {
+		log.Printf("Failed to flush audit entries: %v", err)
+	}
+}
@@ -0,0 +1,447 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+var (
+	errSlugTaken = errors.New("slug is already in use")
+	errCycle     = errors.New("category cycle")
+	errNotEmpty  = errors.New("category is not empty")
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 50
+)
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// numericPattern matches refs that findCategory reads as IDs
+	numericPattern = regexp.MustCompile(`^[0-9]+$`)
+)
+
+const slugMessage = "Slug must be lowercase letters, digits and single hyphens, and not only digits"
+
+// categoryCount is a category with the number of live items in it and its
+// subcategories
+type categoryCount struct {
+	model.Category
+	ItemCount int `json:"item_count"`
+}
+
+// ListCategories returns all categories with their item counts, or only the
+// children of parent_id (0 for the roots)
+func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	parentID := int64(-1)
+	if raw := r.URL.Query().Get("parent_id"); raw != "" {
+		var err error
+		if parentID, err = strconv.ParseInt(raw, 10, 64); err != nil || parentID < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid parent ID")
+			return
+		}
+	}
+
+	list := make([]categoryCount, 0)
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		counts := categoryItemCounts(tx)
+		for _, c := range store.Categories.List(tx) {
+			if parentID < 0 || c.ParentID == parentID {
+				list = append(list, categoryCount{Category: c, ItemCount: counts[c.ID]})
+			}
+		}
+		return nil
+	})
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"categories": list,
+		"total":      len(list),
+	})
+}
+
+// GetCategory returns a category by ID or slug with its item count
+func (h *Handler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	var category model.Category
+	var exists bool
+	var count int
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		category, exists = findCategory(tx, r.PathValue("id"))
+		count = categoryItemCounts(tx)[category.ID]
+		return nil
+	})
+
+	if !exists {
+		response.Error(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, categoryCount{Category: category, ItemCount: count})
+}
+
+// CreateCategory creates a category, at the root or under parent_id
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateCategoryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Name == "" {
+		response.Error(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	slug, ok := categorySlug(w, req.Slug, req.Name)
+	if !ok {
+		return
+	}
+
+	var category model.Category
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		if _, exists := store.Categories.Get(tx, req.ParentID); req.ParentID != 0 && !exists {
+			return invalidError("Parent category not found")
+		}
+		if slugInUse(tx, slug, 0) {
+			return errSlugTaken
+		}
+
+		var err error
+		category, err = store.Categories.Insert(tx, func(id int64) model.Category {
+			return model.Category{ID: id, Name: req.Name, Slug: slug, ParentID: req.ParentID}
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("category", category.ID, events.ActionCreated, category)
+		return recordAudit(tx, r, audit.ActionCreate, "category", category.ID, nil, category)
+	})
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, category)
+}
+
+// UpdateCategory renames a category or changes its slug
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var req model.UpdateCategoryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Slug != "" && !validSlug(req.Slug) {
+		response.Error(w, http.StatusBadRequest, slugMessage)
+		return
+	}
+
+	var before, category model.Category
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Categories.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+
+		category = before
+		if req.Name != "" {
+			category.Name = req.Name
+		}
+		if req.Slug != "" {
+			if slugInUse(tx, req.Slug, id) {
+				return errSlugTaken
+			}
+			category.Slug = req.Slug
+		}
+
+		var err error
+		if category, err = store.Categories.Save(tx, id, category); err != nil {
+			return err
+		}
+		tx.Emit("category", id, events.ActionUpdated, category)
+		return recordAudit(tx, r, audit.ActionUpdate, "category", id, before, category)
+	})
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, category)
+}
+
+// DeleteCategory deletes a category that has no subcategories or live
+// items. Deleted items in it are unassigned.
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var before model.Category
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Categories.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		children := store.Categories.Filter(tx, func(c model.Category) bool { return c.ParentID == id })
+		items := store.Items.Filter(tx, func(i model.Item) bool { return i.CategoryID == id })
+		if len(children) > 0 || slices.ContainsFunc(items, func(i model.Item) bool { return i.DeletedAt == nil }) {
+			return errNotEmpty
+		}
+
+		for _, item := range items {
+			item.CategoryID = 0
+			if err := store.Items.Put(tx, item.ID, item); err != nil {
+				return err
+			}
+		}
+		store.Categories.Delete(tx, id)
+		tx.Emit("category", id, events.ActionDeleted, nil)
+		return recordAudit(tx, r, audit.ActionDelete, "category", id, before, nil)
+	})
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{
+		"message": "Category deleted successfully",
+	})
+}
+
+// CategoryAction handles POST /api/v1/categories/{id}:move, which moves a
+// category and its subtree under another parent
+func (h *Handler) CategoryAction(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := parseAction(w, r, "Category")
+	if !ok {
+		return
+	}
+	if action != "move" {
+		response.Error(w, http.StatusNotFound, "Unknown action")
+		return
+	}
+
+	var req model.MoveCategoryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var before, category model.Category
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Categories.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		// Walk up from the new parent; reaching the category would make it
+		// its own ancestor
+		for parent := req.ParentID; parent != 0; {
+			if parent == id {
+				return errCycle
+			}
+			c, exists := store.Categories.Get(tx, parent)
+			if !exists {
+				return invalidError("Parent category not found")
+			}
+			parent = c.ParentID
+		}
+
+		category = before
+		category.ParentID = req.ParentID
+		var err error
+		if category, err = store.Categories.Save(tx, id, category); err != nil {
+			return err
+		}
+		tx.Emit("category", id, events.ActionUpdated, category)
+		return recordAudit(tx, r, audit.ActionUpdate, "category", id, before, category)
+	})
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, category)
+}
+
+// SetItemCategory assigns an item to a category
+func (h *Handler) SetItemCategory(w http.ResponseWriter, r *http.Request) {
+	var req model.SetItemCategoryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	h.modifyItem(w, r, func(tx *store.Tx, item *model.Item) error {
+		if _, exists := store.Categories.Get(tx, req.CategoryID); req.CategoryID != 0 && !exists {
+			return invalidError("Category not found")
+		}
+		item.CategoryID = req.CategoryID
+		return nil
+	})
+}
+
+// SetItemTags replaces the tags of an item
+func (h *Handler) SetItemTags(w http.ResponseWriter, r *http.Request) {
+	h.changeItemTags(w, r, func(_, tags []string) []string { return tags })
+}
+
+// AddItemTags adds tags to an item
+func (h *Handler) AddItemTags(w http.ResponseWriter, r *http.Request) {
+	h.changeItemTags(w, r, func(current, tags []string) []string {
+		return append(slices.Clone(current), tags...)
+	})
+}
+
+// RemoveItemTag removes one tag from an item
+func (h *Handler) RemoveItemTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.PathValue("tag"))
+	h.modifyItem(w, r, func(_ *store.Tx, item *model.Item) error {
+		item.Tags = slices.DeleteFunc(slices.Clone(item.Tags), func(t string) bool { return t == tag })
+		return nil
+	})
+}
+
+func (h *Handler) changeItemTags(w http.ResponseWriter, r *http.Request, change func(current, tags []string) []string) {
+	var req model.ItemTagsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	for _, tag := range req.Tags {
+		if tag = strings.TrimSpace(tag); tag == "" || len(tag) > maxTagLength {
+			response.Error(w, http.StatusBadRequest, "Tags must be 1 to "+strconv.Itoa(maxTagLength)+" characters")
+			return
+		}
+	}
+
+	h.modifyItem(w, r, func(_ *store.Tx, item *model.Item) error {
+		tags := normalizeTags(change(item.Tags, req.Tags))
+		if len(tags) > maxTags {
+			return invalidError("Items can have at most " + strconv.Itoa(maxTags) + " tags")
+		}
+		item.Tags = tags
+		return nil
+	})
+}
+
+// writeCategoryError writes the response for a failed category transaction
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errSlugTaken):
+		response.Error(w, http.StatusConflict, "Slug is already in use")
+	case errors.Is(err, errCycle):
+		response.Error(w, http.StatusConflict, "Cannot move a category into its own subtree")
+	case errors.Is(err, errNotEmpty):
+		response.Error(w, http.StatusConflict, "Category has subcategories or items")
+	default:
+		writeStoreError(w, err, "Category")
+	}
+}
+
+// categorySlug validates slug, or derives one from name if it is empty
+func categorySlug(w http.ResponseWriter, slug, name string) (string, bool) {
+	if slug == "" {
+		slug = slugify(name)
+	}
+	if !validSlug(slug) {
+		response.Error(w, http.StatusBadRequest, slugMessage)
+		return "", false
+	}
+	return slug, true
+}
+
+// validSlug reports whether slug is well formed. All-digit slugs are
+// rejected since they would be looked up as IDs.
+func validSlug(slug string) bool {
+	return slugPattern.MatchString(slug) && !numericPattern.MatchString(slug)
+}
+
+// slugify lowercases name and joins its runs of ASCII letters and digits
+// with hyphens
+func slugify(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+func slugInUse(tx *store.Tx, slug string, except int64) bool {
+	return len(store.Categories.Filter(tx, func(c model.Category) bool {
+		return c.Slug == slug && c.ID != except
+	})) > 0
+}
+
+// findCategory looks a category up by ID or slug
+func findCategory(tx *store.Tx, ref string) (model.Category, bool) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return store.Categories.Get(tx, id)
+	}
+	matches := store.Categories.Filter(tx, func(c model.Category) bool { return c.Slug == ref })
+	if len(matches) == 0 {
+		return model.Category{}, false
+	}
+	return matches[0], true
+}
+
+// categorySubtree returns the IDs of root and all categories below it
+func categorySubtree(tx *store.Tx, root int64) map[int64]bool {
+	children := make(map[int64][]int64)
+	for _, c := range store.Categories.List(tx) {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+	}
+	subtree := map[int64]bool{root: true}
+	for queue := []int64{root}; len(queue) > 0; queue = queue[1:] {
+		for _, child := range children[queue[0]] {
+			subtree[child] = true
+			queue = append(queue, child)
+		}
+	}
+	return subtree
+}
+
+// categoryItemCounts counts the live items in each category and its
+// subcategories
+func categoryItemCounts(tx *store.Tx) map[int64]int {
+	parents := make(map[int64]int64)
+	for _, c := range store.Categories.List(tx) {
+		parents[c.ID] = c.ParentID
+	}
+	counts := make(map[int64]int)
+	for _, item := range store.Items.Filter(tx, func(i model.Item) bool { return i.DeletedAt == nil }) {
+		for id := item.CategoryID; id != 0; id = parents[id] {
+			counts[id]++
+		}
+	}
+	return counts
+}
+
+// normalizeTags lowercases and trims tags and returns them sorted without
+// duplicates
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(tag)))
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/pkg/response"
+	"github.com/gostructure/app/pkg/websocket"
+)
+
+const (
+	defaultHeartbeatInterval = 15 * time.Second
+	// streamWriteTimeout disconnects clients that stop reading
+	streamWriteTimeout = 10 * time.Second
+)
+
+// subscribeMessage changes the filter of a WebSocket subscription
+type subscribeMessage struct {
+	Action    string   `json:"action"`
+	Resources []string `json:"resources"`
+	Actions   []string `json:"actions"`
+	IDs       []string `json:"ids"`
+}
+
+// controlMessage is sent to WebSocket clients alongside events
+type controlMessage struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	LastID  uint64 `json:"last_event_id,omitempty"`
+}
+
+// StreamEvents streams change events as Server-Sent Events
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	filter := parseEventFilter(r)
+
+	lastID, err := parseLastEventID(r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+		return
+	}
+
+	sub, err := h.events.Subscribe(filter, lastID)
+	if err != nil {
+		response.Error(w, http.StatusServiceUnavailable, "Event stream unavailable")
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...interface{}) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	writeEvent := func(e events.Event) bool {
+		data, _ := json.Marshal(e)
+		return write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	}
+
+	if !write("retry: 3000\n\n") {
+		return
+	}
+	if sub.Gap && !write("event: reset\ndata: {\"last_event_id\":%d}\n\n", h.events.LastID()) {
+		return
+	}
+	for _, e := range sub.Replay {
+		if !writeEvent(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeatInterval())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				write("event: error\ndata: {\"message\":%q}\n\n", errMessage(sub.Err()))
+				return
+			}
+			if !writeEvent(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// EventsWebSocket streams change events over a WebSocket. Clients may send
+// {"action":"subscribe",...} messages to change their filter.
+func (h *Handler) EventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter := parseEventFilter(r)
+
+	lastID, err := parseLastEventID(r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid last_event_id")
+		return
+	}
+	// Without a resume point, resubscribing replays from where the stream
+	// started so events published in between are not lost
+	if lastID == 0 {
+		lastID = h.events.LastID()
+	}
+
+	sub, err := h.events.Subscribe(filter, lastID)
+	if err != nil {
+		response.Error(w, http.StatusServiceUnavailable, "Event stream unavailable")
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		sub.Close()
+		return
+	}
+	defer conn.Close(websocket.CloseGoingAway, "")
+
+	// Read client messages until the connection closes or the handler
+	// returns
+	messages := make(chan subscribeMessage)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg subscribeMessage
+			if json.Unmarshal(data, &msg) != nil || msg.Action != "subscribe" {
+				h.sendWS(conn, controlMessage{Type: "error", Message: "unsupported message"})
+				continue
+			}
+			select {
+			case messages <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(h.heartbeatInterval())
+	defer heartbeat.Stop()
+
+	deliver := func(sub *events.Subscription) bool {
+		if sub.Gap && !h.sendWS(conn, controlMessage{Type: "reset", LastID: h.events.LastID()}) {
+			return false
+		}
+		for _, e := range sub.Replay {
+			if !h.sendWS(conn, e) {
+				return false
+			}
+			lastID = e.ID
+		}
+		return true
+	}
+
+	defer func() { sub.Close() }()
+	if !deliver(sub) {
+		return
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-messages:
+			// Resubscribe from the last delivered event so nothing is lost
+			sub.Close()
+			filter = filterFromLists(msg.Resources, msg.Actions, msg.IDs)
+			filter.Tenant = tenant(r)
+			sub, err = h.events.Subscribe(filter, lastID)
+			if err != nil {
+				conn.Close(websocket.CloseGoingAway, "event stream closed")
+				return
+			}
+			if !h.sendWS(conn, controlMessage{Type: "subscribed", LastID: lastID}) || !deliver(sub) {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				code := websocket.CloseGoingAway
+				if sub.Err() == events.ErrSlowConsumer {
+					code = websocket.CloseTryAgainLater
+				}
+				conn.Close(code, errMessage(sub.Err()))
+				return
+			}
+			if !h.sendWS(conn, e) {
+				return
+			}
+			lastID = e.ID
+		case <-heartbeat.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if conn.Ping() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *Handler) sendWS(conn *websocket.Conn, v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return conn.WriteText(data) == nil
+}
+
+func (h *Handler) heartbeatInterval() time.Duration {
+	if h.config.Events.HeartbeatInterval > 0 {
+		return h.config.Events.HeartbeatInterval
+	}
+	return defaultHeartbeatInterval
+}
+
+// parseEventFilter returns the filter in the query, limited to the request
+// tenant's events
+func parseEventFilter(r *http.Request) events.Filter {
+	q := r.URL.Query()
+	filter := filterFromLists(splitQuery(q.Get("resources")), splitQuery(q.Get("actions")), splitQuery(q.Get("ids")))
+	filter.Tenant = tenant(r)
+	return filter
+}
+
+func filterFromLists(resources, actions, ids []string) events.Filter {
+	return events.Filter{
+		Resources:   toSet(resources),
+		Actions:     toSet(actions),
+		ResourceIDs: toSet(ids),
+	}
+}
+
+func parseLastEventID(r *http.Request) (uint64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+func splitQuery(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func errMessage(err error) string {
+	if err == nil {
+		return "stream closed"
+	}
+	return err.Error()
+}
@@ -1,17 +1,186 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/blob"
 	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/notify"
+	"github.com/gostructure/app/internal/scheduler"
+	"github.com/gostructure/app/internal/search"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/internal/webhook"
+	"github.com/gostructure/app/pkg/money"
+	"github.com/gostructure/app/pkg/response"
 )
 
 // Handler contains all HTTP handlers
 type Handler struct {
-	config *config.Config
+	config    *config.Config
+	store     *store.Store
+	audit     *audit.Log
+	events    *events.Bus
+	webhooks  *webhook.Dispatcher
+	jobs      *jobs.Manager
+	scheduler *scheduler.Scheduler
+	search    *search.Index
+	blobs     blob.Store
+	notifiers []notify.Channel
+	checks    []readinessCheck
+
+	// blobMu orders uploads against the removal of unreferenced blobs
+	blobMu sync.Mutex
+}
+
+// Services are the dependencies shared by the handlers
+type Services struct {
+	Store     *store.Store
+	Audit     *audit.Log
+	Events    *events.Bus
+	Webhooks  *webhook.Dispatcher
+	Jobs      *jobs.Manager
+	Scheduler *scheduler.Scheduler
+	Search    *search.Index
+	Blobs     blob.Store
+	Notifiers []notify.Channel
+}
+
+type readinessCheck struct {
+	name  string
+	check func() error
 }
 
 // New creates a new Handler instance
-func New(cfg *config.Config) *Handler {
+func New(cfg *config.Config, services Services) *Handler {
 	return &Handler{
-		config: cfg,
+		config:    cfg,
+		store:     services.Store,
+		audit:     services.Audit,
+		events:    services.Events,
+		webhooks:  services.Webhooks,
+		jobs:      services.Jobs,
+		scheduler: services.Scheduler,
+		search:    services.Search,
+		blobs:     services.Blobs,
+		notifiers: services.Notifiers,
+	}
+}
+
+// AddReadinessCheck registers a check consulted by the readiness endpoint
+func (h *Handler) AddReadinessCheck(name string, check func() error) {
+	h.checks = append(h.checks, readinessCheck{name: name, check: check})
+}
+
+// decodeJSON decodes the request body into v and writes an error response
+// if the body is malformed, too large or arrived too slowly
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		response.Error(w, http.StatusRequestEntityTooLarge, "Request body too large")
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		response.Error(w, http.StatusRequestTimeout, "Request body read timed out")
+	case errors.Is(err, money.ErrCurrency), errors.Is(err, money.ErrScale), errors.Is(err, money.ErrOverflow):
+		response.Error(w, http.StatusBadRequest, "Invalid amount: "+err.Error())
+	default:
+		response.Error(w, http.StatusBadRequest, "Invalid request body")
+	}
+	return false
+}
+
+// writeStoreError writes the response for a failed store transaction on
+// resource
+func writeStoreError(w http.ResponseWriter, err error, resource string) {
+	if errors.Is(err, store.ErrNotFound) {
+		response.Error(w, http.StatusNotFound, resource+" not found")
+		return
+	}
+	if errors.Is(err, errNotDeleted) {
+		response.Error(w, http.StatusConflict, resource+" is not deleted")
+		return
+	}
+	if errors.Is(err, errInsufficientStock) {
+		response.Error(w, http.StatusConflict, "Insufficient stock")
+		return
+	}
+	var invalid invalidError
+	if errors.As(err, &invalid) {
+		response.Error(w, http.StatusBadRequest, string(invalid))
+		return
+	}
+	if errors.Is(err, errNotActive) {
+		response.Error(w, http.StatusConflict, resource+" is not active")
+		return
+	}
+	if errors.Is(err, errQuotaExceeded) {
+		response.Error(w, http.StatusForbidden, resource+" quota exceeded")
+		return
+	}
+	if errors.Is(err, errNotOwner) {
+		response.Error(w, http.StatusForbidden, resource+" belongs to another user")
+		return
+	}
+	if errors.Is(err, errNotSelf) {
+		response.Error(w, http.StatusForbidden, resource+" can only be changed by that user or an admin")
+		return
+	}
+	if errors.Is(err, errOwnsItems) {
+		response.Error(w, http.StatusConflict, resource+" still owns items")
+		return
+	}
+	if errors.Is(err, errSubjectTaken) {
+		response.Error(w, http.StatusConflict, "Subject is already in use")
+		return
+	}
+	log.Printf("Store transaction on %s failed: %v", strings.ToLower(resource), err)
+	response.Error(w, http.StatusInternalServerError, "Failed to save "+strings.ToLower(resource))
+}
+
+// actor returns the subject of the request principal, recorded as the
+// author of changes
+func actor(r *http.Request) string {
+	return middleware.GetPrincipal(r.Context()).Subject
+}
+
+// tenant returns the tenant the request is served for, or "" when tenancy
+// is disabled
+func tenant(r *http.Request) string {
+	return middleware.GetTenant(r.Context())
+}
+
+// tenantStore returns the store scoped to the request tenant. Request
+// handlers use it for every transaction so they cannot reach the rows of
+// other tenants.
+func (h *Handler) tenantStore(r *http.Request) store.Scope {
+	return h.store.Scoped(tenant(r))
+}
+
+// tenantConfig returns the configuration with the request tenant's
+// overrides applied
+func (h *Handler) tenantConfig(r *http.Request) *config.Config {
+	return h.config.ForTenant(tenant(r))
+}
+
+// requireRole writes a 403 response unless the principal holds role
+func requireRole(w http.ResponseWriter, r *http.Request, role string) bool {
+	if !middleware.GetPrincipal(r.Context()).HasRole(role) {
+		response.Error(w, http.StatusForbidden, "Insufficient permissions")
+		return false
 	}
+	return true
 }
@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 
+	"github.com/gostructure/app/internal/middleware"
 	"github.com/gostructure/app/pkg/response"
 )
 
@@ -15,7 +16,21 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 
 // Ready handles readiness check requests
 func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
-	// Add database connectivity check here if needed
+	failures := make(map[string]string)
+	for _, c := range h.checks {
+		if err := c.check(); err != nil {
+			failures[c.name] = err.Error()
+		}
+	}
+
+	if len(failures) > 0 {
+		response.JSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "not ready",
+			"checks": failures,
+		})
+		return
+	}
+
 	response.JSON(w, http.StatusOK, map[string]string{
 		"status": "ready",
 	})
@@ -23,9 +38,14 @@ func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
 
 // Info returns application information
 func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
-	response.JSON(w, http.StatusOK, map[string]interface{}{
+	info := map[string]interface{}{
 		"name":        h.config.App.Name,
 		"version":     h.config.App.Version,
 		"environment": h.config.App.Environment,
-	})
+	}
+	if identity := middleware.GetClientIdentity(r.Context()); identity != nil {
+		info["client"] = identity
+	}
+
+	response.JSON(w, http.StatusOK, info)
 }
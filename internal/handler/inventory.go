@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// errInsufficientStock is returned when a movement would make an item's
+// quantity negative
+var errInsufficientStock = errors.New("insufficient stock")
+
+// AdjustStock atomically applies a stock movement to an item and records it
+// in the item's ledger
+func (h *Handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	var req model.StockAdjustmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	delta := req.Quantity
+	switch req.Type {
+	case model.MovementReceipt, model.MovementSale:
+		if req.Quantity <= 0 {
+			response.Error(w, http.StatusBadRequest, "Quantity must be positive")
+			return
+		}
+		if req.Type == model.MovementSale {
+			delta = -req.Quantity
+		}
+	case model.MovementCorrection:
+		if req.Quantity == 0 {
+			response.Error(w, http.StatusBadRequest, "Quantity must not be zero")
+			return
+		}
+		if req.Reason == "" {
+			response.Error(w, http.StatusBadRequest, "Reason is required for corrections")
+			return
+		}
+	default:
+		response.Error(w, http.StatusBadRequest, "Type must be receipt, sale or correction")
+		return
+	}
+
+	var before, item model.Item
+	var movement model.StockMovement
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Items.Get(tx, id)
+		if !exists || before.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, before); err != nil {
+			return err
+		}
+
+		var err error
+		item, movement, err = moveStock(tx, before, req.Type, delta, req.Reason)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, r, audit.ActionUpdate, "item", id, before, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, movement)
+}
+
+// ListStockMovements returns an item's ledger, oldest first, with the
+// balance after each movement
+func (h *Handler) ListStockMovements(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	var item model.Item
+	var exists bool
+	var movements []model.StockMovement
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		item, exists = store.Items.Get(tx, id)
+		movements = store.Movements.Filter(tx, func(m model.StockMovement) bool {
+			return m.ItemID == id
+		})
+		return nil
+	})
+
+	if !exists || item.DeletedAt != nil {
+		response.Error(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"movements": movements,
+		"total":     len(movements),
+		"balance":   item.Quantity,
+	})
+}
+
+// moveStock changes item's quantity by delta and appends the movement to
+// the ledger, failing rather than taking stock that is not available
+func moveStock(tx *store.Tx, item model.Item, movementType string, delta int, reason string) (model.Item, model.StockMovement, error) {
+	if delta < 0 && available(tx, item, time.Now().UTC())+delta < 0 {
+		return item, model.StockMovement{}, errInsufficientStock
+	}
+
+	item.Quantity += delta
+	item, err := store.Items.Save(tx, item.ID, item)
+	if err != nil {
+		return item, model.StockMovement{}, err
+	}
+	tx.Emit("item", item.ID, events.ActionUpdated, item)
+
+	movement, err := appendMovement(tx, item, movementType, delta, reason)
+	return item, movement, err
+}
+
+// appendMovement records a change of delta that left item at its current
+// quantity. The item's own event carries the new quantity to subscribers.
+func appendMovement(tx *store.Tx, item model.Item, movementType string, delta int, reason string) (model.StockMovement, error) {
+	return store.Movements.Insert(tx, func(id int64) model.StockMovement {
+		return model.StockMovement{
+			ID:       id,
+			ItemID:   item.ID,
+			Type:     movementType,
+			Quantity: delta,
+			Balance:  item.Quantity,
+			Reason:   reason,
+		}
+	})
+}
@@ -1,30 +1,71 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
+	"slices"
 	"strconv"
-	"sync"
+	"time"
 
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
 	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/money"
 	"github.com/gostructure/app/pkg/response"
 )
 
-// In-memory item storage (replace with database in production)
-var (
-	items     = make(map[int64]*model.Item)
-	itemMutex sync.RWMutex
-	itemIDSeq int64 = 1
-)
-
-// ListItems returns all items
+// ListItems returns all items, or those changed since updated_since, in the
+// subtree of category (an ID or slug), carrying every tag given or owned by
+// owner (a user ID or "me"). Deleted items are only listed for admins
+// passing include_deleted=true.
 func (h *Handler) ListItems(w http.ResponseWriter, r *http.Request) {
-	itemMutex.RLock()
-	defer itemMutex.RUnlock()
+	include, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+	since, ok := parseUpdatedSince(w, r)
+	if !ok {
+		return
+	}
+	categoryRef := r.URL.Query().Get("category")
+	tags := normalizeTags(r.URL.Query()["tag"])
+	ownerRef := r.URL.Query().Get("owner")
+	var ownerID int64
+	if ownerRef != "" && ownerRef != "me" {
+		var err error
+		if ownerID, err = strconv.ParseInt(ownerRef, 10, 64); err != nil || ownerID <= 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid owner")
+			return
+		}
+	}
 
-	itemList := make([]*model.Item, 0, len(items))
-	for _, item := range items {
-		itemList = append(itemList, item)
+	var itemList []model.Item
+	var categoryFound bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		if ownerRef == "me" {
+			// Principals without a user own nothing
+			user, _ := principalUser(tx, r)
+			ownerID = user.ID
+		}
+		var categories map[int64]bool
+		if categoryRef != "" {
+			var category model.Category
+			if category, categoryFound = findCategory(tx, categoryRef); !categoryFound {
+				return nil
+			}
+			categories = categorySubtree(tx, category.ID)
+		}
+		itemList = store.Items.Filter(tx, func(i model.Item) bool {
+			return (include || i.DeletedAt == nil) && !i.UpdatedAt.Before(since) &&
+				(categories == nil || categories[i.CategoryID]) &&
+				(ownerRef == "" || (ownerID != 0 && i.OwnerID == ownerID)) &&
+				!slices.ContainsFunc(tags, func(tag string) bool { return !slices.Contains(i.Tags, tag) })
+		})
+		return nil
+	})
+	if categoryRef != "" && !categoryFound {
+		response.Error(w, http.StatusBadRequest, "Unknown category")
+		return
 	}
 
 	response.JSON(w, http.StatusOK, map[string]interface{}{
@@ -33,6 +74,15 @@ func (h *Handler) ListItems(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// itemDetail is an item with the stock held by reservations and its
+// attachments. Quantity is the stock on hand.
+type itemDetail struct {
+	model.Item
+	Reserved    int                `json:"reserved"`
+	Available   int                `json:"available"`
+	Attachments []model.Attachment `json:"attachments"`
+}
+
 // GetItem returns a specific item by ID
 func (h *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
@@ -40,24 +90,42 @@ func (h *Handler) GetItem(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "Invalid item ID")
 		return
 	}
+	include, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
 
-	itemMutex.RLock()
-	item, exists := items[id]
-	itemMutex.RUnlock()
+	var item model.Item
+	var exists bool
+	var held int
+	var attachments []model.Attachment
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		item, exists = store.Items.Get(tx, id)
+		held = reserved(tx, id, time.Now().UTC())
+		attachments = itemAttachments(tx, id)
+		return nil
+	})
 
-	if !exists {
+	if !exists || (item.DeletedAt != nil && !include) {
 		response.Error(w, http.StatusNotFound, "Item not found")
 		return
 	}
+	if notModified(w, r, item.UpdatedAt) {
+		return
+	}
 
-	response.JSON(w, http.StatusOK, item)
+	response.JSON(w, http.StatusOK, itemDetail{
+		Item:        item,
+		Reserved:    held,
+		Available:   item.Quantity - held,
+		Attachments: attachments,
+	})
 }
 
 // CreateItem creates a new item
 func (h *Handler) CreateItem(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateItemRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -65,18 +133,55 @@ func (h *Handler) CreateItem(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "Name is required")
 		return
 	}
+	if req.Price.IsNegative() {
+		response.Error(w, http.StatusBadRequest, "Price must not be negative")
+		return
+	}
+	if req.Quantity < 0 {
+		response.Error(w, http.StatusBadRequest, "Quantity must not be negative")
+		return
+	}
+	if req.ReorderThreshold < 0 {
+		response.Error(w, http.StatusBadRequest, "Reorder threshold must not be negative")
+		return
+	}
 
-	itemMutex.Lock()
-	item := &model.Item{
-		ID:          itemIDSeq,
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
-		Quantity:    req.Quantity,
+	var item model.Item
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		if err := checkItemQuota(tx, h.tenantConfig(r), 1); err != nil {
+			return err
+		}
+		owner, _ := principalUser(tx, r)
+		var err error
+		item, err = store.Items.Insert(tx, func(id int64) model.Item {
+			return model.Item{
+				ID:               id,
+				Name:             req.Name,
+				Description:      req.Description,
+				Price:            defaultPrice(req.Price),
+				Quantity:         req.Quantity,
+				ReorderThreshold: req.ReorderThreshold,
+				OwnerID:          owner.ID,
+			}
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("item", item.ID, events.ActionCreated, item)
+		if err := recordPrice(tx, item, "Initial price"); err != nil {
+			return err
+		}
+		if item.Quantity > 0 {
+			if _, err := appendMovement(tx, item, model.MovementReceipt, item.Quantity, "Initial stock"); err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, r, audit.ActionCreate, "item", item.ID, nil, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
 	}
-	items[itemIDSeq] = item
-	itemIDSeq++
-	itemMutex.Unlock()
 
 	response.JSON(w, http.StatusCreated, item)
 }
@@ -90,37 +195,81 @@ func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req model.UpdateItemRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-
-	itemMutex.Lock()
-	defer itemMutex.Unlock()
-
-	item, exists := items[id]
-	if !exists {
-		response.Error(w, http.StatusNotFound, "Item not found")
+	if req.Price != nil && req.Price.IsNegative() {
+		response.Error(w, http.StatusBadRequest, "Price must not be negative")
 		return
 	}
-
-	if req.Name != "" {
-		item.Name = req.Name
-	}
-	if req.Description != "" {
-		item.Description = req.Description
+	if req.Quantity != nil && *req.Quantity < 0 {
+		response.Error(w, http.StatusBadRequest, "Quantity must not be negative")
+		return
 	}
-	if req.Price != nil {
-		item.Price = *req.Price
+	if req.ReorderThreshold != nil && *req.ReorderThreshold < 0 {
+		response.Error(w, http.StatusBadRequest, "Reorder threshold must not be negative")
+		return
 	}
-	if req.Quantity != nil {
-		item.Quantity = *req.Quantity
+
+	var before, item model.Item
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Items.Get(tx, id)
+		if !exists || before.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, before); err != nil {
+			return err
+		}
+
+		item = before
+		if req.Name != "" {
+			item.Name = req.Name
+		}
+		if req.Description != "" {
+			item.Description = req.Description
+		}
+		if req.Price != nil {
+			item.Price = defaultPrice(*req.Price)
+		}
+		if req.ReorderThreshold != nil {
+			item.ReorderThreshold = *req.ReorderThreshold
+		}
+		if req.Quantity != nil {
+			item.Quantity = *req.Quantity
+			if item.Quantity < before.Quantity && item.Quantity < reserved(tx, id, time.Now().UTC()) {
+				return errInsufficientStock
+			}
+		}
+
+		var err error
+		if item, err = store.Items.Save(tx, id, item); err != nil {
+			return err
+		}
+		tx.Emit("item", id, events.ActionUpdated, item)
+		if item.Price != before.Price {
+			if err := recordPrice(tx, item, "Item update"); err != nil {
+				return err
+			}
+		}
+		// Setting the quantity directly is recorded as a correction
+		if delta := item.Quantity - before.Quantity; delta != 0 {
+			if _, err := appendMovement(tx, item, model.MovementCorrection, delta, "Item update"); err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, r, audit.ActionUpdate, "item", id, before, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
 	}
 
 	response.JSON(w, http.StatusOK, item)
 }
 
-// DeleteItem deletes an item
+// DeleteItem moves an item to the trash, from which it can be restored
+// until it is purged
 func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -128,16 +277,131 @@ func (h *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	itemMutex.Lock()
-	defer itemMutex.Unlock()
+	var before, item model.Item
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Items.Get(tx, id)
+		if !exists || before.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, before); err != nil {
+			return err
+		}
 
-	if _, exists := items[id]; !exists {
-		response.Error(w, http.StatusNotFound, "Item not found")
+		now := time.Now().UTC()
+		item = before
+		item.DeletedAt = &now
+		var err error
+		if item, err = store.Items.Save(tx, id, item); err != nil {
+			return err
+		}
+		tx.Emit("item", id, events.ActionDeleted, item)
+		return recordAudit(tx, r, audit.ActionDelete, "item", id, before, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
 		return
 	}
 
-	delete(items, id)
 	response.JSON(w, http.StatusOK, map[string]string{
 		"message": "Item deleted successfully",
 	})
 }
+
+// ItemAction handles custom methods on an item, such as
+// POST /api/v1/items/{id}:restore
+func (h *Handler) ItemAction(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := parseAction(w, r, "Item")
+	if !ok {
+		return
+	}
+
+	switch action {
+	case "restore":
+		h.restoreItem(w, r, id)
+	default:
+		response.Error(w, http.StatusNotFound, "Unknown action")
+	}
+}
+
+func (h *Handler) restoreItem(w http.ResponseWriter, r *http.Request, id int64) {
+	var before, item model.Item
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Items.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if before.DeletedAt == nil {
+			return errNotDeleted
+		}
+		if err := checkOwner(tx, r, before); err != nil {
+			return err
+		}
+		if err := checkItemQuota(tx, h.tenantConfig(r), 1); err != nil {
+			return err
+		}
+
+		item = before
+		item.DeletedAt = nil
+		var err error
+		if item, err = store.Items.Save(tx, id, item); err != nil {
+			return err
+		}
+		tx.Emit("item", id, events.ActionRestored, item)
+		return recordAudit(tx, r, audit.ActionRestore, "item", id, before, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, item)
+}
+
+// modifyItem applies change to the item named in the request path and saves
+// it
+func (h *Handler) modifyItem(w http.ResponseWriter, r *http.Request, change func(tx *store.Tx, item *model.Item) error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	var before, item model.Item
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Items.Get(tx, id)
+		if !exists || before.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, before); err != nil {
+			return err
+		}
+
+		item = before
+		if err := change(tx, &item); err != nil {
+			return err
+		}
+		var err error
+		if item, err = store.Items.Save(tx, id, item); err != nil {
+			return err
+		}
+		tx.Emit("item", id, events.ActionUpdated, item)
+		return recordAudit(tx, r, audit.ActionUpdate, "item", id, before, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, item)
+}
+
+// defaultPrice returns price, or zero in the default currency if unset
+func defaultPrice(price money.Money) money.Money {
+	if price.Currency() == "" {
+		price, _ = money.Zero(money.DefaultCurrency)
+	}
+	return price
+}
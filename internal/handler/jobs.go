@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/money"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// Job types
+const (
+	JobExportItems = "items.export"
+	JobImportItems = "items.import"
+)
+
+// RegisterJobs registers the handlers' background job types with m
+func (h *Handler) RegisterJobs(m *jobs.Manager) {
+	m.Register(JobExportItems, h.exportItems, jobs.WithQueue("exports"))
+	m.Register(JobImportItems, h.importItems, jobs.WithQueue("imports"))
+}
+
+// GetJob returns the status and result of a job. Jobs are visible to the
+// principal that enqueued them and to admins of the same tenant. Anonymous
+// callers share one subject, so their jobs are visible to admins only.
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	job, ok := h.jobs.Get(id)
+	principal := middleware.GetPrincipal(r.Context())
+	owner := principal.Source != middleware.SourceAnonymous && job.Owner == principal.Subject
+	if !ok || job.Tenant != tenant(r) || (!owner && !principal.HasRole(middleware.RoleAdmin)) {
+		response.Error(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, job)
+}
+
+// ExportItems starts a job exporting all items
+func (h *Handler) ExportItems(w http.ResponseWriter, r *http.Request) {
+	h.enqueueJob(w, r, JobExportItems, struct{}{})
+}
+
+// ImportItems starts a job creating the items in the request body
+func (h *Handler) ImportItems(w http.ResponseWriter, r *http.Request) {
+	var req model.ImportItemsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.Items) == 0 {
+		response.Error(w, http.StatusBadRequest, "Items are required")
+		return
+	}
+	for i, item := range req.Items {
+		if item.Name == "" {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Name is required for item %d", i))
+			return
+		}
+		if item.Price.IsNegative() {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Price must not be negative for item %d", i))
+			return
+		}
+	}
+	// The job checks the quota again when it runs
+	err := h.tenantStore(r).View(func(tx *store.Tx) error {
+		return checkItemQuota(tx, h.tenantConfig(r), len(req.Items))
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	h.enqueueJob(w, r, JobImportItems, req)
+}
+
+func (h *Handler) enqueueJob(w http.ResponseWriter, r *http.Request, jobType string, payload interface{}) {
+	owner := middleware.GetPrincipal(r.Context()).Subject
+	job, err := h.jobs.EnqueueFor(tenant(r), jobType, payload, owner)
+	if err != nil {
+		writeStoreError(w, err, "Job")
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/jobs/"+strconv.FormatInt(job.ID, 10))
+	response.JSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handler) exportItems(ctx context.Context, job jobs.Job) (interface{}, error) {
+	var itemList []model.Item
+	h.store.Scoped(job.Tenant).View(func(tx *store.Tx) error {
+		itemList = store.Items.Filter(tx, func(i model.Item) bool {
+			return i.DeletedAt == nil
+		})
+		return nil
+	})
+
+	// Stock value is totalled per currency
+	value := make(map[string]money.Money)
+	for _, item := range itemList {
+		currency := item.Price.Currency()
+		if currency == "" {
+			continue
+		}
+		total, ok := value[currency]
+		if !ok {
+			total, _ = money.Zero(currency)
+		}
+		stock, err := item.Price.Mul(int64(item.Quantity))
+		if err == nil {
+			total, err = total.Add(stock)
+		}
+		if err != nil {
+			return nil, jobs.Permanent(fmt.Errorf("value of item %d: %w", item.ID, err))
+		}
+		value[currency] = total
+	}
+
+	return map[string]interface{}{
+		"items": itemList,
+		"total": len(itemList),
+		"value": value,
+	}, ctx.Err()
+}
+
+func (h *Handler) importItems(ctx context.Context, job jobs.Job) (interface{}, error) {
+	var req model.ImportItemsRequest
+	if err := job.Decode(&req); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	// All items are created in the transaction that completes the job, so a
+	// retry never leaves a partial import behind or imports the items twice
+	requestID := "job-" + strconv.FormatInt(job.ID, 10)
+	var result map[string]interface{}
+	err := h.store.Scoped(job.Tenant).UpdateAs(job.Owner, func(tx *store.Tx) error {
+		if err := checkItemQuota(tx, h.config.ForTenant(job.Tenant), len(req.Items)); err != nil {
+			return jobs.Permanent(err)
+		}
+		owner, _ := subjectUser(tx, job.Owner)
+		var ids []int64
+		for _, r := range req.Items {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if r.Name == "" {
+				return jobs.Permanent(errors.New("name is required"))
+			}
+			if r.Quantity < 0 {
+				return jobs.Permanent(errors.New("quantity must not be negative"))
+			}
+			if r.ReorderThreshold < 0 {
+				return jobs.Permanent(errors.New("reorder threshold must not be negative"))
+			}
+			item, err := store.Items.Insert(tx, func(id int64) model.Item {
+				return model.Item{
+					ID:               id,
+					Name:             r.Name,
+					Description:      r.Description,
+					Price:            defaultPrice(r.Price),
+					Quantity:         r.Quantity,
+					ReorderThreshold: r.ReorderThreshold,
+					OwnerID:          owner.ID,
+				}
+			})
+			if err != nil {
+				return err
+			}
+			tx.Emit("item", item.ID, events.ActionCreated, item)
+			if err := recordPrice(tx, item, "Initial price"); err != nil {
+				return err
+			}
+			if item.Quantity > 0 {
+				if _, err := appendMovement(tx, item, model.MovementReceipt, item.Quantity, "Initial stock"); err != nil {
+					return err
+				}
+			}
+			if err := appendAudit(tx, job.Owner, requestID, audit.ActionCreate, "item", item.ID, nil, item); err != nil {
+				return err
+			}
+			ids = append(ids, item.ID)
+		}
+
+		result = map[string]interface{}{
+			"created": len(ids),
+			"ids":     ids,
+		}
+		return jobs.Complete(tx, job, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gostructure/app/pkg/response"
+)
+
+// parseUpdatedSince reads the updated_since list filter used for delta
+// sync. The zero time matches every record.
+func parseUpdatedSince(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	v := r.URL.Query().Get("updated_since")
+	if v == "" {
+		return time.Time{}, true
+	}
+	since, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid updated_since timestamp")
+		return time.Time{}, false
+	}
+	return since, true
+}
+
+// notModified sets Last-Modified from updated and reports whether the
+// client's copy is current per If-Modified-Since, in which case a 304 has
+// been written
+func notModified(w http.ResponseWriter, r *http.Request, updated time.Time) bool {
+	if updated.IsZero() {
+		return false
+	}
+	// HTTP dates have second precision
+	updated = updated.Truncate(time.Second)
+	w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || updated.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/money"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// errInvalidTransition is returned when an order's status does not allow
+// the requested change
+var errInvalidTransition = errors.New("invalid order status transition")
+
+// invalidError is a request that fails a check made inside a transaction.
+// Its text is the response message.
+type invalidError string
+
+func (e invalidError) Error() string { return string(e) }
+
+// orderActions maps the custom methods on an order to the status they move
+// it to
+var orderActions = map[string]string{
+	"pay":    model.OrderPaid,
+	"ship":   model.OrderShipped,
+	"cancel": model.OrderCancelled,
+}
+
+// ListOrders returns all orders, optionally filtered by user_id and status
+func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if raw := r.URL.Query().Get("user_id"); raw != "" {
+		var err error
+		if userID, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+	}
+	status := r.URL.Query().Get("status")
+
+	var orderList []model.Order
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		orderList = store.Orders.Filter(tx, func(o model.Order) bool {
+			return (userID == 0 || o.UserID == userID) && (status == "" || o.Status == status)
+		})
+		return nil
+	})
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"orders": orderList,
+		"total":  len(orderList),
+	})
+}
+
+// GetOrder returns a specific order by ID
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var order model.Order
+	var exists bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		order, exists = store.Orders.Get(tx, id)
+		return nil
+	})
+
+	if !exists {
+		response.Error(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	if notModified(w, r, order.UpdatedAt) {
+		return
+	}
+
+	response.JSON(w, http.StatusOK, order)
+}
+
+// CreateOrder creates a pending order for a user, capturing the current
+// price of each item
+func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateOrderRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var order model.Order
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		user, exists := store.Users.Get(tx, req.UserID)
+		if !exists || user.DeletedAt != nil {
+			return invalidError(fmt.Sprintf("User %d not found", req.UserID))
+		}
+		lines, total, err := orderLines(tx, req.Lines)
+		if err != nil {
+			return err
+		}
+
+		order, err = store.Orders.Insert(tx, func(id int64) model.Order {
+			return model.Order{
+				ID:     id,
+				UserID: user.ID,
+				Status: model.OrderPending,
+				Lines:  lines,
+				Total:  total,
+			}
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("order", order.ID, events.ActionCreated, order)
+		return recordAudit(tx, r, audit.ActionCreate, "order", order.ID, nil, order)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Order")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, order)
+}
+
+// UpdateOrder replaces the lines of a pending order
+func (h *Handler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req model.UpdateOrderRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var before, order model.Order
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Orders.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if before.Status != model.OrderPending {
+			return errInvalidTransition
+		}
+
+		order = before
+		var err error
+		if order.Lines, order.Total, err = orderLines(tx, req.Lines); err != nil {
+			return err
+		}
+		if order, err = store.Orders.Save(tx, id, order); err != nil {
+			return err
+		}
+		tx.Emit("order", id, events.ActionUpdated, order)
+		return recordAudit(tx, r, audit.ActionUpdate, "order", id, before, order)
+	})
+	if errors.Is(err, errInvalidTransition) {
+		response.Error(w, http.StatusConflict, "Only pending orders can be changed")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Order")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, order)
+}
+
+// DeleteOrder removes a pending or cancelled order
+func (h *Handler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var before model.Order
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Orders.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if before.Status != model.OrderPending && before.Status != model.OrderCancelled {
+			return errInvalidTransition
+		}
+
+		store.Orders.Delete(tx, id)
+		tx.Emit("order", id, events.ActionDeleted, nil)
+		return recordAudit(tx, r, audit.ActionDelete, "order", id, before, nil)
+	})
+	if errors.Is(err, errInvalidTransition) {
+		response.Error(w, http.StatusConflict, "Only pending or cancelled orders can be deleted")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Order")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{
+		"message": "Order deleted successfully",
+	})
+}
+
+// OrderAction handles the status transitions of an order:
+// POST /api/v1/orders/{id}:pay, {id}:ship and {id}:cancel
+func (h *Handler) OrderAction(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := parseAction(w, r, "Order")
+	if !ok {
+		return
+	}
+	status, ok := orderActions[action]
+	if !ok {
+		response.Error(w, http.StatusNotFound, "Unknown action")
+		return
+	}
+
+	var before, order model.Order
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Orders.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if !model.CanTransition(before.Status, status) {
+			return errInvalidTransition
+		}
+
+		// Paying takes the stock and cancelling a paid order returns it.
+		// Shipped stock has left, so cancelling a shipped order keeps it
+		// taken; returned goods are booked with a stock receipt.
+		reason := "Order " + strconv.FormatInt(id, 10)
+		var changes []itemChange
+		var err error
+		switch {
+		case status == model.OrderPaid:
+			changes, err = moveOrderStock(tx, before, model.MovementSale, -1, reason)
+		case status == model.OrderCancelled && before.Status == model.OrderPaid:
+			changes, err = moveOrderStock(tx, before, model.MovementReceipt, 1, reason+" cancelled")
+		}
+		if err != nil {
+			return err
+		}
+
+		order = before
+		order.Status = status
+		if order, err = store.Orders.Save(tx, id, order); err != nil {
+			return err
+		}
+		tx.Emit("order", id, events.ActionUpdated, order)
+		if err := recordAudit(tx, r, audit.ActionUpdate, "order", id, before, order); err != nil {
+			return err
+		}
+		for _, c := range changes {
+			if err := recordAudit(tx, r, audit.ActionUpdate, "item", c.after.ID, c.before, c.after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidTransition) {
+		response.Error(w, http.StatusConflict, fmt.Sprintf("Cannot %s a %s order", action, before.Status))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Order")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, order)
+}
+
+// itemChange is an item before and after a change
+type itemChange struct{ before, after model.Item }
+
+// moveOrderStock moves the quantity of each line of order in the direction
+// of sign. Stock is not returned to items that have been purged.
+func moveOrderStock(tx *store.Tx, order model.Order, movementType string, sign int, reason string) ([]itemChange, error) {
+	var changes []itemChange
+	for _, line := range order.Lines {
+		item, exists := store.Items.Get(tx, line.ItemID)
+		if sign < 0 && (!exists || item.DeletedAt != nil) {
+			return nil, errInsufficientStock
+		}
+		if !exists {
+			continue
+		}
+		after, _, err := moveStock(tx, item, movementType, sign*line.Quantity, reason)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, itemChange{item, after})
+	}
+	return changes, nil
+}
+
+// orderLines builds order lines from a request, capturing each item's
+// current name and price, and returns them with the order total
+func orderLines(tx *store.Tx, reqLines []model.OrderLineRequest) ([]model.OrderLine, money.Money, error) {
+	if len(reqLines) == 0 {
+		return nil, money.Money{}, invalidError("Order must have at least one line")
+	}
+
+	lines := make([]model.OrderLine, len(reqLines))
+	totals := make([]money.Money, len(reqLines))
+	seen := make(map[int64]bool, len(reqLines))
+	for i, req := range reqLines {
+		if req.Quantity <= 0 {
+			return nil, money.Money{}, invalidError(fmt.Sprintf("Quantity must be positive for line %d", i))
+		}
+		if seen[req.ItemID] {
+			return nil, money.Money{}, invalidError(fmt.Sprintf("Item %d appears on more than one line", req.ItemID))
+		}
+		seen[req.ItemID] = true
+
+		item, exists := store.Items.Get(tx, req.ItemID)
+		if !exists || item.DeletedAt != nil {
+			return nil, money.Money{}, invalidError(fmt.Sprintf("Item %d not found", req.ItemID))
+		}
+		price := defaultPrice(item.Price)
+		total, err := price.Mul(int64(req.Quantity))
+		if err != nil {
+			return nil, money.Money{}, invalidError(fmt.Sprintf("Total out of range for line %d", i))
+		}
+		lines[i] = model.OrderLine{
+			ItemID:    item.ID,
+			Name:      item.Name,
+			Quantity:  req.Quantity,
+			UnitPrice: price,
+			Total:     total,
+		}
+		totals[i] = total
+	}
+
+	total, err := money.Sum(totals...)
+	if errors.Is(err, money.ErrCurrencyMismatch) {
+		return nil, money.Money{}, invalidError("Order lines must share one currency")
+	}
+	if err != nil {
+		return nil, money.Money{}, invalidError("Order total out of range")
+	}
+	return lines, total, nil
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+var (
+	errNotOwner     = errors.New("not the owner")
+	errNotSelf      = errors.New("not the user")
+	errOwnsItems    = errors.New("user owns items")
+	errSubjectTaken = errors.New("subject is already in use")
+)
+
+// ListUserItems returns the live items owned by a user
+func (h *Handler) ListUserItems(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var itemList []model.Item
+	var exists bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		var user model.User
+		user, exists = store.Users.Get(tx, id)
+		exists = exists && user.DeletedAt == nil
+		itemList = store.Items.Filter(tx, func(i model.Item) bool {
+			return i.OwnerID == id && i.DeletedAt == nil
+		})
+		return nil
+	})
+
+	if !exists {
+		response.Error(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"items": itemList,
+		"total": len(itemList),
+	})
+}
+
+// subjectUser returns the live user that principals with subject act as
+func subjectUser(tx *store.Tx, subject string) (model.User, bool) {
+	if subject == "" {
+		return model.User{}, false
+	}
+	users := store.Users.Filter(tx, func(u model.User) bool {
+		return u.Subject == subject && u.DeletedAt == nil
+	})
+	if len(users) == 0 {
+		return model.User{}, false
+	}
+	return users[0], true
+}
+
+// principalUser returns the live user the request principal acts as
+func principalUser(tx *store.Tx, r *http.Request) (model.User, bool) {
+	principal := middleware.GetPrincipal(r.Context())
+	if principal.Source == middleware.SourceAnonymous {
+		return model.User{}, false
+	}
+	return subjectUser(tx, principal.Subject)
+}
+
+// checkOwner returns errNotOwner unless the principal may change item:
+// items without an owner can be changed by anyone, others only by their
+// owner and admins
+func checkOwner(tx *store.Tx, r *http.Request, item model.Item) error {
+	if item.OwnerID == 0 || middleware.GetPrincipal(r.Context()).HasRole(middleware.RoleAdmin) {
+		return nil
+	}
+	if user, ok := principalUser(tx, r); ok && user.ID == item.OwnerID {
+		return nil
+	}
+	return errNotOwner
+}
+
+// checkSelf returns errNotSelf unless the principal may change user: users
+// without a subject can be changed by anyone, others only by the principal
+// they act for and admins
+func checkSelf(r *http.Request, user model.User) error {
+	principal := middleware.GetPrincipal(r.Context())
+	if user.Subject == "" || principal.HasRole(middleware.RoleAdmin) {
+		return nil
+	}
+	if principal.Source != middleware.SourceAnonymous && user.Subject == principal.Subject {
+		return nil
+	}
+	return errNotSelf
+}
+
+// checkSubject returns errSubjectTaken if another live user than id has
+// subject
+func checkSubject(tx *store.Tx, id int64, subject string) error {
+	if user, ok := subjectUser(tx, subject); ok && user.ID != id {
+		return errSubjectTaken
+	}
+	return nil
+}
+
+// ownedItemsPolicy reads what to do with the items of a user being deleted
+// from ?items= and ?reassign_to=, defaulting to the configured policy. Only
+// admins may ask for items to be orphaned or reassigned.
+func (h *Handler) ownedItemsPolicy(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	q := r.URL.Query()
+	policy := q.Get("items")
+	if policy == model.OwnedItemsOrphan || policy == model.OwnedItemsReassign {
+		if !requireRole(w, r, middleware.RoleAdmin) {
+			return "", 0, false
+		}
+	}
+	if policy == "" {
+		policy = h.config.Users.ItemsOnDelete
+	}
+	switch policy {
+	case "":
+		return model.OwnedItemsBlock, 0, true
+	case model.OwnedItemsBlock, model.OwnedItemsOrphan:
+		return policy, 0, true
+	case model.OwnedItemsReassign:
+		target, err := strconv.ParseInt(q.Get("reassign_to"), 10, 64)
+		if err != nil || target <= 0 {
+			response.Error(w, http.StatusBadRequest, "Reassigning items requires a reassign_to user ID")
+			return "", 0, false
+		}
+		return policy, target, true
+	default:
+		response.Error(w, http.StatusBadRequest, "Items must be block, orphan or reassign")
+		return "", 0, false
+	}
+}
+
+// releaseItems applies policy to the items owned by user, including deleted
+// ones, and returns them before and after the change
+func releaseItems(tx *store.Tx, userID int64, policy string, target int64) (before, after []model.Item, err error) {
+	owned := store.Items.Filter(tx, func(i model.Item) bool { return i.OwnerID == userID })
+	if len(owned) == 0 {
+		return nil, nil, nil
+	}
+
+	var owner int64
+	switch policy {
+	case model.OwnedItemsBlock:
+		return nil, nil, errOwnsItems
+	case model.OwnedItemsReassign:
+		user, exists := store.Users.Get(tx, target)
+		if !exists || user.DeletedAt != nil || user.ID == userID {
+			return nil, nil, invalidError("Items can only be reassigned to another active user")
+		}
+		owner = user.ID
+	}
+
+	for _, item := range owned {
+		updated := item
+		updated.OwnerID = owner
+		if updated, err = store.Items.Save(tx, item.ID, updated); err != nil {
+			return nil, nil, err
+		}
+		tx.Emit("item", item.ID, events.ActionUpdated, updated)
+		before, after = append(before, item), append(after, updated)
+	}
+	return before, after, nil
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// errNotScheduled is returned when cancelling a price change that has
+// already been applied
+var errNotScheduled = errors.New("price change is not scheduled")
+
+// ListPrices returns an item's price history ordered by effective time,
+// including scheduled changes. With ?at= only the change in effect at that
+// time is returned.
+func (h *Handler) ListPrices(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+	query := r.URL.Query()
+	status := query.Get("status")
+	var at time.Time
+	if raw := query.Get("at"); raw != "" {
+		if at, err = time.Parse(time.RFC3339, raw); err != nil {
+			response.Error(w, http.StatusBadRequest, "at must be an RFC 3339 time")
+			return
+		}
+	}
+
+	var item model.Item
+	var exists bool
+	var prices []model.PriceChange
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		item, exists = store.Items.Get(tx, id)
+		if at.IsZero() {
+			prices = priceHistory(tx, id)
+		} else if change, ok := priceAt(tx, id, at); ok {
+			prices = []model.PriceChange{change}
+		}
+		return nil
+	})
+
+	if !exists || item.DeletedAt != nil {
+		response.Error(w, http.StatusNotFound, "Item not found")
+		return
+	}
+	if status != "" {
+		prices = slices.DeleteFunc(prices, func(p model.PriceChange) bool { return p.Status != status })
+	}
+	if prices == nil {
+		prices = []model.PriceChange{}
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"prices": prices,
+		"total":  len(prices),
+		"price":  item.Price,
+	})
+}
+
+// SchedulePrice schedules a change of an item's price at a future time
+func (h *Handler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+
+	var req model.SchedulePriceRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Price.IsNegative() {
+		response.Error(w, http.StatusBadRequest, "Price must not be negative")
+		return
+	}
+	if !req.EffectiveAt.After(time.Now()) {
+		response.Error(w, http.StatusBadRequest, "effective_at must be in the future")
+		return
+	}
+
+	var change model.PriceChange
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		item, exists := store.Items.Get(tx, id)
+		if !exists || item.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, item); err != nil {
+			return err
+		}
+
+		var err error
+		change, err = store.Prices.Insert(tx, func(pid int64) model.PriceChange {
+			return model.PriceChange{
+				ID:          pid,
+				ItemID:      id,
+				Price:       defaultPrice(req.Price),
+				Status:      model.PriceScheduled,
+				EffectiveAt: req.EffectiveAt.UTC(),
+				Reason:      req.Reason,
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, r, audit.ActionCreate, "price_change", change.ID, nil, change)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, change)
+}
+
+// CancelPriceChange deletes a scheduled price change
+func (h *Handler) CancelPriceChange(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid item ID")
+		return
+	}
+	pid, err := strconv.ParseInt(r.PathValue("pid"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid price change ID")
+		return
+	}
+
+	var change model.PriceChange
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		change, exists = store.Prices.Get(tx, pid)
+		if !exists || change.ItemID != id {
+			return store.ErrNotFound
+		}
+		item, exists := store.Items.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if err := checkOwner(tx, r, item); err != nil {
+			return err
+		}
+		if change.Status != model.PriceScheduled {
+			return errNotScheduled
+		}
+		store.Prices.Delete(tx, pid)
+		return recordAudit(tx, r, audit.ActionDelete, "price_change", pid, change, nil)
+	})
+	if errors.Is(err, errNotScheduled) {
+		response.Error(w, http.StatusConflict, "Only scheduled price changes can be cancelled")
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Price change")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{
+		"message": "Price change cancelled successfully",
+	})
+}
+
+// ApplyScheduledPrices sets the price of items whose scheduled changes have
+// become effective and returns how many were applied. Changes keep their
+// scheduled effective time and record when they were applied. Changes of
+// items in the trash wait until the item is restored, and a change
+// superseded by a later applied one only enters the history.
+func (h *Handler) ApplyScheduledPrices() (int, error) {
+	now := time.Now().UTC()
+
+	var count int
+	err := h.store.UpdateAs(systemActor, func(tx *store.Tx) error {
+		due := store.Prices.Filter(tx, func(p model.PriceChange) bool {
+			return p.Status == model.PriceScheduled && !p.EffectiveAt.After(now)
+		})
+		// Changes due together for one item are applied in effective order
+		slices.SortStableFunc(due, func(a, b model.PriceChange) int {
+			return a.EffectiveAt.Compare(b.EffectiveAt)
+		})
+		for _, change := range due {
+			item, exists := store.Items.Get(tx, change.ItemID)
+			if exists && item.DeletedAt != nil {
+				continue
+			}
+
+			applied := now
+			change.Status = model.PriceApplied
+			change.AppliedAt = &applied
+			if _, err := store.Prices.Save(tx, change.ID, change); err != nil {
+				return err
+			}
+			if !exists || supersededPrice(tx, change) {
+				continue
+			}
+
+			updated := item
+			updated.Price = change.Price
+			var err error
+			if updated, err = store.Items.Save(tx, item.ID, updated); err != nil {
+				return err
+			}
+			tx.Emit("item", item.ID, events.ActionUpdated, updated)
+			if err := appendAudit(tx, systemActor, "", audit.ActionUpdate, "item", item.ID, item, updated); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// recordPrice appends item's current price to its history as effective now
+func recordPrice(tx *store.Tx, item model.Item, reason string) error {
+	now := time.Now().UTC()
+	_, err := store.Prices.Insert(tx, func(id int64) model.PriceChange {
+		return model.PriceChange{
+			ID:          id,
+			ItemID:      item.ID,
+			Price:       item.Price,
+			Status:      model.PriceApplied,
+			EffectiveAt: now,
+			AppliedAt:   &now,
+			Reason:      reason,
+		}
+	})
+	return err
+}
+
+// supersededPrice reports whether another applied change of the same item
+// became effective after change
+func supersededPrice(tx *store.Tx, change model.PriceChange) bool {
+	later := store.Prices.Filter(tx, func(p model.PriceChange) bool {
+		return p.ItemID == change.ItemID && p.ID != change.ID &&
+			p.Status == model.PriceApplied && p.EffectiveAt.After(change.EffectiveAt)
+	})
+	return len(later) > 0
+}
+
+// priceHistory returns the price changes of an item ordered by effective
+// time
+func priceHistory(tx *store.Tx, itemID int64) []model.PriceChange {
+	prices := store.Prices.Filter(tx, func(p model.PriceChange) bool { return p.ItemID == itemID })
+	slices.SortStableFunc(prices, func(a, b model.PriceChange) int {
+		return cmp.Or(a.EffectiveAt.Compare(b.EffectiveAt), cmp.Compare(a.ID, b.ID))
+	})
+	return prices
+}
+
+// priceAt returns the applied price change of an item in effect at t
+func priceAt(tx *store.Tx, itemID int64, t time.Time) (model.PriceChange, bool) {
+	var current model.PriceChange
+	found := false
+	for _, p := range priceHistory(tx, itemID) {
+		if p.Status == model.PriceApplied && !p.EffectiveAt.After(t) {
+			current, found = p, true
+		}
+	}
+	return current, found
+}
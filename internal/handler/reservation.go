@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// errNotActive is returned when confirming or releasing a reservation that
+// no longer holds stock
+var errNotActive = errors.New("reservation is not active")
+
+const (
+	defaultReservationTTL    = 15 * time.Minute
+	defaultMaxReservationTTL = 24 * time.Hour
+)
+
+// CreateReservation holds stock of an item for the principal. It fails
+// with 409 if the item's available quantity is too low.
+func (h *Handler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateReservationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Quantity <= 0 {
+		response.Error(w, http.StatusBadRequest, "Quantity must be positive")
+		return
+	}
+	cfg := h.tenantConfig(r)
+	ttl, maxTTL := cfg.Inventory.ReservationTTL, cfg.Inventory.MaxReservationTTL
+	if ttl <= 0 {
+		ttl = defaultReservationTTL
+	}
+	if maxTTL <= 0 {
+		maxTTL = defaultMaxReservationTTL
+	}
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || ttl > maxTTL {
+			response.Error(w, http.StatusBadRequest, "TTL must be a positive duration up to "+maxTTL.String())
+			return
+		}
+	}
+
+	var reservation model.Reservation
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		item, exists := store.Items.Get(tx, req.ItemID)
+		if !exists || item.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+
+		now := time.Now().UTC()
+		if available(tx, item, now) < req.Quantity {
+			return errInsufficientStock
+		}
+
+		var err error
+		reservation, err = store.Reservations.Insert(tx, func(id int64) model.Reservation {
+			return model.Reservation{
+				ID:        id,
+				ItemID:    item.ID,
+				Quantity:  req.Quantity,
+				Owner:     actor(r),
+				Status:    model.ReservationActive,
+				ExpiresAt: now.Add(ttl),
+			}
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("reservation", reservation.ID, events.ActionCreated, reservation)
+		return recordAudit(tx, r, audit.ActionCreate, "reservation", reservation.ID, nil, reservation)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Item")
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, reservation)
+}
+
+// ListReservations returns the principal's reservations, or everyone's for
+// admins, optionally filtered by status
+func (h *Handler) ListReservations(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	var reservations []model.Reservation
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		reservations = store.Reservations.Filter(tx, func(res model.Reservation) bool {
+			return ownsReservation(r, res) && (status == "" || res.Status == status)
+		})
+		return nil
+	})
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"reservations": reservations,
+		"total":        len(reservations),
+	})
+}
+
+// GetReservation returns a reservation. Reservations are visible to their
+// owner and to admins.
+func (h *Handler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid reservation ID")
+		return
+	}
+
+	var reservation model.Reservation
+	var exists bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		reservation, exists = store.Reservations.Get(tx, id)
+		return nil
+	})
+
+	if !exists || !ownsReservation(r, reservation) {
+		response.Error(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, reservation)
+}
+
+// ReservationAction handles POST /api/v1/reservations/{id}:confirm, which
+// turns the held stock into a sale, and {id}:release, which frees it
+func (h *Handler) ReservationAction(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := parseAction(w, r, "Reservation")
+	if !ok {
+		return
+	}
+
+	switch action {
+	case "confirm":
+		h.closeReservation(w, r, id, model.ReservationConfirmed)
+	case "release":
+		h.closeReservation(w, r, id, model.ReservationReleased)
+	default:
+		response.Error(w, http.StatusNotFound, "Unknown action")
+	}
+}
+
+func (h *Handler) closeReservation(w http.ResponseWriter, r *http.Request, id int64, status string) {
+	var before, reservation model.Reservation
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Reservations.Get(tx, id)
+		if !exists || !ownsReservation(r, before) {
+			return store.ErrNotFound
+		}
+		if !before.Holds(time.Now().UTC()) {
+			return errNotActive
+		}
+
+		reservation = before
+		reservation.Status = status
+		var err error
+		if reservation, err = store.Reservations.Save(tx, id, reservation); err != nil {
+			return err
+		}
+		tx.Emit("reservation", id, events.ActionUpdated, reservation)
+		if err := recordAudit(tx, r, audit.ActionUpdate, "reservation", id, before, reservation); err != nil {
+			return err
+		}
+		if status != model.ReservationConfirmed {
+			return nil
+		}
+
+		// The reservation no longer holds the stock it is about to sell
+		itemBefore, exists := store.Items.Get(tx, reservation.ItemID)
+		if !exists || itemBefore.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		item, _, err := moveStock(tx, itemBefore, model.MovementSale, -reservation.Quantity, "Reservation "+strconv.FormatInt(id, 10))
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, r, audit.ActionUpdate, "item", item.ID, itemBefore, item)
+	})
+	if err != nil {
+		writeStoreError(w, err, "Reservation")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, reservation)
+}
+
+// ExpireReservations marks active reservations past their expiry as expired,
+// freeing their stock, and returns how many expired
+func (h *Handler) ExpireReservations() (int, error) {
+	now := time.Now().UTC()
+
+	var stale []model.Reservation
+	err := h.store.UpdateAs(systemActor, func(tx *store.Tx) error {
+		stale = store.Reservations.Filter(tx, func(res model.Reservation) bool {
+			return res.Status == model.ReservationActive && !res.Holds(now)
+		})
+		for _, before := range stale {
+			res := before
+			res.Status = model.ReservationExpired
+			res, err := store.Reservations.Save(tx, res.ID, res)
+			if err != nil {
+				return err
+			}
+			tx.Emit("reservation", res.ID, events.ActionUpdated, res)
+			if err := appendAudit(tx, systemActor, "", audit.ActionUpdate, "reservation", res.ID, before, res); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
+
+// ownsReservation reports whether the principal may see and act on res
+func ownsReservation(r *http.Request, res model.Reservation) bool {
+	principal := middleware.GetPrincipal(r.Context())
+	return res.Owner == principal.Subject || principal.HasRole(middleware.RoleAdmin)
+}
+
+// reserved returns the stock of item held by reservations at now
+func reserved(tx *store.Tx, itemID int64, now time.Time) int {
+	total := 0
+	for _, res := range store.Reservations.Filter(tx, func(res model.Reservation) bool {
+		return res.ItemID == itemID && res.Holds(now)
+	}) {
+		total += res.Quantity
+	}
+	return total
+}
+
+// available returns the quantity of item that is on hand and not reserved
+func available(tx *store.Tx, item model.Item, now time.Time) int {
+	return item.Quantity - reserved(tx, item.ID, now)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gostructure/app/internal/search"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+const maxSearchLimit = 100
+
+// searchResult is a search hit with the current version of its record
+type searchResult struct {
+	search.Hit
+	Data interface{} `json:"data"`
+}
+
+// Search finds items and users matching every word of q, by whole word or
+// prefix, ranked by relevance
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := search.Query{Text: q.Get("q"), Resource: q.Get("type"), Tenant: tenant(r)}
+	if query.Text == "" {
+		response.Error(w, http.StatusBadRequest, "Query is required")
+		return
+	}
+	if query.Resource != "" && query.Resource != search.ResourceItem && query.Resource != search.ResourceUser {
+		response.Error(w, http.StatusBadRequest, "Invalid type")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			response.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		query.Limit = limit
+	}
+
+	hits := h.search.Search(query)
+
+	// The index is updated shortly after each commit, so skip records that
+	// have since been deleted
+	results := make([]searchResult, 0, len(hits))
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		for _, hit := range hits {
+			var data interface{}
+			switch hit.Resource {
+			case search.ResourceItem:
+				if item, ok := store.Items.Get(tx, hit.ID); ok && item.DeletedAt == nil {
+					data = item
+				}
+			case search.ResourceUser:
+				if user, ok := store.Users.Get(tx, hit.ID); ok && user.DeletedAt == nil {
+					data = user
+				}
+			}
+			if data != nil {
+				results = append(results, searchResult{Hit: hit, Data: data})
+			}
+		}
+		return nil
+	})
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"total":   len(results),
+	})
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+)
+
+// errQuotaExceeded is returned when a change would take a tenant past one
+// of its quotas
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// checkItemQuota returns errQuotaExceeded unless the tenant of tx may have
+// n more live items
+func checkItemQuota(tx *store.Tx, cfg *config.Config, n int) error {
+	return checkQuota(tx, store.Items, cfg.Tenancy.MaxItems, n, func(i model.Item) bool {
+		return i.DeletedAt == nil
+	})
+}
+
+// checkUserQuota returns errQuotaExceeded unless the tenant of tx may have
+// n more live users
+func checkUserQuota(tx *store.Tx, cfg *config.Config, n int) error {
+	return checkQuota(tx, store.Users, cfg.Tenancy.MaxUsers, n, func(u model.User) bool {
+		return u.DeletedAt == nil
+	})
+}
+
+// checkQuota counts the live rows of t in the tenant scope of tx against
+// limit. Quotas only apply to transactions scoped to a tenant.
+func checkQuota[T any](tx *store.Tx, t store.Table[T], limit, n int, live func(T) bool) error {
+	if tx.Tenant() == "" || limit <= 0 {
+		return nil
+	}
+	if len(t.Filter(tx, live))+n > limit {
+		return errQuotaExceeded
+	}
+	return nil
+}
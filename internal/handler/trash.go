@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// systemActor is the audit actor for changes made by maintenance tasks
+const systemActor = "system"
+
+// errNotDeleted is returned when restoring a record that is not deleted
+var errNotDeleted = errors.New("not deleted")
+
+// includeDeleted reports whether the request asks for soft-deleted records
+// with ?include_deleted=true, which is reserved for admins
+func includeDeleted(w http.ResponseWriter, r *http.Request) (include, ok bool) {
+	v := r.URL.Query().Get("include_deleted")
+	if v == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid include_deleted")
+		return false, false
+	}
+	if include && !requireRole(w, r, middleware.RoleAdmin) {
+		return false, false
+	}
+	return include, true
+}
+
+// parseAction splits a path value such as "42:restore" into the ID and the
+// custom method
+func parseAction(w http.ResponseWriter, r *http.Request, resource string) (int64, string, bool) {
+	rawID, action, _ := strings.Cut(r.PathValue("id"), ":")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid "+strings.ToLower(resource)+" ID")
+		return 0, "", false
+	}
+	return id, action, true
+}
+
+// PurgeDeleted permanently removes users and items deleted more than
+// olderThan ago and returns how many were removed
+func (h *Handler) PurgeDeleted(olderThan time.Duration) (int, error) {
+	if olderThan <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().UTC().Add(-olderThan)
+
+	var users []model.User
+	var items []model.Item
+	var blobs []string
+	err := h.store.Update(func(tx *store.Tx) error {
+		users = purgeDeleted(tx, store.Users, cutoff, func(u model.User) (int64, *time.Time) {
+			return u.ID, u.DeletedAt
+		})
+		items = purgeDeleted(tx, store.Items, cutoff, func(i model.Item) (int64, *time.Time) {
+			return i.ID, i.DeletedAt
+		})
+		// A purged item's stock ledger, reservations, price history and
+		// attachments go with it
+		purged := make(map[int64]bool, len(items))
+		for _, item := range items {
+			purged[item.ID] = true
+		}
+		for _, m := range store.Movements.Filter(tx, func(m model.StockMovement) bool { return purged[m.ItemID] }) {
+			store.Movements.Delete(tx, m.ID)
+		}
+		for _, res := range store.Reservations.Filter(tx, func(res model.Reservation) bool { return purged[res.ItemID] }) {
+			store.Reservations.Delete(tx, res.ID)
+		}
+		for _, p := range store.Prices.Filter(tx, func(p model.PriceChange) bool { return purged[p.ItemID] }) {
+			store.Prices.Delete(tx, p.ID)
+		}
+		for _, a := range store.Attachments.Filter(tx, func(a model.Attachment) bool { return purged[a.ItemID] }) {
+			store.Attachments.Delete(tx, a.ID)
+			blobs = append(blobs, a.Checksum, a.ThumbnailChecksum)
+		}
+
+		for _, user := range users {
+			if err := appendAudit(tx, systemActor, "", audit.ActionPurge, "user", user.ID, user, nil); err != nil {
+				return err
+			}
+		}
+		for _, item := range items {
+			if err := appendAudit(tx, systemActor, "", audit.ActionPurge, "item", item.ID, item, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	h.removeUnreferencedBlobs(blobs...)
+
+	return len(users) + len(items), nil
+}
+
+// purgeDeleted deletes the rows of t deleted before cutoff
+func purgeDeleted[T any](tx *store.Tx, t store.Table[T], cutoff time.Time, key func(T) (int64, *time.Time)) []T {
+	expired := t.Filter(tx, func(row T) bool {
+		_, deletedAt := key(row)
+		return deletedAt != nil && deletedAt.Before(cutoff)
+	})
+	for _, row := range expired {
+		id, _ := key(row)
+		t.Delete(tx, id)
+	}
+	return expired
+}
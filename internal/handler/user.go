@@ -1,32 +1,38 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
-	"sync"
+	"time"
 
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/middleware"
 	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
 	"github.com/gostructure/app/pkg/response"
 )
 
-// In-memory user storage (replace with database in production)
-var (
-	users     = make(map[int64]*model.User)
-	userMutex sync.RWMutex
-	userIDSeq int64 = 1
-)
-
-// ListUsers returns all users
+// ListUsers returns all users, or those changed since updated_since. Deleted
+// users are only listed for admins passing include_deleted=true.
 func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	userMutex.RLock()
-	defer userMutex.RUnlock()
-
-	userList := make([]*model.User, 0, len(users))
-	for _, user := range users {
-		userList = append(userList, user)
+	include, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+	since, ok := parseUpdatedSince(w, r)
+	if !ok {
+		return
 	}
 
+	var userList []model.User
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		userList = store.Users.Filter(tx, func(u model.User) bool {
+			return (include || u.DeletedAt == nil) && !u.UpdatedAt.Before(since)
+		})
+		return nil
+	})
+
 	response.JSON(w, http.StatusOK, map[string]interface{}{
 		"users": userList,
 		"total": len(userList),
@@ -40,15 +46,25 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
+	include, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
 
-	userMutex.RLock()
-	user, exists := users[id]
-	userMutex.RUnlock()
+	var user model.User
+	var exists bool
+	h.tenantStore(r).View(func(tx *store.Tx) error {
+		user, exists = store.Users.Get(tx, id)
+		return nil
+	})
 
-	if !exists {
+	if !exists || (user.DeletedAt != nil && !include) {
 		response.Error(w, http.StatusNotFound, "User not found")
 		return
 	}
+	if notModified(w, r, user.UpdatedAt) {
+		return
+	}
 
 	response.JSON(w, http.StatusOK, user)
 }
@@ -56,8 +72,7 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 // CreateUser creates a new user
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req model.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -65,16 +80,42 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "Name and email are required")
 		return
 	}
+	if req.Subject == middleware.SourceAnonymous {
+		response.Error(w, http.StatusBadRequest, "Subject is reserved")
+		return
+	}
+	// Binding a user to a principal is reserved to admins
+	if req.Subject != "" && !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
 
-	userMutex.Lock()
-	user := &model.User{
-		ID:    userIDSeq,
-		Name:  req.Name,
-		Email: req.Email,
+	var user model.User
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		if err := checkUserQuota(tx, h.tenantConfig(r), 1); err != nil {
+			return err
+		}
+		if err := checkSubject(tx, 0, req.Subject); err != nil {
+			return err
+		}
+		var err error
+		user, err = store.Users.Insert(tx, func(id int64) model.User {
+			return model.User{
+				ID:      id,
+				Name:    req.Name,
+				Email:   req.Email,
+				Subject: req.Subject,
+			}
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("user", user.ID, events.ActionCreated, user)
+		return recordAudit(tx, r, audit.ActionCreate, "user", user.ID, nil, user)
+	})
+	if err != nil {
+		writeStoreError(w, err, "User")
+		return
 	}
-	users[userIDSeq] = user
-	userIDSeq++
-	userMutex.Unlock()
 
 	response.JSON(w, http.StatusCreated, user)
 }
@@ -88,48 +129,157 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req model.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Error(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-
-	userMutex.Lock()
-	defer userMutex.Unlock()
-
-	user, exists := users[id]
-	if !exists {
-		response.Error(w, http.StatusNotFound, "User not found")
+	if req.Subject == middleware.SourceAnonymous {
+		response.Error(w, http.StatusBadRequest, "Subject is reserved")
 		return
 	}
-
-	if req.Name != "" {
-		user.Name = req.Name
+	if req.Subject != "" && !requireRole(w, r, middleware.RoleAdmin) {
+		return
 	}
-	if req.Email != "" {
-		user.Email = req.Email
+
+	var before, user model.User
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Users.Get(tx, id)
+		if !exists || before.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkSelf(r, before); err != nil {
+			return err
+		}
+
+		user = before
+		if req.Name != "" {
+			user.Name = req.Name
+		}
+		if req.Email != "" {
+			user.Email = req.Email
+		}
+		if req.Subject != "" {
+			if err := checkSubject(tx, id, req.Subject); err != nil {
+				return err
+			}
+			user.Subject = req.Subject
+		}
+
+		var err error
+		if user, err = store.Users.Save(tx, id, user); err != nil {
+			return err
+		}
+		tx.Emit("user", id, events.ActionUpdated, user)
+		return recordAudit(tx, r, audit.ActionUpdate, "user", id, before, user)
+	})
+	if err != nil {
+		writeStoreError(w, err, "User")
+		return
 	}
 
 	response.JSON(w, http.StatusOK, user)
 }
 
-// DeleteUser deletes a user
+// DeleteUser moves a user to the trash, from which it can be restored
+// until it is purged. ?items= says what happens to the items the user owns:
+// block the deletion, orphan them or reassign them to ?reassign_to=.
 func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
 		response.Error(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
+	policy, target, ok := h.ownedItemsPolicy(w, r)
+	if !ok {
+		return
+	}
 
-	userMutex.Lock()
-	defer userMutex.Unlock()
+	var before, user model.User
+	err = h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Users.Get(tx, id)
+		if !exists || before.DeletedAt != nil {
+			return store.ErrNotFound
+		}
+		if err := checkSelf(r, before); err != nil {
+			return err
+		}
+		itemsBefore, itemsAfter, err := releaseItems(tx, id, policy, target)
+		if err != nil {
+			return err
+		}
+		for i, item := range itemsAfter {
+			if err := recordAudit(tx, r, audit.ActionUpdate, "item", item.ID, itemsBefore[i], item); err != nil {
+				return err
+			}
+		}
 
-	if _, exists := users[id]; !exists {
-		response.Error(w, http.StatusNotFound, "User not found")
+		now := time.Now().UTC()
+		user = before
+		user.DeletedAt = &now
+		if user, err = store.Users.Save(tx, id, user); err != nil {
+			return err
+		}
+		tx.Emit("user", id, events.ActionDeleted, user)
+		return recordAudit(tx, r, audit.ActionDelete, "user", id, before, user)
+	})
+	if err != nil {
+		writeStoreError(w, err, "User")
 		return
 	}
 
-	delete(users, id)
 	response.JSON(w, http.StatusOK, map[string]string{
 		"message": "User deleted successfully",
 	})
 }
+
+// UserAction handles custom methods on a user, such as
+// POST /api/v1/users/{id}:restore
+func (h *Handler) UserAction(w http.ResponseWriter, r *http.Request) {
+	id, action, ok := parseAction(w, r, "User")
+	if !ok {
+		return
+	}
+
+	switch action {
+	case "restore":
+		h.restoreUser(w, r, id)
+	default:
+		response.Error(w, http.StatusNotFound, "Unknown action")
+	}
+}
+
+func (h *Handler) restoreUser(w http.ResponseWriter, r *http.Request, id int64) {
+	var before, user model.User
+	err := h.tenantStore(r).UpdateAs(actor(r), func(tx *store.Tx) error {
+		var exists bool
+		before, exists = store.Users.Get(tx, id)
+		if !exists {
+			return store.ErrNotFound
+		}
+		if before.DeletedAt == nil {
+			return errNotDeleted
+		}
+		if err := checkSelf(r, before); err != nil {
+			return err
+		}
+		if err := checkUserQuota(tx, h.tenantConfig(r), 1); err != nil {
+			return err
+		}
+
+		user = before
+		user.DeletedAt = nil
+		var err error
+		if user, err = store.Users.Save(tx, id, user); err != nil {
+			return err
+		}
+		tx.Emit("user", id, events.ActionRestored, user)
+		return recordAudit(tx, r, audit.ActionRestore, "user", id, before, user)
+	})
+	if err != nil {
+		writeStoreError(w, err, "User")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, user)
+}
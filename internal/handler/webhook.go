@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gostructure/app/internal/middleware"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/webhook"
+	"github.com/gostructure/app/pkg/response"
+)
+
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 1000
+)
+
+// ListWebhooks returns the webhook subscriptions of the request tenant
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	subs := make([]webhook.Subscription, 0)
+	for _, sub := range h.webhooks.List() {
+		if sub.Tenant == tenant(r) {
+			sub.Secret = ""
+			subs = append(subs, sub)
+		}
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"webhooks": subs,
+		"total":    len(subs),
+	})
+}
+
+// GetWebhook returns a webhook subscription without its secret
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	sub, ok := h.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	sub.Secret = ""
+	response.JSON(w, http.StatusOK, sub)
+}
+
+// CreateWebhook registers a webhook subscription. The signing secret is
+// only returned in this response.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	var req model.CreateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	sub := webhook.Subscription{
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+		Active: req.Active == nil || *req.Active,
+		Tenant: tenant(r),
+	}
+	created, err := h.webhooks.Create(sub)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, created)
+}
+
+// UpdateWebhook updates a webhook subscription
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	var req model.UpdateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if _, ok := h.findWebhook(w, r); !ok {
+		return
+	}
+
+	updated, err := h.webhooks.Update(r.PathValue("id"), func(s *webhook.Subscription) {
+		if req.URL != "" {
+			s.URL = req.URL
+		}
+		if req.Secret != "" {
+			s.Secret = req.Secret
+		}
+		if req.Events != nil {
+			s.Events = req.Events
+		}
+		if req.Active != nil {
+			s.Active = *req.Active
+		}
+	})
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	updated.Secret = ""
+	response.JSON(w, http.StatusOK, updated)
+}
+
+// DeleteWebhook removes a webhook subscription and its delivery log
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if _, ok := h.findWebhook(w, r); !ok {
+		return
+	}
+	if err := h.webhooks.Delete(r.PathValue("id")); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries returns the delivery log of a subscription
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultDeliveryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDeliveryLimit {
+			response.Error(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	if _, ok := h.findWebhook(w, r); !ok {
+		return
+	}
+	deliveries, err := h.webhooks.Deliveries(r.PathValue("id"), q.Get("status"), limit)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"total":      len(deliveries),
+	})
+}
+
+// RedeliverWebhook queues a delivery to be sent again
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if _, ok := h.findWebhook(w, r); !ok {
+		return
+	}
+	delivery, err := h.webhooks.Redeliver(r.PathValue("id"), r.PathValue("deliveryID"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, delivery)
+}
+
+// findWebhook returns the subscription named by the path, writing a 404
+// response if it does not exist or belongs to another tenant
+func (h *Handler) findWebhook(w http.ResponseWriter, r *http.Request) (webhook.Subscription, bool) {
+	sub, err := h.webhooks.Get(r.PathValue("id"))
+	if err == nil && sub.Tenant != tenant(r) {
+		err = webhook.ErrNotFound
+	}
+	if err != nil {
+		writeWebhookError(w, err)
+		return webhook.Subscription{}, false
+	}
+	return sub, true
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		response.Error(w, http.StatusNotFound, "Webhook not found")
+	case errors.Is(err, webhook.ErrInvalidURL):
+		response.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, "Webhook operation failed")
+	}
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gostructure/app/internal/store"
+)
+
+// Job states
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// DefaultQueue is used for job types registered without a queue
+const DefaultQueue = "default"
+
+// ErrUnknownType is returned when enqueueing a job type with no handler
+var ErrUnknownType = errors.New("unknown job type")
+
+// Job is a unit of background work persisted in the store. Jobs enqueued
+// for a tenant run with its data only.
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Queue       string          `json:"queue"`
+	Owner       string          `json:"owner,omitempty"`
+	Tenant      string          `json:"tenant,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Decode unmarshals the job payload into v
+func (j Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// TenantID returns the tenant the job runs for
+func (j Job) TenantID() string {
+	return j.Tenant
+}
+
+// SetTenant assigns the job to tenant
+func (j *Job) SetTenant(tenant string) {
+	j.Tenant = tenant
+}
+
+// Done reports whether the job has finished, successfully or not
+func (j Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Table holds jobs so they survive restarts
+var Table = store.NewTable[Job]("jobs")
+
+// Complete marks job succeeded with result in tx. Handlers whose work is not
+// safe to repeat call it in the transaction that commits the work, so a job
+// interrupted before the manager records its result is not run again.
+func Complete(tx *store.Tx, job Job, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	finished := time.Now().UTC()
+	job.Status = StatusSucceeded
+	job.Result = data
+	job.Error = ""
+	job.FinishedAt = &finished
+	return Table.Put(tx, job.ID, job)
+}
+
+// HandlerFunc runs a job. The returned result is stored as JSON. ctx is
+// cancelled when the job times out.
+type HandlerFunc func(ctx context.Context, job Job) (interface{}, error)
+
+// permanentError marks failures that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further retries
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
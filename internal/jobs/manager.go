@@ -0,0 +1,391 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/store"
+)
+
+const (
+	defaultWorkers          = 4
+	defaultQueueConcurrency = 2
+	defaultTimeout          = 5 * time.Minute
+	defaultMaxAttempts      = 3
+	defaultInitialBackoff   = time.Second
+	defaultMaxBackoff       = 5 * time.Minute
+	defaultPollInterval     = time.Second
+)
+
+type jobType struct {
+	handler     HandlerFunc
+	queue       string
+	timeout     time.Duration
+	maxAttempts int
+}
+
+// Option configures a registered job type
+type Option func(*jobType)
+
+// WithQueue runs jobs of the type on the named queue
+func WithQueue(queue string) Option {
+	return func(t *jobType) {
+		t.queue = queue
+	}
+}
+
+// WithTimeout bounds each attempt of the job type
+func WithTimeout(d time.Duration) Option {
+	return func(t *jobType) {
+		t.timeout = d
+	}
+}
+
+// WithMaxAttempts sets how many times a failing job is attempted
+func WithMaxAttempts(n int) Option {
+	return func(t *jobType) {
+		t.maxAttempts = n
+	}
+}
+
+// Manager persists jobs in the store and runs them on a bounded worker
+// pool. Each queue has its own concurrency limit within the pool.
+type Manager struct {
+	cfg   config.JobsConfig
+	store *store.Store
+
+	mu      sync.Mutex
+	types   map[string]jobType
+	running map[string]int
+	total   int
+	wake    chan struct{}
+}
+
+// NewManager creates a job manager. Zero config values use defaults.
+func NewManager(cfg config.JobsConfig, s *store.Store) *Manager {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	return &Manager{
+		cfg:     cfg,
+		store:   s,
+		types:   make(map[string]jobType),
+		running: make(map[string]int),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for a job type
+func (m *Manager) Register(name string, fn HandlerFunc, opts ...Option) {
+	t := jobType{
+		handler:     fn,
+		queue:       DefaultQueue,
+		timeout:     m.cfg.Timeout,
+		maxAttempts: m.cfg.MaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.types[name] = t
+}
+
+// Enqueue persists a job of the given type for owner and wakes the workers
+func (m *Manager) Enqueue(name string, payload interface{}, owner string) (Job, error) {
+	return m.EnqueueFor("", name, payload, owner)
+}
+
+// EnqueueFor persists a job like Enqueue that runs for tenant
+func (m *Manager) EnqueueFor(tenant, name string, payload interface{}, owner string) (Job, error) {
+	var job Job
+	err := m.store.Scoped(tenant).Update(func(tx *store.Tx) error {
+		var err error
+		job, err = m.EnqueueTx(tx, name, payload, owner)
+		return err
+	})
+	if err != nil {
+		return Job{}, err
+	}
+
+	m.notify()
+	return job, nil
+}
+
+// EnqueueTx adds a job within an existing transaction, so it is only
+// queued if the transaction commits. Workers pick it up on their next poll.
+func (m *Manager) EnqueueTx(tx *store.Tx, name string, payload interface{}, owner string) (Job, error) {
+	m.mu.Lock()
+	t, ok := m.types[name]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownType, name)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now().UTC()
+	return Table.Insert(tx, func(id int64) Job {
+		return Job{
+			ID:          id,
+			Type:        name,
+			Queue:       t.queue,
+			Owner:       owner,
+			Payload:     data,
+			Status:      StatusQueued,
+			MaxAttempts: t.maxAttempts,
+			RunAt:       now,
+			CreatedAt:   now,
+		}
+	})
+}
+
+// Get returns a job by ID
+func (m *Manager) Get(id int64) (Job, bool) {
+	var job Job
+	var ok bool
+	m.store.View(func(tx *store.Tx) error {
+		job, ok = Table.Get(tx, id)
+		return nil
+	})
+	return job, ok
+}
+
+// Purge deletes finished jobs older than the retention period and
+// returns how many were removed
+func (m *Manager) Purge() (int, error) {
+	if m.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().UTC().Add(-m.cfg.Retention)
+
+	var purged int
+	err := m.store.Update(func(tx *store.Tx) error {
+		expired := Table.Filter(tx, func(j Job) bool {
+			return j.Done() && j.FinishedAt != nil && j.FinishedAt.Before(cutoff)
+		})
+		for _, job := range expired {
+			Table.Delete(tx, job.ID)
+		}
+		purged = len(expired)
+		return nil
+	})
+	return purged, err
+}
+
+// Run executes due jobs until ctx is cancelled. Jobs interrupted by
+// cancellation are queued again without counting the attempt, as are jobs
+// left running by a previous process.
+func (m *Manager) Run(ctx context.Context) {
+	m.requeueInterrupted()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(m.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, job := range m.claim() {
+			wg.Add(1)
+			go func(job Job) {
+				defer wg.Done()
+				m.execute(ctx, job)
+			}(job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+// claim marks due jobs as running while workers and queue slots are free
+func (m *Manager) claim() []Job {
+	var claimed []Job
+	now := time.Now().UTC()
+
+	err := m.store.Update(func(tx *store.Tx) error {
+		due := Table.Filter(tx, func(j Job) bool {
+			return j.Status == StatusQueued && !j.RunAt.After(now)
+		})
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].RunAt.Before(due[j].RunAt)
+		})
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		for _, job := range due {
+			if m.total >= m.cfg.Workers {
+				break
+			}
+			if _, ok := m.types[job.Type]; !ok || m.running[job.Queue] >= m.queueLimit(job.Queue) {
+				continue
+			}
+
+			started := now
+			job.Status = StatusRunning
+			job.Attempts++
+			job.StartedAt = &started
+			if err := Table.Put(tx, job.ID, job); err != nil {
+				return err
+			}
+			m.running[job.Queue]++
+			m.total++
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to claim jobs: %v", err)
+		m.release(claimed)
+		return nil
+	}
+	return claimed
+}
+
+func (m *Manager) execute(ctx context.Context, job Job) {
+	defer m.release([]Job{job})
+
+	m.mu.Lock()
+	t := m.types[job.Type]
+	m.mu.Unlock()
+
+	jobCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	result, err := runHandler(jobCtx, t.handler, job)
+	if err == nil {
+		job.Result, err = json.Marshal(result)
+	}
+	if err != nil && errors.Is(jobCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %v: %w", t.timeout, err)
+	}
+
+	finished := time.Now().UTC()
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.Error = ""
+		job.FinishedAt = &finished
+	case ctx.Err() != nil:
+		// Shutting down; run the job again on the next start
+		job.Status = StatusQueued
+		job.Attempts--
+		job.StartedAt = nil
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		job.FinishedAt = &finished
+		log.Printf("Job %d (%s) failed after %d attempt(s): %v", job.ID, job.Type, job.Attempts, err)
+	default:
+		job.Status = StatusQueued
+		job.Error = err.Error()
+		job.RunAt = finished.Add(m.backoff(job.Attempts))
+	}
+
+	err = m.store.Update(func(tx *store.Tx) error {
+		// A job completed by its handler keeps that result
+		if stored, ok := Table.Get(tx, job.ID); ok && stored.Done() {
+			return nil
+		}
+		return Table.Put(tx, job.ID, job)
+	})
+	if err != nil {
+		log.Printf("Failed to save job %d: %v", job.ID, err)
+	}
+}
+
+// runHandler converts panics in job handlers into errors
+func runHandler(ctx context.Context, fn HandlerFunc, job Job) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(ctx, job)
+}
+
+func (m *Manager) release(jobs []Job) {
+	m.mu.Lock()
+	for _, job := range jobs {
+		m.running[job.Queue]--
+		m.total--
+	}
+	m.mu.Unlock()
+	m.notify()
+}
+
+func (m *Manager) requeueInterrupted() {
+	err := m.store.Update(func(tx *store.Tx) error {
+		for _, job := range Table.Filter(tx, func(j Job) bool { return j.Status == StatusRunning }) {
+			job.Status = StatusQueued
+			job.Attempts--
+			job.StartedAt = nil
+			if err := Table.Put(tx, job.ID, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to requeue interrupted jobs: %v", err)
+	}
+}
+
+func (m *Manager) queueLimit(queue string) int {
+	if n, ok := m.cfg.Queues[queue]; ok && n > 0 {
+		return n
+	}
+	return defaultQueueConcurrency
+}
+
+// backoff doubles the retry delay after every failed attempt
+func (m *Manager) backoff(attempts int) time.Duration {
+	delay := m.cfg.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= m.cfg.MaxBackoff {
+			return m.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultBuckets are the request duration histogram bounds in seconds
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Registry collects HTTP and runtime metrics and renders them in the
+// Prometheus text exposition format
+type Registry struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+	inFlight  atomic.Int64
+	startTime time.Time
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+		startTime: time.Now(),
+	}
+}
+
+// IncInFlight adjusts the number of requests currently being served
+func (r *Registry) IncInFlight(delta int64) {
+	r.inFlight.Add(delta)
+}
+
+// ObserveRequest records a completed request. Route should be the matched
+// pattern rather than the raw path to keep label cardinality bounded.
+func (r *Registry) ObserveRequest(method, route string, status int, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[requestKey{method: method, route: route, status: status}]++
+
+	h, ok := r.durations[route]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(defaultBuckets))}
+		r.durations[route] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range defaultBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Handler serves the metrics in Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// WriteTo writes all metrics to w
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	r.mu.Lock()
+	keys := make([]requestKey, 0, len(r.requests))
+	for k := range r.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	b.WriteString("# HELP http_requests_total Total number of HTTP requests.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "http_requests_total{method=%q,route=%q,status=\"%d\"} %d\n", k.method, k.route, k.status, r.requests[k])
+	}
+
+	routes := make([]string, 0, len(r.durations))
+	for route := range r.durations {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	b.WriteString("# HELP http_request_duration_seconds HTTP request latency.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	for _, route := range routes {
+		h := r.durations[route]
+		for i, bound := range defaultBuckets {
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{route=%q,le=%q} %d\n", route, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, h.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{route=%q} %s\n", route, formatFloat(h.sum))
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{route=%q} %d\n", route, h.count)
+	}
+	r.mu.Unlock()
+
+	b.WriteString("# HELP http_requests_in_flight Requests currently being served.\n")
+	b.WriteString("# TYPE http_requests_in_flight gauge\n")
+	fmt.Fprintf(&b, "http_requests_in_flight %d\n", r.inFlight.Load())
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	b.WriteString("# HELP go_goroutines Number of goroutines.\n")
+	b.WriteString("# TYPE go_goroutines gauge\n")
+	fmt.Fprintf(&b, "go_goroutines %d\n", runtime.NumGoroutine())
+	b.WriteString("# HELP go_memstats_heap_alloc_bytes Heap bytes allocated and in use.\n")
+	b.WriteString("# TYPE go_memstats_heap_alloc_bytes gauge\n")
+	fmt.Fprintf(&b, "go_memstats_heap_alloc_bytes %d\n", mem.HeapAlloc)
+	b.WriteString("# HELP go_gc_cycles_total Completed GC cycles.\n")
+	b.WriteString("# TYPE go_gc_cycles_total counter\n")
+	fmt.Fprintf(&b, "go_gc_cycles_total %d\n", mem.NumGC)
+	b.WriteString("# HELP process_start_time_seconds Start time of the process since unix epoch.\n")
+	b.WriteString("# TYPE process_start_time_seconds gauge\n")
+	fmt.Fprintf(&b, "process_start_time_seconds %d\n", r.startTime.Unix())
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// ClientIdentityKey is the context key for the verified client certificate identity
+type ClientIdentityKey struct{}
+
+// ClientIdentity describes the verified client certificate of an mTLS request
+type ClientIdentity struct {
+	Subject      string   `json:"subject"`
+	CommonName   string   `json:"common_name"`
+	Issuer       string   `json:"issuer"`
+	SerialNumber string   `json:"serial_number"`
+	DNSNames     []string `json:"dns_names,omitempty"`
+	Emails       []string `json:"emails,omitempty"`
+	URIs         []string `json:"uris,omitempty"`
+}
+
+// ClientCert middleware exposes the verified client certificate identity to
+// handlers. Unverified or absent certificates leave the context untouched.
+func ClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := r.TLS.VerifiedChains[0][0]
+		identity := &ClientIdentity{
+			Subject:      cert.Subject.String(),
+			CommonName:   cert.Subject.CommonName,
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.String(),
+			DNSNames:     cert.DNSNames,
+			Emails:       cert.EmailAddresses,
+		}
+		for _, u := range cert.URIs {
+			identity.URIs = append(identity.URIs, u.String())
+		}
+
+		ctx := context.WithValue(r.Context(), ClientIdentityKey{}, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetClientIdentity retrieves the verified client identity from context
+func GetClientIdentity(ctx context.Context) *ClientIdentity {
+	if identity, ok := ctx.Value(ClientIdentityKey{}).(*ClientIdentity); ok {
+		return identity
+	}
+	return nil
+}
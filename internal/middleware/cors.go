@@ -2,22 +2,250 @@ package middleware
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/pkg/response"
+)
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"}
 )
 
-// CORS middleware adds CORS headers to responses
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// corsPolicy is a compiled CORS policy for a set of routes
+type corsPolicy struct {
+	prefix           string
+	anyOrigin        bool
+	origins          map[string]bool
+	patterns         []originPattern
+	methods          []string
+	headers          map[string]bool
+	anyHeader        bool
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// originPattern matches origins such as "https://*.example.com"
+type originPattern struct {
+	prefix string
+	suffix string
+}
+
+func (p originPattern) match(origin string) bool {
+	return len(origin) > len(p.prefix)+len(p.suffix) &&
+		strings.HasPrefix(origin, p.prefix) &&
+		strings.HasSuffix(origin, p.suffix)
+}
+
+// CORS middleware applies the configured cross-origin policy. Requests
+// without an Origin header pass through untouched, and only genuine
+// preflight requests are answered by the middleware itself.
+func CORS(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	base := newCORSPolicy(cfg, "", cfg.AllowedOrigins, cfg.AllowedMethods, cfg.AllowedHeaders, cfg.AllowCredentials)
+
+	routes := make([]*corsPolicy, 0, len(cfg.Routes))
+	for _, rc := range cfg.Routes {
+		origins := rc.AllowedOrigins
+		if len(origins) == 0 {
+			origins = cfg.AllowedOrigins
+		}
+		methods := rc.AllowedMethods
+		if len(methods) == 0 {
+			methods = cfg.AllowedMethods
+		}
+		headers := rc.AllowedHeaders
+		if len(headers) == 0 {
+			headers = cfg.AllowedHeaders
+		}
+		routes = append(routes, newCORSPolicy(cfg, rc.PathPrefix, origins, methods, headers, rc.AllowCredentials))
+	}
+	// Longest prefix wins
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := base
+			for _, rp := range routes {
+				if rp.matchPath(r.URL.Path) {
+					policy = rp
+					break
+				}
+			}
+
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// Responses differ per origin unless the policy is a plain wildcard
+			if !policy.anyOrigin || policy.allowCredentials {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !policy.allowOrigin(origin) {
+				if preflight {
+					response.Error(w, http.StatusForbidden, "Origin not allowed")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if preflight {
+				policy.handlePreflight(w, r, origin)
+				return
+			}
+
+			policy.setOriginHeaders(w, origin)
+			if policy.exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newCORSPolicy(cfg config.CORSConfig, prefix string, origins, methods, headers []string, credentials bool) *corsPolicy {
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	p := &corsPolicy{
+		prefix:           prefix,
+		origins:          make(map[string]bool),
+		methods:          methods,
+		headers:          make(map[string]bool),
+		allowMethods:     strings.Join(methods, ", "),
+		allowHeaders:     strings.Join(headers, ", "),
+		exposeHeaders:    strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: credentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	for _, o := range origins {
+		switch {
+		case o == "*":
+			p.anyOrigin = true
+		case strings.Contains(o, "*"):
+			before, after, _ := strings.Cut(strings.ToLower(o), "*")
+			p.patterns = append(p.patterns, originPattern{prefix: before, suffix: after})
+		default:
+			p.origins[strings.ToLower(o)] = true
+		}
+	}
+	for _, h := range headers {
+		if h == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[strings.ToLower(h)] = true
+	}
+
+	return p
+}
+
+// matchPath reports whether path is the policy's prefix or below it, so
+// "/api/v1/items" covers "/api/v1/items/1" but not "/api/v1/itemsfoo"
+func (p *corsPolicy) matchPath(path string) bool {
+	prefix := strings.TrimSuffix(p.prefix, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return rest == "" || rest[0] == '/'
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	return p.anyOrigin || p.listedOrigin(origin)
+}
+
+// listedOrigin reports whether origin is allowed by name or pattern rather
+// than by the "*" wildcard
+func (p *corsPolicy) listedOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) allowMethod(method string) bool {
+	for _, m := range p.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// setOriginHeaders allows origin. Credentials are only ever allowed for
+// listed origins: an origin admitted by "*" gets the literal wildcard, which
+// browsers refuse for credentialed requests.
+func (p *corsPolicy) setOriginHeaders(w http.ResponseWriter, origin string) {
+	if p.allowCredentials && p.listedOrigin(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		return
+	}
+	if p.anyOrigin {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-Request-ID")
-		w.Header().Set("Access-Control-Max-Age", "86400")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
+
+// handlePreflight validates the requested method and headers and answers
+// the preflight request without reaching the router
+func (p *corsPolicy) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if !p.allowMethod(r.Header.Get("Access-Control-Request-Method")) {
+		response.Error(w, http.StatusForbidden, "Method not allowed by CORS policy")
+		return
+	}
 
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" && !p.anyHeader && !p.headers[h] {
+			response.Error(w, http.StatusForbidden, "Header not allowed by CORS policy")
 			return
 		}
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	p.setOriginHeaders(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", p.allowMethods)
+	if p.anyHeader && requested != "" {
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+	} else {
+		w.Header().Set("Access-Control-Allow-Headers", p.allowHeaders)
+	}
+	if p.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InFlightRequest describes a request that has not completed yet
+type InFlightRequest struct {
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	RequestID string        `json:"request_id"`
+	Started   time.Time     `json:"started"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// InFlightTracker records requests currently being served so shutdown can
+// report what was still running when its deadline expired
+type InFlightTracker struct {
+	mu       sync.Mutex
+	nextID   atomic.Uint64
+	requests map[uint64]InFlightRequest
+}
+
+// NewInFlightTracker creates an empty tracker
+func NewInFlightTracker() *InFlightTracker {
+	return &InFlightTracker{
+		requests: make(map[uint64]InFlightRequest),
+	}
+}
+
+// Track middleware registers each request for the duration of the handler
+func (t *InFlightTracker) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := t.nextID.Add(1)
+
+		t.mu.Lock()
+		t.requests[id] = InFlightRequest{
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			RequestID: GetRequestID(r.Context()),
+			Started:   time.Now(),
+		}
+		t.mu.Unlock()
+
+		defer func() {
+			t.mu.Lock()
+			delete(t.requests, id)
+			t.mu.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Count returns the number of requests in flight
+func (t *InFlightTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.requests)
+}
+
+// Snapshot returns the requests in flight, oldest first
+func (t *InFlightTracker) Snapshot() []InFlightRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	list := make([]InFlightRequest, 0, len(t.requests))
+	for _, req := range t.requests {
+		req.Duration = now.Sub(req.Started)
+		list = append(list, req)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Started.Before(list[j].Started)
+	})
+	return list
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gostructure/app/internal/metrics"
+)
+
+// Metrics middleware records request counts and latency per route. It must
+// wrap the router directly so the matched pattern is visible afterwards.
+func Metrics(registry *metrics.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			registry.IncInFlight(1)
+			defer registry.IncInFlight(-1)
+
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+			registry.ObserveRequest(r.Method, route, wrapped.statusCode, time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gostructure/app/internal/config"
+)
+
+// PrincipalKey is the context key for the authenticated principal
+type PrincipalKey struct{}
+
+// Principal sources
+const (
+	SourceAnonymous   = "anonymous"
+	SourceCertificate = "certificate"
+	SourceHeader      = "header"
+)
+
+// RoleAdmin grants access to administrative endpoints
+const RoleAdmin = "admin"
+
+// Principal identifies who is making a request. Tenant is the tenant its
+// credentials are bound to, if any.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles,omitempty"`
+	Tenant  string   `json:"tenant,omitempty"`
+	Source  string   `json:"source"`
+}
+
+// HasRole reports whether the principal holds role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+var anonymous = &Principal{Subject: SourceAnonymous, Source: SourceAnonymous}
+
+// Authenticate middleware resolves the request principal from a verified
+// client certificate, or from identity headers set by a trusted proxy.
+// Requests without credentials proceed as the anonymous principal.
+func Authenticate(cfg config.AuthConfig) func(http.Handler) http.Handler {
+	admins := make(map[string]bool, len(cfg.AdminSubjects))
+	for _, s := range cfg.AdminSubjects {
+		admins[s] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p *Principal
+
+			if identity := GetClientIdentity(r.Context()); identity != nil {
+				p = &Principal{Subject: identity.CommonName, Source: SourceCertificate}
+			} else if cfg.TrustHeaders && cfg.SubjectHeader != "" {
+				if subject := r.Header.Get(cfg.SubjectHeader); subject != "" {
+					p = &Principal{Subject: subject, Source: SourceHeader}
+					if cfg.RolesHeader != "" {
+						for _, role := range strings.Split(r.Header.Get(cfg.RolesHeader), ",") {
+							if role = strings.TrimSpace(role); role != "" {
+								p.Roles = append(p.Roles, role)
+							}
+						}
+					}
+					if cfg.TenantHeader != "" {
+						p.Tenant = strings.TrimSpace(r.Header.Get(cfg.TenantHeader))
+					}
+				}
+			}
+
+			if p == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if admins[p.Subject] && !p.HasRole(RoleAdmin) {
+				p.Roles = append(p.Roles, RoleAdmin)
+			}
+
+			ctx := context.WithValue(r.Context(), PrincipalKey{}, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetPrincipal retrieves the principal from context, defaulting to anonymous
+func GetPrincipal(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(PrincipalKey{}).(*Principal); ok {
+		return p
+	}
+	return anonymous
+}
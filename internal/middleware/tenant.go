@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/pkg/response"
+)
+
+// TenantKey is the context key for the request tenant
+type TenantKey struct{}
+
+// Tenant middleware resolves the tenant of API requests from the
+// principal's tenant claim, the tenant header or the subdomain. A header or
+// subdomain naming another tenant than the claim is rejected, so a
+// principal cannot act for another tenant. Requests outside /api/ and all
+// requests when tenancy is disabled pass through without a tenant.
+func Tenant(cfg config.TenancyConfig) func(http.Handler) http.Handler {
+	known := make(map[string]bool, len(cfg.Tenants))
+	for _, t := range cfg.Tenants {
+		known[t] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claim := GetPrincipal(r.Context()).Tenant
+			var requested string
+			if cfg.Header != "" {
+				requested = strings.TrimSpace(r.Header.Get(cfg.Header))
+			}
+			if sub := subdomain(r.Host, cfg.BaseDomain); sub != "" {
+				if requested != "" && requested != sub {
+					response.Error(w, http.StatusBadRequest, "Conflicting tenants requested")
+					return
+				}
+				requested = sub
+			}
+
+			tenant := claim
+			switch {
+			case claim != "" && requested != "" && requested != claim:
+				response.Error(w, http.StatusForbidden, "Tenant does not match credentials")
+				return
+			case tenant == "":
+				tenant = requested
+			}
+
+			if tenant == "" {
+				response.Error(w, http.StatusBadRequest, "Tenant is required")
+				return
+			}
+			if !ValidTenant(tenant) {
+				response.Error(w, http.StatusBadRequest, "Invalid tenant")
+				return
+			}
+			if len(known) > 0 && !known[tenant] {
+				response.Error(w, http.StatusNotFound, "Unknown tenant")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), TenantKey{}, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ValidTenant reports whether id is a valid tenant ID: 1 to 63 lowercase
+// letters, digits and hyphens, usable as a DNS label
+func ValidTenant(id string) bool {
+	if id == "" || len(id) > 63 || id[0] == '-' || id[len(id)-1] == '-' {
+		return false
+	}
+	for _, c := range id {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// GetTenant retrieves the request tenant from context, or "" when tenancy
+// is disabled
+func GetTenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(TenantKey{}).(string)
+	return tenant
+}
+
+// subdomain returns the part of host before baseDomain
+func subdomain(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	sub, ok := strings.CutSuffix(host, "."+strings.ToLower(baseDomain))
+	if !ok {
+		return ""
+	}
+	return sub
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Alert types
+const (
+	AlertLowStock = "low_stock"
+)
+
+// Alert statuses
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+)
+
+// Alert reports an item whose stock fell below its reorder threshold. An
+// item has at most one unresolved alert; it is resolved once the stock is
+// back at the threshold.
+type Alert struct {
+	ID             int64      `json:"id"`
+	ItemID         int64      `json:"item_id"`
+	Type           string     `json:"type"`
+	Status         string     `json:"status"`
+	Quantity       int        `json:"quantity"`
+	Threshold      int        `json:"threshold"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	Meta
+}
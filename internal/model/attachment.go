@@ -0,0 +1,19 @@
+package model
+
+// Attachment is a file attached to an item. The contents are stored once
+// per checksum, however many attachments share them.
+type Attachment struct {
+	ID          int64  `json:"id"`
+	ItemID      int64  `json:"item_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// Checksum is the hex SHA-256 of the contents
+	Checksum string `json:"checksum"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	// ThumbnailChecksum names the scaled-down preview of an image
+	ThumbnailChecksum    string `json:"thumbnail_checksum,omitempty"`
+	ThumbnailContentType string `json:"thumbnail_content_type,omitempty"`
+	Meta
+}
@@ -0,0 +1,42 @@
+package model
+
+// Category is a node in the item category tree. Root categories have no
+// ParentID.
+type Category struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID int64  `json:"parent_id,omitempty"`
+	Meta
+}
+
+// CreateCategoryRequest represents a request to create a category. The slug
+// is derived from the name if omitted.
+type CreateCategoryRequest struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug,omitempty"`
+	ParentID int64  `json:"parent_id,omitempty"`
+}
+
+// UpdateCategoryRequest represents a request to rename a category
+type UpdateCategoryRequest struct {
+	Name string `json:"name,omitempty"`
+	Slug string `json:"slug,omitempty"`
+}
+
+// MoveCategoryRequest represents a request to move a category under another
+// parent, or to the root if ParentID is zero
+type MoveCategoryRequest struct {
+	ParentID int64 `json:"parent_id"`
+}
+
+// SetItemCategoryRequest represents a request to assign an item to a
+// category, or to none if CategoryID is zero
+type SetItemCategoryRequest struct {
+	CategoryID int64 `json:"category_id"`
+}
+
+// ItemTagsRequest represents tags to set on, add to or remove from an item
+type ItemTagsRequest struct {
+	Tags []string `json:"tags"`
+}
@@ -0,0 +1,28 @@
+package model
+
+// Stock movement types
+const (
+	MovementReceipt    = "receipt"
+	MovementSale       = "sale"
+	MovementCorrection = "correction"
+)
+
+// StockMovement is an entry in an item's append-only inventory ledger.
+// Quantity is the signed change and Balance the item's quantity after it.
+type StockMovement struct {
+	ID       int64  `json:"id"`
+	ItemID   int64  `json:"item_id"`
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+	Balance  int    `json:"balance"`
+	Reason   string `json:"reason,omitempty"`
+	Meta
+}
+
+// StockAdjustmentRequest represents a request to move stock. Quantity is
+// positive for receipts and sales and signed for corrections.
+type StockAdjustmentRequest struct {
+	Type     string `json:"type"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason"`
+}
@@ -1,26 +1,48 @@
 package model
 
-// Item represents an item entity
+import (
+	"time"
+
+	"github.com/gostructure/app/pkg/money"
+)
+
+// Item represents an item entity. Deleted items keep their record with
+// DeletedAt set until they are purged. A low-stock alert is raised when
+// Quantity falls below a non-zero ReorderThreshold. OwnerID is the user who
+// created the item, or zero if it has no owner.
 type Item struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	ID               int64       `json:"id"`
+	Name             string      `json:"name"`
+	Description      string      `json:"description"`
+	Price            money.Money `json:"price"`
+	Quantity         int         `json:"quantity"`
+	ReorderThreshold int         `json:"reorder_threshold,omitempty"`
+	CategoryID       int64       `json:"category_id,omitempty"`
+	Tags             []string    `json:"tags,omitempty"`
+	OwnerID          int64       `json:"owner_id,omitempty"`
+	DeletedAt        *time.Time  `json:"deleted_at,omitempty"`
+	Meta
 }
 
 // CreateItemRequest represents a request to create an item
 type CreateItemRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	Name             string      `json:"name"`
+	Description      string      `json:"description"`
+	Price            money.Money `json:"price"`
+	Quantity         int         `json:"quantity"`
+	ReorderThreshold int         `json:"reorder_threshold"`
 }
 
 // UpdateItemRequest represents a request to update an item
 type UpdateItemRequest struct {
-	Name        string   `json:"name,omitempty"`
-	Description string   `json:"description,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
-	Quantity    *int     `json:"quantity,omitempty"`
+	Name             string       `json:"name,omitempty"`
+	Description      string       `json:"description,omitempty"`
+	Price            *money.Money `json:"price,omitempty"`
+	Quantity         *int         `json:"quantity,omitempty"`
+	ReorderThreshold *int         `json:"reorder_threshold,omitempty"`
+}
+
+// ImportItemsRequest represents a request to create items in bulk
+type ImportItemsRequest struct {
+	Items []CreateItemRequest `json:"items"`
 }
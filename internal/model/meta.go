@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Meta is change metadata maintained by the store on every write
+type Meta struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+}
+
+// Stamp records a write at the given time by actor. The creation fields
+// are only set on the first write.
+func (m *Meta) Stamp(at time.Time, by string) {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = at
+		m.CreatedBy = by
+	}
+	m.UpdatedAt = at
+	m.UpdatedBy = by
+}
+
+// TenantID returns the tenant the row belongs to
+func (m Meta) TenantID() string {
+	return m.Tenant
+}
+
+// SetTenant assigns the row to tenant
+func (m *Meta) SetTenant(tenant string) {
+	m.Tenant = tenant
+}
@@ -0,0 +1,68 @@
+package model
+
+import "github.com/gostructure/app/pkg/money"
+
+// Order statuses
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderCancelled = "cancelled"
+)
+
+// orderTransitions lists the statuses each status may move to
+var orderTransitions = map[string][]string{
+	OrderPending: {OrderPaid, OrderCancelled},
+	OrderPaid:    {OrderShipped, OrderCancelled},
+	OrderShipped: {OrderCancelled},
+}
+
+// CanTransition reports whether an order may move from status from to to
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is a user's purchase of items. Stock is taken when the order is
+// paid and returned if a paid order is cancelled. A shipped order's stock
+// has left and stays taken when it is cancelled.
+type Order struct {
+	ID     int64       `json:"id"`
+	UserID int64       `json:"user_id"`
+	Status string      `json:"status"`
+	Lines  []OrderLine `json:"lines"`
+	Total  money.Money `json:"total"`
+	Meta
+}
+
+// OrderLine is a quantity of an item at the price captured when the line
+// was added
+type OrderLine struct {
+	ItemID    int64       `json:"item_id"`
+	Name      string      `json:"name"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+	Total     money.Money `json:"total"`
+}
+
+// OrderLineRequest represents a line of an order request
+type OrderLineRequest struct {
+	ItemID   int64 `json:"item_id"`
+	Quantity int   `json:"quantity"`
+}
+
+// CreateOrderRequest represents a request to create an order
+type CreateOrderRequest struct {
+	UserID int64              `json:"user_id"`
+	Lines  []OrderLineRequest `json:"lines"`
+}
+
+// UpdateOrderRequest represents a request to replace the lines of a
+// pending order
+type UpdateOrderRequest struct {
+	Lines []OrderLineRequest `json:"lines"`
+}
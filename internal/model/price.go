@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/gostructure/app/pkg/money"
+)
+
+// Price change statuses
+const (
+	PriceScheduled = "scheduled"
+	PriceApplied   = "applied"
+)
+
+// PriceChange is an entry in an item's price history. An applied change set
+// the item's price at EffectiveAt; a scheduled one will set it once
+// EffectiveAt has passed. AppliedAt is when the change reached the item.
+type PriceChange struct {
+	ID          int64       `json:"id"`
+	ItemID      int64       `json:"item_id"`
+	Price       money.Money `json:"price"`
+	Status      string      `json:"status"`
+	EffectiveAt time.Time   `json:"effective_at"`
+	AppliedAt   *time.Time  `json:"applied_at,omitempty"`
+	Reason      string      `json:"reason,omitempty"`
+	Meta
+}
+
+// SchedulePriceRequest represents a request to change an item's price at a
+// future time
+type SchedulePriceRequest struct {
+	Price       money.Money `json:"price"`
+	EffectiveAt time.Time   `json:"effective_at"`
+	Reason      string      `json:"reason"`
+}
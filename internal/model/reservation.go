@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Reservation statuses
+const (
+	ReservationActive    = "active"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation holds stock of an item for its owner until it is confirmed,
+// released or expires. Only active reservations hold stock.
+type Reservation struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	Quantity  int       `json:"quantity"`
+	Owner     string    `json:"owner"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Meta
+}
+
+// Holds reports whether r holds stock at now
+func (r Reservation) Holds(now time.Time) bool {
+	return r.Status == ReservationActive && now.Before(r.ExpiresAt)
+}
+
+// CreateReservationRequest represents a request to reserve stock. TTL is a
+// duration such as "30m".
+type CreateReservationRequest struct {
+	ItemID   int64  `json:"item_id"`
+	Quantity int    `json:"quantity"`
+	TTL      string `json:"ttl,omitempty"`
+}
@@ -1,20 +1,36 @@
 package model
 
-// User represents a user entity
+import "time"
+
+// What happens to the items a user owns when the user is deleted
+const (
+	OwnedItemsBlock    = "block"
+	OwnedItemsOrphan   = "orphan"
+	OwnedItemsReassign = "reassign"
+)
+
+// User represents a user entity. Deleted users keep their record with
+// DeletedAt set until they are purged. Subject links the user to the
+// request principal authenticating as it, which owns the items it creates.
 type User struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Subject   string     `json:"subject,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Meta
 }
 
 // CreateUserRequest represents a request to create a user
 type CreateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Subject string `json:"subject"`
 }
 
 // UpdateUserRequest represents a request to update a user
 type UpdateUserRequest struct {
-	Name  string `json:"name,omitempty"`
-	Email string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Subject string `json:"subject,omitempty"`
 }
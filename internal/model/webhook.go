@@ -0,0 +1,17 @@
+package model
+
+// CreateWebhookRequest represents a request to register a webhook
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+// UpdateWebhookRequest represents a request to update a webhook
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
@@ -0,0 +1,160 @@
+// Package notify delivers operational notifications, such as low-stock
+// alerts, through configurable channels.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/webhook"
+)
+
+// Notification is a message for people operating the system
+type Notification struct {
+	Kind    string      `json:"kind"`
+	Subject string      `json:"subject"`
+	Text    string      `json:"text"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Channel delivers notifications
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// New returns the channels named in cfg. Channels that are misconfigured
+// are left out and reported in the returned error, so one bad channel does
+// not silence the others.
+func New(cfg config.AlertsConfig) ([]Channel, error) {
+	var channels []Channel
+	var errs []error
+	for _, name := range cfg.Channels {
+		switch name {
+		case "log":
+			channels = append(channels, LogChannel{})
+		case "webhook":
+			if cfg.WebhookURL == "" {
+				errs = append(errs, fmt.Errorf("webhook notification channel needs a URL"))
+				continue
+			}
+			channels = append(channels, &WebhookChannel{URL: cfg.WebhookURL, Secret: cfg.WebhookSecret})
+		case "smtp":
+			if cfg.SMTPAddr == "" || len(cfg.SMTPTo) == 0 {
+				errs = append(errs, fmt.Errorf("smtp notification channel needs an address and recipients"))
+				continue
+			}
+			channels = append(channels, SMTPChannel{Addr: cfg.SMTPAddr, From: cfg.SMTPFrom, To: cfg.SMTPTo})
+		default:
+			errs = append(errs, fmt.Errorf("unknown notification channel %q", name))
+		}
+	}
+	return channels, errors.Join(errs...)
+}
+
+// Send delivers n through every channel, logging failures, and returns how
+// many channels accepted it
+func Send(ctx context.Context, channels []Channel, n Notification) int {
+	sent := 0
+	for _, c := range channels {
+		if err := c.Send(ctx, n); err != nil {
+			log.Printf("Failed to send %s notification via %s: %v", n.Kind, c.Name(), err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// LogChannel writes notifications to the standard logger
+type LogChannel struct{}
+
+// Name implements Channel
+func (LogChannel) Name() string { return "log" }
+
+// Send implements Channel
+func (LogChannel) Send(_ context.Context, n Notification) error {
+	log.Printf("[%s] %s: %s", n.Kind, n.Subject, n.Text)
+	return nil
+}
+
+// WebhookChannel posts notifications as JSON to a URL. With a secret the
+// request is signed like webhook deliveries.
+type WebhookChannel struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Name implements Channel
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+// Send implements Channel
+func (c *WebhookChannel) Send(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderEvent, n.Kind)
+	if c.Secret != "" {
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(c.Secret, time.Now(), payload))
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPChannel mails notifications through an SMTP server without
+// authentication, such as a local relay or development mail catcher
+type SMTPChannel struct {
+	Addr string
+	From string
+	To   []string
+}
+
+// Name implements Channel
+func (c SMTPChannel) Name() string { return "smtp" }
+
+// Send implements Channel. The context is not consulted once sending has
+// started, as net/smtp does not support cancellation.
+func (c SMTPChannel) Send(ctx context.Context, n Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(n.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Text, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return smtp.SendMail(c.Addr, nil, c.From, c.To, []byte(msg.String()))
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/store"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultPollInterval = time.Second
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = time.Minute
+)
+
+// Sink publishes outbox events to a destination. Publish may be called
+// more than once for the same event; consumers use Event.DedupID to drop
+// duplicates.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, e events.Event) error
+}
+
+// Relay publishes committed outbox records to every sink in commit order.
+// A record is acknowledged per sink after it is published and removed once
+// all sinks have it, so delivery is at-least-once across restarts.
+type Relay struct {
+	cfg   config.OutboxConfig
+	store *store.Store
+	sinks []Sink
+}
+
+// NewRelay creates a relay from s to sinks. Zero config values use
+// defaults.
+func NewRelay(cfg config.OutboxConfig, s *store.Store, sinks ...Sink) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	return &Relay{
+		cfg:   cfg,
+		store: s,
+		sinks: sinks,
+	}
+}
+
+// Run relays records until ctx is cancelled, then makes a final pass so
+// events committed while draining are not left for the next start
+func (r *Relay) Run(ctx context.Context) {
+	defer r.Flush(context.Background())
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	backoff := r.cfg.RetryBackoff
+	for {
+		wait := (<-chan time.Time)(ticker.C)
+		if r.Flush(ctx) {
+			backoff = r.cfg.RetryBackoff
+		} else {
+			// A sink failed; retry after a growing delay
+			wait = time.After(backoff)
+			backoff = min(backoff*2, maxRetryBackoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+		case <-r.store.OutboxNotify():
+		}
+	}
+}
+
+// Flush publishes every pending record and reports whether all sinks
+// succeeded. A sink that fails stops receiving records for this pass so
+// its events stay in order.
+func (r *Relay) Flush(ctx context.Context) bool {
+	failed := make(map[string]bool)
+
+	for {
+		records := r.store.Pending(defaultBatchSize)
+		if len(records) == 0 {
+			return len(failed) == 0
+		}
+
+		progressed := false
+		for _, rec := range records {
+			done := true
+			for _, sink := range r.sinks {
+				name := sink.Name()
+				if rec.Delivered[name] {
+					continue
+				}
+				if failed[name] {
+					done = false
+					continue
+				}
+				if err := sink.Publish(ctx, rec.Event); err != nil {
+					log.Printf("Outbox sink %s failed for event %s: %v", name, rec.Event.DedupID, err)
+					failed[name] = true
+					done = false
+					continue
+				}
+				if err := r.store.Ack(rec.Seq, name); err != nil {
+					log.Printf("Outbox ack failed for event %s: %v", rec.Event.DedupID, err)
+					return false
+				}
+				progressed = true
+			}
+			if done {
+				if err := r.store.Complete(rec.Seq); err != nil {
+					log.Printf("Outbox completion failed for event %s: %v", rec.Event.DedupID, err)
+					return false
+				}
+				progressed = true
+			}
+		}
+
+		if !progressed || ctx.Err() != nil {
+			return len(failed) == 0 && ctx.Err() == nil
+		}
+	}
+}
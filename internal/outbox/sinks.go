@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/search"
+	"github.com/gostructure/app/internal/webhook"
+)
+
+// BusSink publishes events to the in-process change feed
+type BusSink struct {
+	Bus *events.Bus
+}
+
+// Name implements Sink
+func (s BusSink) Name() string { return "bus" }
+
+// Publish implements Sink
+func (s BusSink) Publish(_ context.Context, e events.Event) error {
+	s.Bus.Publish(e)
+	return nil
+}
+
+// WebhookSink queues events for delivery to webhook subscriptions. The
+// deliveries are persisted before Publish returns, so acknowledging the
+// event cannot lose them.
+type WebhookSink struct {
+	Dispatcher *webhook.Dispatcher
+}
+
+// Name implements Sink
+func (s WebhookSink) Name() string { return "webhook" }
+
+// Publish implements Sink
+func (s WebhookSink) Publish(_ context.Context, e events.Event) error {
+	return s.Dispatcher.Enqueue(e)
+}
+
+// FileSink appends events to a file as JSON lines
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileSink opens or creates path for appending
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open event file: %w", err)
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Name implements Sink
+func (s *FileSink) Name() string { return "file" }
+
+// Publish implements Sink
+func (s *FileSink) Publish(_ context.Context, e events.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// SearchSink keeps the full-text search index current. Events that cannot
+// be indexed are logged and skipped, since retrying would not help.
+type SearchSink struct {
+	Index *search.Index
+}
+
+// Name implements Sink
+func (s SearchSink) Name() string { return "search" }
+
+// Publish implements Sink
+func (s SearchSink) Publish(_ context.Context, e events.Event) error {
+	if err := s.Index.Apply(e); err != nil {
+		log.Printf("Failed to index event %s: %v", e.DedupID, err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Lease grants one holder at a time the right to run a named task, so a
+// schedule shared by several replicas runs on only one of them
+type Lease interface {
+	// Acquire obtains or renews the lease for ttl and reports whether
+	// holder owns it
+	Acquire(name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder owns it
+	Release(name, holder string) error
+}
+
+type leaseRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// MemoryLease is a Lease for a single process
+type MemoryLease struct {
+	mu     sync.Mutex
+	leases map[string]leaseRecord
+}
+
+// NewMemoryLease creates an in-process lease
+func NewMemoryLease() *MemoryLease {
+	return &MemoryLease{leases: make(map[string]leaseRecord)}
+}
+
+// Acquire implements Lease
+func (l *MemoryLease) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if cur, ok := l.leases[name]; ok && cur.Holder != holder && now.Before(cur.Expires) {
+		return false, nil
+	}
+	l.leases[name] = leaseRecord{Holder: holder, Expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release implements Lease
+func (l *MemoryLease) Release(name, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cur, ok := l.leases[name]; ok && cur.Holder == holder {
+		delete(l.leases, name)
+	}
+	return nil
+}
+
+// FileLease stores leases as files in a directory shared by all replicas,
+// such as a network volume
+type FileLease struct {
+	dir string
+}
+
+// lockTimeout is how long a lock file may exist before it is considered
+// abandoned by a crashed process
+const lockTimeout = 10 * time.Second
+
+// NewFileLease creates a lease backed by files in dir
+func NewFileLease(dir string) (*FileLease, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create lease directory: %w", err)
+	}
+	return &FileLease{dir: dir}, nil
+}
+
+// Acquire implements Lease
+func (l *FileLease) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	unlock, err := l.lock(name)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	now := time.Now()
+	cur, err := l.read(name)
+	if err != nil {
+		return false, err
+	}
+	if cur != nil && cur.Holder != holder && now.Before(cur.Expires) {
+		return false, nil
+	}
+	return true, l.write(name, leaseRecord{Holder: holder, Expires: now.Add(ttl)})
+}
+
+// Release implements Lease
+func (l *FileLease) Release(name, holder string) error {
+	unlock, err := l.lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cur, err := l.read(name)
+	if err != nil || cur == nil || cur.Holder != holder {
+		return err
+	}
+	if err := os.Remove(l.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *FileLease) path(name string) string {
+	return filepath.Join(l.dir, name+".lease")
+}
+
+// lock serializes access to a lease with an exclusively created lock file
+func (l *FileLease) lock(name string) (func(), error) {
+	lockPath := filepath.Join(l.dir, name+".lock")
+	for attempt := 0; attempt < 50; attempt++ {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("lock lease %s: %w", name, err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("lock lease %s: timed out", name)
+}
+
+func (l *FileLease) read(name string) (*leaseRecord, error) {
+	data, err := os.ReadFile(l.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read lease %s: %w", name, err)
+	}
+	var rec leaseRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		// A corrupt lease is treated as free
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (l *FileLease) write(name string, rec leaseRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp := l.path(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write lease %s: %w", name, err)
+	}
+	return os.Rename(tmp, l.path(name))
+}
@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+)
+
+const (
+	defaultLeaseTTL = time.Minute
+	// disabled as a schedule override turns a task off
+	disabled = "off"
+)
+
+// Task is a recurring unit of work. ctx is cancelled on shutdown.
+type Task func(ctx context.Context) error
+
+type schedule struct {
+	name   string
+	spec   Spec
+	task   Task
+	jitter time.Duration
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Status describes a schedule and its most recent run
+type Status struct {
+	Name         string     `json:"name"`
+	Spec         string     `json:"spec"`
+	Disabled     bool       `json:"disabled,omitempty"`
+	Running      bool       `json:"running"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	// Skipped counts runs dropped because the previous one was still going
+	Skipped int `json:"skipped"`
+}
+
+// Option configures a schedule
+type Option func(*schedule)
+
+// WithJitter overrides the configured jitter for one schedule
+func WithJitter(d time.Duration) Option {
+	return func(s *schedule) {
+		s.jitter = d
+	}
+}
+
+// Scheduler runs tasks on cron or interval schedules. A run is skipped if
+// the previous run of the same task has not finished, and only the
+// instance holding a task's lease runs it, so replicas sharing a lease
+// store do not duplicate work.
+type Scheduler struct {
+	cfg   config.SchedulerConfig
+	lease Lease
+
+	mu        sync.Mutex
+	schedules []*schedule
+}
+
+// New creates a scheduler. A nil lease runs every task on this instance.
+// Zero config values use defaults.
+func New(cfg config.SchedulerConfig, lease Lease) *Scheduler {
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = defaultLeaseTTL
+	}
+	if lease == nil {
+		lease = NewMemoryLease()
+	}
+	return &Scheduler{cfg: cfg, lease: lease}
+}
+
+// Add registers a task under name. The configured schedule for name, if
+// any, replaces expr; "off" disables the task. Tasks must be added before
+// Run is called.
+func (s *Scheduler) Add(name, expr string, task Task, opts ...Option) error {
+	if override, ok := s.cfg.Schedules[name]; ok {
+		expr = override
+	}
+
+	sch := &schedule{
+		name:   name,
+		task:   task,
+		jitter: s.cfg.Jitter,
+		status: Status{Name: name, Spec: expr},
+	}
+	if expr == disabled {
+		sch.status.Disabled = true
+	} else {
+		spec, err := Parse(expr)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", name, err)
+		}
+		sch.spec = spec
+		sch.status.Spec = spec.String()
+	}
+	for _, opt := range opts {
+		opt(sch)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedules = append(s.schedules, sch)
+	return nil
+}
+
+// List returns the status of every schedule ordered by name
+func (s *Scheduler) List() []Status {
+	s.mu.Lock()
+	schedules := make([]*schedule, len(s.schedules))
+	copy(schedules, s.schedules)
+	s.mu.Unlock()
+
+	list := make([]Status, len(schedules))
+	for i, sch := range schedules {
+		sch.mu.Lock()
+		list[i] = sch.status
+		sch.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Run triggers tasks until ctx is cancelled, then waits for running tasks
+// to return and releases this instance's leases
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	schedules := make([]*schedule, len(s.schedules))
+	copy(schedules, s.schedules)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sch := range schedules {
+		if sch.spec == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, sch, &wg)
+		}()
+	}
+	wg.Wait()
+
+	for _, sch := range schedules {
+		if err := s.lease.Release(sch.name, s.cfg.InstanceID); err != nil {
+			log.Printf("Failed to release lease for %s: %v", sch.name, err)
+		}
+	}
+}
+
+// loop waits for each scheduled time and starts the task without waiting
+// for it, so a slow run is detected and the next one skipped
+func (s *Scheduler) loop(ctx context.Context, sch *schedule, wg *sync.WaitGroup) {
+	for {
+		next := sch.spec.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Schedule %s never runs again", sch.name)
+			return
+		}
+		if sch.jitter > 0 {
+			next = next.Add(rand.N(sch.jitter))
+		}
+
+		sch.mu.Lock()
+		sch.status.NextRun = &next
+		sch.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !sch.begin() {
+			log.Printf("Skipping %s: previous run still in progress", sch.name)
+			continue
+		}
+		if !s.acquire(sch) {
+			sch.finish(time.Time{}, nil)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.execute(ctx, sch)
+		}()
+	}
+}
+
+// acquire reports whether this instance holds the task's lease
+func (s *Scheduler) acquire(sch *schedule) bool {
+	ok, err := s.lease.Acquire(sch.name, s.cfg.InstanceID, s.cfg.LeaseTTL)
+	if err != nil {
+		log.Printf("Failed to acquire lease for %s: %v", sch.name, err)
+		return false
+	}
+	return ok
+}
+
+func (s *Scheduler) execute(ctx context.Context, sch *schedule) {
+	// Renew the lease while the task runs so it cannot expire mid-run
+	renewCtx, stopRenew := context.WithCancel(ctx)
+	defer stopRenew()
+	go func() {
+		ticker := time.NewTicker(s.cfg.LeaseTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				s.acquire(sch)
+			}
+		}
+	}()
+
+	started := time.Now()
+	err := runTask(ctx, sch.task)
+	if err != nil {
+		log.Printf("Scheduled task %s failed: %v", sch.name, err)
+	}
+	sch.finish(started, err)
+}
+
+// runTask converts panics in tasks into errors
+func runTask(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return task(ctx)
+}
+
+// begin marks the schedule running, or counts a skip if it already is
+func (sch *schedule) begin() bool {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+
+	if sch.status.Running {
+		sch.status.Skipped++
+		return false
+	}
+	sch.status.Running = true
+	return true
+}
+
+// finish records a run started at started; a zero time means the run did
+// not happen because another instance holds the lease
+func (sch *schedule) finish(started time.Time, err error) {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+
+	sch.status.Running = false
+	if started.IsZero() {
+		return
+	}
+	sch.status.LastRun = &started
+	sch.status.LastDuration = time.Since(started).String()
+	sch.status.Runs++
+	sch.status.LastError = ""
+	if err != nil {
+		sch.status.Failures++
+		sch.status.LastError = err.Error()
+	}
+}
@@ -0,0 +1,97 @@
+package search
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+)
+
+// Searchable resource types
+const (
+	ResourceItem = "item"
+	ResourceUser = "user"
+)
+
+// ItemFields returns the searchable text of an item
+func ItemFields(item model.Item) []Field {
+	return []Field{
+		{Name: "name", Text: item.Name, Weight: 2},
+		{Name: "description", Text: item.Description, Weight: 1},
+	}
+}
+
+// UserFields returns the searchable text of a user
+func UserFields(user model.User) []Field {
+	return []Field{
+		{Name: "name", Text: user.Name, Weight: 2},
+		{Name: "email", Text: user.Email, Weight: 1},
+	}
+}
+
+// Rebuild replaces the index contents with the live users and items in s
+func (ix *Index) Rebuild(s *store.Store) {
+	ix.Reset()
+	s.View(func(tx *store.Tx) error {
+		for _, item := range store.Items.List(tx) {
+			ix.putItem(item)
+		}
+		for _, user := range store.Users.List(tx) {
+			ix.putUser(user)
+		}
+		return nil
+	})
+}
+
+// Apply updates the index from a change event. Events for other resources
+// are ignored.
+func (ix *Index) Apply(e events.Event) error {
+	id, err := strconv.ParseInt(e.ResourceID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	switch e.Resource {
+	case ResourceItem:
+		var item model.Item
+		if e.Data == nil || e.Action == events.ActionDeleted {
+			ix.Remove(ResourceItem, id)
+			return nil
+		}
+		if err := json.Unmarshal(e.Data, &item); err != nil {
+			return err
+		}
+		ix.putItem(item)
+	case ResourceUser:
+		var user model.User
+		if e.Data == nil || e.Action == events.ActionDeleted {
+			ix.Remove(ResourceUser, id)
+			return nil
+		}
+		if err := json.Unmarshal(e.Data, &user); err != nil {
+			return err
+		}
+		ix.putUser(user)
+	}
+	return nil
+}
+
+// putItem indexes item unless it is deleted
+func (ix *Index) putItem(item model.Item) {
+	if item.DeletedAt != nil {
+		ix.Remove(ResourceItem, item.ID)
+		return
+	}
+	ix.PutTenant(item.Tenant, ResourceItem, item.ID, ItemFields(item))
+}
+
+// putUser indexes user unless it is deleted
+func (ix *Index) putUser(user model.User) {
+	if user.DeletedAt != nil {
+		ix.Remove(ResourceUser, user.ID)
+		return
+	}
+	ix.PutTenant(user.Tenant, ResourceUser, user.ID, UserFields(user))
+}
@@ -0,0 +1,363 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultLimit = 20
+	// prefixWeight scales matches where a query term is only a prefix of
+	// the indexed term
+	prefixWeight = 0.5
+	// snippetRadius is roughly how many characters of context surround the
+	// first match in a highlight
+	snippetRadius = 40
+)
+
+// Field is a piece of searchable text. Matches in fields with a higher
+// weight rank higher.
+type Field struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+// Hit is a search result
+type Hit struct {
+	Resource   string            `json:"resource"`
+	ID         int64             `json:"id"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// Query selects documents. Every term must match a word in the document,
+// either exactly or as a prefix.
+type Query struct {
+	Text string
+	// Resource restricts results to one resource type when set
+	Resource string
+	// Tenant restricts results to one tenant's documents when set
+	Tenant string
+	Limit  int
+}
+
+type docKey struct {
+	resource string
+	id       int64
+}
+
+type document struct {
+	tenant string
+	fields []Field
+	terms  map[string]bool
+}
+
+// Index is an in-memory inverted index over documents identified by
+// resource and ID
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[docKey]*document
+	postings map[string]map[docKey]float64
+	// terms is kept sorted for prefix lookups
+	terms []string
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[docKey]*document),
+		postings: make(map[string]map[docKey]float64),
+	}
+}
+
+// Put indexes a document, replacing any previous version
+func (ix *Index) Put(resource string, id int64, fields []Field) {
+	ix.PutTenant("", resource, id, fields)
+}
+
+// PutTenant indexes a document belonging to tenant like Put
+func (ix *Index) PutTenant(tenant, resource string, id int64, fields []Field) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	key := docKey{resource, id}
+	ix.remove(key)
+
+	doc := &document{tenant: tenant, fields: fields, terms: make(map[string]bool)}
+	for _, f := range fields {
+		weight := f.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		for _, tok := range tokenize(f.Text) {
+			postings, ok := ix.postings[tok.term]
+			if !ok {
+				postings = make(map[docKey]float64)
+				ix.postings[tok.term] = postings
+				ix.insertTerm(tok.term)
+			}
+			postings[key] += weight
+			doc.terms[tok.term] = true
+		}
+	}
+	ix.docs[key] = doc
+}
+
+// Remove drops a document from the index
+func (ix *Index) Remove(resource string, id int64) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(docKey{resource, id})
+}
+
+// Reset empties the index
+func (ix *Index) Reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs = make(map[docKey]*document)
+	ix.postings = make(map[string]map[docKey]float64)
+	ix.terms = nil
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search returns documents matching every term of q ranked by relevance,
+// with the matching words of each field highlighted
+func (ix *Index) Search(q Query) []Hit {
+	queryTerms := uniqueTerms(q.Text)
+	if len(queryTerms) == 0 {
+		return nil
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	total := len(ix.docs)
+	if q.Tenant != "" {
+		total = 0
+		for _, doc := range ix.docs {
+			if doc.tenant == q.Tenant {
+				total++
+			}
+		}
+	}
+
+	var scores map[docKey]float64
+	for _, qt := range queryTerms {
+		termScores := make(map[docKey]float64)
+		for _, term := range ix.expand(qt) {
+			match := 1.0
+			if term != qt {
+				match = prefixWeight
+			}
+			for key, tf := range ix.postings[term] {
+				if q.Resource != "" && key.resource != q.Resource {
+					continue
+				}
+				if q.Tenant != "" && ix.docs[key].tenant != q.Tenant {
+					continue
+				}
+				termScores[key] = max(termScores[key], tf*match)
+			}
+		}
+		// Rarer query terms count for more; a prefix is as rare as all the
+		// documents it matches
+		idf := math.Log(1 + float64(total)/float64(max(len(termScores), 1)))
+		for key := range termScores {
+			termScores[key] *= idf
+		}
+
+		// Documents must match every query term
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for key := range scores {
+			if s, ok := termScores[key]; ok {
+				scores[key] += s
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for key, score := range scores {
+		hits = append(hits, Hit{Resource: key.resource, ID: key.id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Resource != hits[j].Resource {
+			return hits[i].Resource < hits[j].Resource
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	for i := range hits {
+		doc := ix.docs[docKey{hits[i].Resource, hits[i].ID}]
+		for _, f := range doc.fields {
+			if snippet, ok := highlight(f.Text, queryTerms); ok {
+				if hits[i].Highlights == nil {
+					hits[i].Highlights = make(map[string]string)
+				}
+				hits[i].Highlights[f.Name] = snippet
+			}
+		}
+	}
+	return hits
+}
+
+// remove drops key; must be called with ix.mu held
+func (ix *Index) remove(key docKey) {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		postings := ix.postings[term]
+		delete(postings, key)
+		if len(postings) == 0 {
+			delete(ix.postings, term)
+			ix.deleteTerm(term)
+		}
+	}
+	delete(ix.docs, key)
+}
+
+func (ix *Index) insertTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+func (ix *Index) deleteTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// expand returns the indexed terms starting with prefix
+func (ix *Index) expand(prefix string) []string {
+	var terms []string
+	for i := sort.SearchStrings(ix.terms, prefix); i < len(ix.terms); i++ {
+		if !strings.HasPrefix(ix.terms[i], prefix) {
+			break
+		}
+		terms = append(terms, ix.terms[i])
+	}
+	return terms
+}
+
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lower-cased words of letters and digits,
+// recording their byte offsets
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokens = append(tokens, token{fold(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{fold(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// fold maps a word to a case-folded form. Upper-casing first also folds
+// letters with several lower-case forms, such as the Greek final sigma.
+func fold(word string) string {
+	return strings.ToLower(strings.ToUpper(word))
+}
+
+func uniqueTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, tok := range tokenize(text) {
+		if !seen[tok.term] {
+			seen[tok.term] = true
+			terms = append(terms, tok.term)
+		}
+	}
+	return terms
+}
+
+// highlight returns an HTML-escaped excerpt of text around its first match
+// with every matching word wrapped in <mark>
+func highlight(text string, queryTerms []string) (string, bool) {
+	var matches []token
+	for _, tok := range tokenize(text) {
+		for _, qt := range queryTerms {
+			if strings.HasPrefix(tok.term, qt) {
+				matches = append(matches, tok)
+				break
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	from := max(0, matches[0].start-snippetRadius)
+	to := min(len(text), matches[0].end+snippetRadius)
+	// Keep the excerpt on rune boundaries
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gostructure/app/internal/config"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd
+const listenFDsStart = 3
+
+var (
+	inheritedOnce  sync.Once
+	inheritedFiles []*os.File
+	inheritedNames []string
+)
+
+// Listen opens the socket described by lc
+func Listen(lc config.ListenerConfig) (net.Listener, error) {
+	switch lc.Network {
+	case "tcp":
+		return net.Listen("tcp", lc.Address)
+	case "unix":
+		return listenUnix(lc)
+	case "fd":
+		return listenInherited(lc.Address)
+	default:
+		return nil, fmt.Errorf("unsupported listener network %q", lc.Network)
+	}
+}
+
+func listenUnix(lc config.ListenerConfig) (net.Listener, error) {
+	// Remove a stale socket left by an unclean exit, but never a regular file
+	if info, err := os.Lstat(lc.Address); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", lc.Address)
+		}
+		if err := os.Remove(lc.Address); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", lc.Address)
+	if err != nil {
+		return nil, err
+	}
+	if lc.SocketMode != 0 {
+		if err := os.Chmod(lc.Address, lc.SocketMode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("chmod socket: %w", err)
+		}
+	}
+	return ln, nil
+}
+
+// listenInherited returns a listener passed by the service manager using
+// the systemd socket activation protocol. The address is either the fd
+// number or the name given in LISTEN_FDNAMES.
+func listenInherited(address string) (net.Listener, error) {
+	inheritedOnce.Do(loadInheritedFiles)
+
+	for i, f := range inheritedFiles {
+		if f == nil {
+			continue
+		}
+		if address != strconv.Itoa(listenFDsStart+i) && address != inheritedNames[i] {
+			continue
+		}
+
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("inherited fd %s: %w", address, err)
+		}
+		// FileListener dups the descriptor, so the original can be released
+		f.Close()
+		inheritedFiles[i] = nil
+		return ln, nil
+	}
+
+	if len(inheritedFiles) == 0 {
+		return nil, errors.New("no sockets were passed by the service manager")
+	}
+	return nil, fmt.Errorf("no inherited socket matches %q", address)
+}
+
+func loadInheritedFiles() {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// Child processes must not believe the sockets are meant for them
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	inheritedFiles = make([]*os.File, count)
+	inheritedNames = make([]string, count)
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		if i < len(names) {
+			inheritedNames[i] = names[i]
+		}
+		inheritedFiles[i] = os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+	}
+}
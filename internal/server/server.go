@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gostructure/app/internal/config"
+)
+
+// Server serves one handler on every configured listener, plus the
+// optional plain-HTTP redirect listener when TLS is enabled
+type Server struct {
+	cfg      *config.Config
+	handler  http.Handler
+	reloader *CertReloader
+	tls      *tls.Config
+	configs  []config.ListenerConfig
+	redirect bool
+
+	servers   []*http.Server
+	listeners []net.Listener
+	errs      chan error
+	stopWatch context.CancelFunc
+}
+
+// New validates the listener and TLS configuration
+func New(cfg *config.Config, handler http.Handler) (*Server, error) {
+	s := &Server{
+		cfg:      cfg,
+		handler:  handler,
+		configs:  cfg.Server.Listeners,
+		redirect: cfg.Server.TLS.RedirectAddress != "",
+		errs:     make(chan error, 1),
+	}
+	// Fall back to a single TCP listener on Server.Address
+	if len(s.configs) == 0 {
+		s.configs = []config.ListenerConfig{{
+			Network: "tcp",
+			Address: cfg.Server.Address,
+			TLS:     cfg.Server.TLS.Enabled(),
+		}}
+	}
+
+	needsTLS := false
+	for _, lc := range s.configs {
+		if lc.TLS && lc.H2C {
+			return nil, errors.New("h2c listeners cannot use TLS")
+		}
+		needsTLS = needsTLS || lc.TLS
+	}
+	if needsTLS && !cfg.Server.TLS.Enabled() {
+		return nil, errors.New("TLS listener configured without a certificate and key")
+	}
+
+	if needsTLS {
+		reloader, err := NewCertReloader(cfg.Server.TLS)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err := NewTLSConfig(cfg.Server.TLS, reloader)
+		if err != nil {
+			return nil, err
+		}
+		s.reloader = reloader
+		s.tls = tlsConfig
+	}
+
+	return s, nil
+}
+
+// NewAdmin creates the plain-HTTP operational server on Server.AdminAddress
+func NewAdmin(cfg *config.Config, handler http.Handler) *Server {
+	return &Server{
+		cfg:     cfg,
+		handler: handler,
+		configs: []config.ListenerConfig{{Network: "tcp", Address: cfg.Server.AdminAddress}},
+		errs:    make(chan error, 1),
+	}
+}
+
+// Start binds every listener and serves them in the background. If any
+// listener fails to bind, those already opened are closed.
+func (s *Server) Start() error {
+	for _, lc := range s.configs {
+		ln, err := Listen(lc)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		s.listeners = append(s.listeners, ln)
+		s.servers = append(s.servers, s.newHTTPServer(lc, s.handler))
+	}
+
+	if s.tls != nil && s.redirect {
+		lc := config.ListenerConfig{Network: "tcp", Address: s.cfg.Server.TLS.RedirectAddress}
+		ln, err := Listen(lc)
+		if err != nil {
+			s.closeListeners()
+			return err
+		}
+		s.listeners = append(s.listeners, ln)
+		s.servers = append(s.servers, s.newHTTPServer(lc, RedirectHandler(s.httpsAddress())))
+	}
+
+	if s.reloader != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopWatch = cancel
+		go s.reloader.Watch(ctx)
+	}
+
+	for i := range s.servers {
+		srv, ln := s.servers[i], s.listeners[i]
+		go func() {
+			log.Printf("Starting server on %s://%s (tls=%t)", ln.Addr().Network(), ln.Addr(), srv.TLSConfig != nil)
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				select {
+				case s.errs <- err:
+				default:
+				}
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Err receives the first error that stops a listener unexpectedly
+func (s *Server) Err() <-chan error {
+	return s.errs
+}
+
+// Addrs returns the bound address of every listener
+func (s *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, ln := range s.listeners {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
+
+// Shutdown gracefully stops all listeners concurrently, waiting for
+// in-flight requests until ctx expires
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.stopWatch != nil {
+		s.stopWatch()
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, srv := range s.servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) newHTTPServer(lc config.ListenerConfig, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       s.cfg.Server.ReadTimeout,
+		WriteTimeout:      s.cfg.Server.WriteTimeout,
+		IdleTimeout:       s.cfg.Server.IdleTimeout,
+		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+		MaxHeaderBytes:    s.cfg.Security.MaxHeaderBytes,
+	}
+
+	if lc.TLS {
+		srv.TLSConfig = s.tls.Clone()
+	}
+	if lc.H2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = protocols
+	}
+
+	return srv
+}
+
+// httpsAddress returns the first TLS listener address for redirects
+func (s *Server) httpsAddress() string {
+	for _, lc := range s.configs {
+		if lc.TLS && lc.Network == "tcp" {
+			return lc.Address
+		}
+	}
+	return ":443"
+}
+
+func (s *Server) closeListeners() {
+	for _, ln := range s.listeners {
+		ln.Close()
+	}
+	s.listeners = nil
+	s.servers = nil
+}
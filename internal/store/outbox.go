@@ -0,0 +1,147 @@
+package store
+
+import (
+	"time"
+
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/pkg/uuid"
+)
+
+// OutboxRecord is an event committed together with the change it
+// describes. Delivered tracks which sinks have published it.
+type OutboxRecord struct {
+	Seq       uint64          `json:"seq"`
+	Event     events.Event    `json:"event"`
+	Delivered map[string]bool `json:"delivered,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type outbox struct {
+	records []*OutboxRecord
+	nextSeq uint64
+}
+
+func (o *outbox) add(r *OutboxRecord) {
+	o.records = append(o.records, r)
+	if r.Seq >= o.nextSeq {
+		o.nextSeq = r.Seq + 1
+	}
+}
+
+func (o *outbox) find(seq uint64) *OutboxRecord {
+	for _, r := range o.records {
+		if r.Seq == seq {
+			return r
+		}
+	}
+	return nil
+}
+
+func (o *outbox) ack(seq uint64, sink string) {
+	if r := o.find(seq); r != nil {
+		if r.Delivered == nil {
+			r.Delivered = make(map[string]bool)
+		}
+		r.Delivered[sink] = true
+	}
+}
+
+func (o *outbox) complete(seq uint64) {
+	for i, r := range o.records {
+		if r.Seq == seq {
+			o.records = append(o.records[:i], o.records[i+1:]...)
+			return
+		}
+	}
+}
+
+// Emit adds an event to the outbox. It is published only if the
+// transaction commits. The event belongs to the tenant of data, or to the
+// transaction's tenant.
+func (tx *Tx) Emit(resource string, id int64, action string, data interface{}) {
+	tx.mustWrite()
+
+	e := events.NewEvent(resource, id, action, data)
+	e.DedupID = uuid.New()
+	e.Tenant = tx.tenant
+	if t, ok := data.(interface{ TenantID() string }); ok && t.TenantID() != "" {
+		e.Tenant = t.TenantID()
+	}
+	e.Timestamp = time.Now().UTC()
+
+	o := &tx.s.outbox
+	if o.nextSeq == 0 {
+		o.nextSeq = 1
+	}
+	r := &OutboxRecord{Seq: o.nextSeq, Event: e, CreatedAt: e.Timestamp}
+	o.add(r)
+	tx.undo = append(tx.undo, func() {
+		o.complete(r.Seq)
+		o.nextSeq = r.Seq
+	})
+	tx.ops = append(tx.ops, op{Op: opOutbox, Record: r})
+	tx.emitted = true
+}
+
+// Pending returns up to limit undelivered outbox records in commit order
+func (s *Store) Pending(limit int) []OutboxRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := len(s.outbox.records)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	records := make([]OutboxRecord, n)
+	for i := 0; i < n; i++ {
+		r := *s.outbox.records[i]
+		r.Delivered = make(map[string]bool, len(r.Delivered))
+		for sink := range s.outbox.records[i].Delivered {
+			r.Delivered[sink] = true
+		}
+		records[i] = r
+	}
+	return records
+}
+
+// Ack records that sink has published the record with seq
+func (s *Store) Ack(seq uint64, sink string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.outbox.find(seq) == nil {
+		return ErrNotFound
+	}
+	if err := s.write([]op{{Op: opAck, Seq: seq, Sink: sink}}); err != nil {
+		return err
+	}
+	s.outbox.ack(seq, sink)
+	return nil
+}
+
+// Complete removes a record once every sink has published it
+func (s *Store) Complete(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.outbox.find(seq) == nil {
+		return ErrNotFound
+	}
+	if err := s.write([]op{{Op: opComplete, Seq: seq}}); err != nil {
+		return err
+	}
+	s.outbox.complete(seq)
+	return nil
+}
+
+// OutboxNotify is signalled after a transaction adds outbox records
+func (s *Store) OutboxNotify() <-chan struct{} {
+	return s.notify
+}
+
+func (s *Store) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
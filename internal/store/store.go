@@ -0,0 +1,324 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a row does not exist
+var ErrNotFound = errors.New("not found")
+
+// Store holds application state in tables that are changed together with
+// their outbox records in a single transaction. When backed by a file,
+// every transaction is appended to a journal as one line and replayed on
+// open, so state changes and their events are persisted atomically.
+type Store struct {
+	mu     sync.RWMutex
+	tables map[string]*tableData
+	outbox outbox
+	path   string
+	file   *os.File
+	notify chan struct{}
+}
+
+type tableData struct {
+	rows   map[int64]any
+	nextID int64
+}
+
+// op is one journaled change
+type op struct {
+	Op     string          `json:"op"`
+	Table  string          `json:"table,omitempty"`
+	ID     int64           `json:"id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Record *OutboxRecord   `json:"record,omitempty"`
+	Seq    uint64          `json:"seq,omitempty"`
+	Sink   string          `json:"sink,omitempty"`
+}
+
+const (
+	opPut      = "put"
+	opDelete   = "delete"
+	opNextID   = "next_id"
+	opOutbox   = "outbox"
+	opAck      = "ack"
+	opComplete = "complete"
+)
+
+// New creates an in-memory store
+func New() *Store {
+	s := &Store{
+		tables: make(map[string]*tableData),
+		notify: make(chan struct{}, 1),
+	}
+	// Create declared tables up front so read transactions never write
+	for name := range decoders {
+		s.table(name)
+	}
+	return s
+}
+
+// OpenFile opens or creates a file-backed store, replaying its journal.
+// The journal is compacted to a single snapshot entry on open.
+func OpenFile(path string) (*Store, error) {
+	s := New()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var ops []op
+		if err := json.Unmarshal(scanner.Bytes(), &ops); err != nil {
+			// A torn final line is an interrupted transaction that never
+			// committed; anything earlier is corruption
+			if !scanner.Scan() {
+				break
+			}
+			return nil, fmt.Errorf("read store journal line %d: %w", line, err)
+		}
+		for _, o := range ops {
+			if err := s.replay(o); err != nil {
+				return nil, fmt.Errorf("replay store journal line %d: %w", line, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read store journal: %w", err)
+	}
+
+	s.path = path
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Compact rewrites the journal as a single snapshot of the current state.
+// It is a no-op for in-memory stores.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	return s.compact()
+}
+
+// Update runs fn in a read-write transaction. If fn returns an error or the
+// journal cannot be written, every change made by fn is rolled back.
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	return s.UpdateAs("", fn)
+}
+
+// UpdateAs runs fn like Update, recording actor as the author of the rows
+// it writes
+func (s *Store) UpdateAs(actor string, fn func(tx *Tx) error) error {
+	return s.update("", actor, fn)
+}
+
+// View runs fn in a read-only transaction
+func (s *Store) View(fn func(tx *Tx) error) error {
+	return s.view("", fn)
+}
+
+// Scoped returns a view of the store confined to tenant's rows. An empty
+// tenant sees every row, like the store itself.
+func (s *Store) Scoped(tenant string) Scope {
+	return Scope{s: s, tenant: tenant}
+}
+
+// Scope runs transactions that only see and write one tenant's rows
+type Scope struct {
+	s      *Store
+	tenant string
+}
+
+// Update runs fn in a read-write transaction scoped to the tenant
+func (sc Scope) Update(fn func(tx *Tx) error) error {
+	return sc.s.update(sc.tenant, "", fn)
+}
+
+// UpdateAs runs fn like Update, recording actor as the author of the rows
+// it writes
+func (sc Scope) UpdateAs(actor string, fn func(tx *Tx) error) error {
+	return sc.s.update(sc.tenant, actor, fn)
+}
+
+// View runs fn in a read-only transaction scoped to the tenant
+func (sc Scope) View(fn func(tx *Tx) error) error {
+	return sc.s.view(sc.tenant, fn)
+}
+
+func (s *Store) update(tenant, actor string, fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Tx{s: s, writable: true, now: time.Now().UTC(), actor: actor, tenant: tenant}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	if err := s.write(tx.ops); err != nil {
+		tx.rollback()
+		return err
+	}
+	if tx.emitted {
+		s.wake()
+	}
+	return nil
+}
+
+func (s *Store) view(tenant string, fn func(tx *Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(&Tx{s: s, tenant: tenant})
+}
+
+// Close closes the journal file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// write appends ops to the journal as one line; must be called with s.mu held
+func (s *Store) write(ops []op) error {
+	if s.file == nil || len(ops) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write store journal: %w", err)
+	}
+	return s.file.Sync()
+}
+
+func (s *Store) table(name string) *tableData {
+	t, ok := s.tables[name]
+	if !ok {
+		t = &tableData{rows: make(map[int64]any), nextID: 1}
+		s.tables[name] = t
+	}
+	return t
+}
+
+func (s *Store) replay(o op) error {
+	switch o.Op {
+	case opPut:
+		decode, ok := decoders[o.Table]
+		if !ok {
+			return fmt.Errorf("unknown table %q", o.Table)
+		}
+		v, err := decode(o.Data)
+		if err != nil {
+			return err
+		}
+		t := s.table(o.Table)
+		t.rows[o.ID] = v
+		if o.ID >= t.nextID {
+			t.nextID = o.ID + 1
+		}
+	case opDelete:
+		delete(s.table(o.Table).rows, o.ID)
+	case opNextID:
+		if t := s.table(o.Table); o.ID > t.nextID {
+			t.nextID = o.ID
+		}
+	case opOutbox:
+		s.outbox.add(o.Record)
+	case opAck:
+		s.outbox.ack(o.Seq, o.Sink)
+	case opComplete:
+		s.outbox.complete(o.Seq)
+	default:
+		return fmt.Errorf("unknown op %q", o.Op)
+	}
+	return nil
+}
+
+// compact rewrites the journal as a single snapshot and keeps it open for
+// appending. The current journal stays in use if the snapshot cannot be
+// written; must be called with s.mu held.
+func (s *Store) compact() error {
+	var ops []op
+	for name, t := range s.tables {
+		for id, row := range t.rows {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			ops = append(ops, op{Op: opPut, Table: name, ID: id, Data: data})
+		}
+		ops = append(ops, op{Op: opNextID, Table: name, ID: t.nextID})
+	}
+	for _, r := range s.outbox.records {
+		ops = append(ops, op{Op: opOutbox, Record: r})
+	}
+
+	tmp := s.path + ".tmp"
+	if err := writeSnapshot(tmp, ops); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compact store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("compact store: %w", err)
+	}
+	if dir, err := os.Open(filepath.Dir(s.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = f
+	return nil
+}
+
+func writeSnapshot(path string, ops []op) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
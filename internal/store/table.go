@@ -0,0 +1,212 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// decoders restore journaled rows by table name
+var decoders = make(map[string]func(json.RawMessage) (any, error))
+
+// Table is a typed view of a named table. Rows are stored by value, so
+// values returned from a table are copies.
+type Table[T any] struct {
+	name string
+}
+
+// NewTable declares a table whose rows have type T. Tables must be declared
+// at package initialization so journals can be replayed.
+func NewTable[T any](name string) Table[T] {
+	decoders[name] = func(data json.RawMessage) (any, error) {
+		var v T
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+	return Table[T]{name: name}
+}
+
+// Name returns the table name
+func (t Table[T]) Name() string {
+	return t.name
+}
+
+// Get returns the row with id
+func (t Table[T]) Get(tx *Tx, id int64) (T, bool) {
+	row, ok := tx.s.table(t.name).rows[id]
+	if !ok || !tx.visible(row) {
+		var zero T
+		return zero, false
+	}
+	return row.(T), true
+}
+
+// List returns all rows ordered by ID
+func (t Table[T]) List(tx *Tx) []T {
+	return t.Filter(tx, nil)
+}
+
+// Filter returns rows for which keep returns true, ordered by ID. A nil
+// keep matches every row.
+func (t Table[T]) Filter(tx *Tx, keep func(T) bool) []T {
+	data := tx.s.table(t.name)
+	ids := make([]int64, 0, len(data.rows))
+	for id := range data.rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	rows := make([]T, 0, len(ids))
+	for _, id := range ids {
+		row := data.rows[id].(T)
+		if !tx.visible(row) {
+			continue
+		}
+		if keep == nil || keep(row) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Count returns the number of rows
+func (t Table[T]) Count(tx *Tx) int {
+	data := tx.s.table(t.name)
+	if tx.tenant == "" {
+		return len(data.rows)
+	}
+	n := 0
+	for _, row := range data.rows {
+		if tx.visible(row) {
+			n++
+		}
+	}
+	return n
+}
+
+// Insert allocates the next ID and stores the row built by fn
+func (t Table[T]) Insert(tx *Tx, fn func(id int64) T) (T, error) {
+	tx.mustWrite()
+	data := tx.s.table(t.name)
+	id := data.nextID
+	data.nextID++
+	tx.undo = append(tx.undo, func() { data.nextID = id })
+
+	return t.Save(tx, id, fn(id))
+}
+
+// Put stores row under id, replacing any existing row
+func (t Table[T]) Put(tx *Tx, id int64, row T) error {
+	_, err := t.Save(tx, id, row)
+	return err
+}
+
+// Save stores row under id like Put and returns the stored row, including
+// change metadata set by the store. In a transaction scoped to a tenant the
+// row is assigned to it, and rows of other tenants cannot be replaced.
+func (t Table[T]) Save(tx *Tx, id int64, row T) (T, error) {
+	tx.mustWrite()
+	data := tx.s.table(t.name)
+	prev, existed := data.rows[id]
+	if existed && !tx.visible(prev) {
+		return row, ErrNotFound
+	}
+	if tenanted, ok := any(&row).(Tenanted); ok && tx.tenant != "" {
+		tenanted.SetTenant(tx.tenant)
+	}
+	if s, ok := any(&row).(Stamper); ok {
+		s.Stamp(tx.now, tx.actor)
+	}
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return row, err
+	}
+
+	data.rows[id] = row
+	prevNext := data.nextID
+	if id >= data.nextID {
+		data.nextID = id + 1
+	}
+	tx.undo = append(tx.undo, func() {
+		data.nextID = prevNext
+		if existed {
+			data.rows[id] = prev
+		} else {
+			delete(data.rows, id)
+		}
+	})
+	tx.ops = append(tx.ops, op{Op: opPut, Table: t.name, ID: id, Data: encoded})
+	return row, nil
+}
+
+// Delete removes the row with id and reports whether it existed
+func (t Table[T]) Delete(tx *Tx, id int64) bool {
+	tx.mustWrite()
+	data := tx.s.table(t.name)
+	prev, ok := data.rows[id]
+	if !ok || !tx.visible(prev) {
+		return false
+	}
+
+	delete(data.rows, id)
+	tx.undo = append(tx.undo, func() { data.rows[id] = prev })
+	tx.ops = append(tx.ops, op{Op: opDelete, Table: t.name, ID: id})
+	return true
+}
+
+// Stamper is implemented by rows whose change metadata is maintained by
+// the store. Stamp is called with the transaction time and actor whenever
+// the row is written.
+type Stamper interface {
+	Stamp(at time.Time, by string)
+}
+
+// Tenanted is implemented by rows that belong to a tenant. Transactions
+// scoped to a tenant only see its rows and assign it to the rows they write.
+type Tenanted interface {
+	TenantID() string
+	SetTenant(tenant string)
+}
+
+// Tx is a transaction. Changes are visible to the transaction immediately
+// and rolled back if it fails.
+type Tx struct {
+	s        *Store
+	writable bool
+	ops      []op
+	undo     []func()
+	emitted  bool
+	now      time.Time
+	actor    string
+	tenant   string
+}
+
+// Tenant returns the tenant the transaction is scoped to, or "" if it sees
+// every tenant's rows
+func (tx *Tx) Tenant() string {
+	return tx.tenant
+}
+
+// visible reports whether row is in the transaction's scope. Rows without
+// a tenant are only visible to unscoped transactions.
+func (tx *Tx) visible(row any) bool {
+	if tx.tenant == "" {
+		return true
+	}
+	t, ok := row.(interface{ TenantID() string })
+	return ok && t.TenantID() == tx.tenant
+}
+
+func (tx *Tx) mustWrite() {
+	if !tx.writable {
+		panic("store: write in read-only transaction")
+	}
+}
+
+func (tx *Tx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+	tx.ops = nil
+}
@@ -0,0 +1,18 @@
+package store
+
+import "github.com/gostructure/app/internal/model"
+
+// Application tables
+var (
+	Users = NewTable[model.User]("users")
+	Items = NewTable[model.Item]("items")
+	// Movements is the append-only inventory ledger
+	Movements    = NewTable[model.StockMovement]("stock_movements")
+	Reservations = NewTable[model.Reservation]("reservations")
+	Orders       = NewTable[model.Order]("orders")
+	Categories   = NewTable[model.Category]("categories")
+	Attachments  = NewTable[model.Attachment]("attachments")
+	// Prices is the price history of items, including scheduled changes
+	Prices = NewTable[model.PriceChange]("price_changes")
+	Alerts = NewTable[model.Alert]("alerts")
+)
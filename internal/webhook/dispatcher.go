@@ -0,0 +1,602 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/uuid"
+)
+
+// Delivery states. Failed deliveries are retried until MaxAttempts, then
+// dead-lettered until redelivered manually.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+const (
+	defaultWorkers        = 4
+	defaultTimeout        = 10 * time.Second
+	defaultMaxAttempts    = 8
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Hour
+	defaultPollInterval   = time.Second
+	// maxDeliveriesPerSubscription bounds the delivery log kept in the store
+	maxDeliveriesPerSubscription = 1000
+	maxResponseBody              = 1 << 10
+)
+
+var (
+	// ErrNotFound is returned for unknown subscriptions and deliveries
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidURL is returned for subscription URLs that are not absolute
+	// http or https URLs
+	ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+)
+
+// Subscription is a registered webhook endpoint
+type Subscription struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events lists event types such as "item.updated"; "item.*" matches a
+	// resource and an empty list matches everything
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	// Tenant restricts the subscription to one tenant's events
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Matches reports whether the subscription wants events of eventType
+func (s *Subscription) Matches(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	resource, _, _ := strings.Cut(eventType, ".")
+	for _, pattern := range s.Events {
+		if pattern == "*" || pattern == eventType || pattern == resource+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Attempt records one delivery attempt
+type Attempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Response   string        `json:"response,omitempty"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// Delivery is an event queued for a subscription. EventID is the dedup ID
+// of the event it carries.
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id,omitempty"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       []Attempt       `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at,omitempty"`
+	RedeliveryOf   string          `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// subscriptionRow and deliveryRow store subscriptions and deliveries under
+// the table key, which is not part of the API
+type subscriptionRow struct {
+	Key int64 `json:"key"`
+	Subscription
+}
+
+type deliveryRow struct {
+	Key int64 `json:"key"`
+	Delivery
+}
+
+// Tables hold subscriptions and deliveries so pending deliveries survive
+// restarts
+var (
+	subscriptionTable = store.NewTable[subscriptionRow]("webhook_subscriptions")
+	deliveryTable     = store.NewTable[deliveryRow]("webhook_deliveries")
+)
+
+// Dispatcher persists subscriptions and their deliveries in the store and
+// delivers events to them with retries
+type Dispatcher struct {
+	cfg    config.WebhookConfig
+	store  *store.Store
+	client *http.Client
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+	wake     chan struct{}
+}
+
+// NewDispatcher creates a dispatcher backed by s. Zero config values use
+// defaults.
+func NewDispatcher(cfg config.WebhookConfig, s *store.Store, client *http.Client) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &Dispatcher{
+		cfg:      cfg,
+		store:    s,
+		client:   client,
+		inFlight: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Create registers a subscription, generating a secret if none is given
+func (d *Dispatcher) Create(s Subscription) (Subscription, error) {
+	if err := validateURL(s.URL); err != nil {
+		return Subscription{}, err
+	}
+	if s.Secret == "" {
+		s.Secret = newSecret()
+	}
+
+	now := time.Now().UTC()
+	s.ID = uuid.New()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+
+	err := d.store.Update(func(tx *store.Tx) error {
+		_, err := subscriptionTable.Insert(tx, func(key int64) subscriptionRow {
+			return subscriptionRow{Key: key, Subscription: s}
+		})
+		return err
+	})
+	if err != nil {
+		return Subscription{}, err
+	}
+	return s, nil
+}
+
+// Get returns a subscription by ID
+func (d *Dispatcher) Get(id string) (Subscription, error) {
+	var row subscriptionRow
+	var ok bool
+	d.store.View(func(tx *store.Tx) error {
+		row, ok = findSubscription(tx, id)
+		return nil
+	})
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return row.Subscription, nil
+}
+
+// List returns all subscriptions ordered by creation time
+func (d *Dispatcher) List() []Subscription {
+	var rows []subscriptionRow
+	d.store.View(func(tx *store.Tx) error {
+		rows = subscriptionTable.List(tx)
+		return nil
+	})
+
+	list := make([]Subscription, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, row.Subscription)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Update applies fn to a subscription and stores the result
+func (d *Dispatcher) Update(id string, fn func(*Subscription)) (Subscription, error) {
+	var updated Subscription
+	err := d.store.Update(func(tx *store.Tx) error {
+		row, ok := findSubscription(tx, id)
+		if !ok {
+			return ErrNotFound
+		}
+
+		updated = row.Subscription
+		fn(&updated)
+		if err := validateURL(updated.URL); err != nil {
+			return err
+		}
+		updated.ID = row.ID
+		updated.CreatedAt = row.CreatedAt
+		updated.UpdatedAt = time.Now().UTC()
+		row.Subscription = updated
+		return subscriptionTable.Put(tx, row.Key, row)
+	})
+	if err != nil {
+		return Subscription{}, err
+	}
+	return updated, nil
+}
+
+// Delete removes a subscription and its delivery log
+func (d *Dispatcher) Delete(id string) error {
+	return d.store.Update(func(tx *store.Tx) error {
+		row, ok := findSubscription(tx, id)
+		if !ok {
+			return ErrNotFound
+		}
+		subscriptionTable.Delete(tx, row.Key)
+		for _, delivery := range subscriptionDeliveries(tx, id) {
+			deliveryTable.Delete(tx, delivery.Key)
+		}
+		return nil
+	})
+}
+
+// Enqueue persists a delivery for every active subscription matching e, so
+// the event is not lost once Enqueue returns. Events already enqueued with
+// the same DedupID are ignored.
+func (d *Dispatcher) Enqueue(e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	queued := false
+	err = d.store.Update(func(tx *store.Tx) error {
+		if e.DedupID != "" && len(deliveryTable.Filter(tx, func(row deliveryRow) bool {
+			return row.EventID == e.DedupID
+		})) > 0 {
+			return nil
+		}
+		for _, s := range subscriptionTable.List(tx) {
+			if !s.Active || s.Tenant != e.Tenant || !s.Matches(e.Type) {
+				continue
+			}
+			_, err := addDelivery(tx, Delivery{
+				SubscriptionID: s.ID,
+				EventID:        e.DedupID,
+				EventType:      e.Type,
+				Payload:        payload,
+			})
+			if err != nil {
+				return err
+			}
+			queued = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if queued {
+		d.notify()
+	}
+	return nil
+}
+
+// Deliveries returns the delivery log of a subscription, newest first,
+// optionally filtered by status
+func (d *Dispatcher) Deliveries(subscriptionID, status string, limit int) ([]Delivery, error) {
+	var rows []deliveryRow
+	var ok bool
+	d.store.View(func(tx *store.Tx) error {
+		if _, ok = findSubscription(tx, subscriptionID); ok {
+			rows = subscriptionDeliveries(tx, subscriptionID)
+		}
+		return nil
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	list := make([]Delivery, 0)
+	for i := len(rows) - 1; i >= 0; i-- {
+		if status != "" && rows[i].Status != status {
+			continue
+		}
+		list = append(list, rows[i].Delivery)
+		if limit > 0 && len(list) == limit {
+			break
+		}
+	}
+	return list, nil
+}
+
+// Redeliver queues a new delivery of the same payload for immediate sending
+func (d *Dispatcher) Redeliver(subscriptionID, deliveryID string) (Delivery, error) {
+	var delivery Delivery
+	err := d.store.Update(func(tx *store.Tx) error {
+		original, ok := findDelivery(tx, deliveryID)
+		if !ok || original.SubscriptionID != subscriptionID {
+			return ErrNotFound
+		}
+		var err error
+		delivery, err = addDelivery(tx, Delivery{
+			SubscriptionID: original.SubscriptionID,
+			EventID:        original.EventID,
+			EventType:      original.EventType,
+			Payload:        original.Payload,
+			RedeliveryOf:   original.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	d.notify()
+	return delivery, nil
+}
+
+// Run delivers due deliveries with the configured number of workers until
+// ctx is cancelled, then waits for attempts in progress to finish. Pending
+// deliveries left by a previous process are picked up again.
+func (d *Dispatcher) Run(ctx context.Context) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < d.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				d.attempt(id)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	ticker := time.NewTicker(defaultPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, id := range d.claimDue() {
+			select {
+			case jobs <- id:
+			case <-ctx.Done():
+				d.release(id)
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// addDelivery stores a new pending delivery and trims the oldest finished
+// deliveries of its subscription from the log
+func addDelivery(tx *store.Tx, delivery Delivery) (Delivery, error) {
+	now := time.Now().UTC()
+	delivery.ID = uuid.New()
+	delivery.Status = StatusPending
+	delivery.CreatedAt = now
+	delivery.NextAttemptAt = now
+
+	_, err := deliveryTable.Insert(tx, func(key int64) deliveryRow {
+		return deliveryRow{Key: key, Delivery: delivery}
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	rows := subscriptionDeliveries(tx, delivery.SubscriptionID)
+	excess := len(rows) - maxDeliveriesPerSubscription
+	for _, row := range rows {
+		if excess <= 0 {
+			break
+		}
+		if row.Status != StatusPending {
+			deliveryTable.Delete(tx, row.Key)
+			excess--
+		}
+	}
+	return delivery, nil
+}
+
+func findSubscription(tx *store.Tx, id string) (subscriptionRow, bool) {
+	rows := subscriptionTable.Filter(tx, func(row subscriptionRow) bool { return row.ID == id })
+	if len(rows) == 0 {
+		return subscriptionRow{}, false
+	}
+	return rows[0], true
+}
+
+func findDelivery(tx *store.Tx, id string) (deliveryRow, bool) {
+	rows := deliveryTable.Filter(tx, func(row deliveryRow) bool { return row.ID == id })
+	if len(rows) == 0 {
+		return deliveryRow{}, false
+	}
+	return rows[0], true
+}
+
+// subscriptionDeliveries returns the deliveries of a subscription, oldest
+// first
+func subscriptionDeliveries(tx *store.Tx, subscriptionID string) []deliveryRow {
+	return deliveryTable.Filter(tx, func(row deliveryRow) bool {
+		return row.SubscriptionID == subscriptionID
+	})
+}
+
+func (d *Dispatcher) claimDue() []string {
+	now := time.Now()
+	var due []deliveryRow
+	d.store.View(func(tx *store.Tx) error {
+		due = deliveryTable.Filter(tx, func(row deliveryRow) bool {
+			return row.Status == StatusPending && !row.NextAttemptAt.After(now)
+		})
+		return nil
+	})
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var ids []string
+	for _, row := range due {
+		if !d.inFlight[row.ID] {
+			d.inFlight[row.ID] = true
+			ids = append(ids, row.ID)
+		}
+	}
+	return ids
+}
+
+func (d *Dispatcher) release(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, id)
+}
+
+func (d *Dispatcher) attempt(id string) {
+	defer d.release(id)
+
+	var delivery deliveryRow
+	var sub subscriptionRow
+	var ok bool
+	d.store.View(func(tx *store.Tx) error {
+		if delivery, ok = findDelivery(tx, id); ok {
+			sub, ok = findSubscription(tx, delivery.SubscriptionID)
+		}
+		return nil
+	})
+	if !ok || delivery.Status != StatusPending {
+		return
+	}
+
+	result := d.send(sub.URL, sub.Secret, id, delivery.EventType, delivery.Payload)
+
+	err := d.store.Update(func(tx *store.Tx) error {
+		// The subscription may have been deleted while sending
+		row, ok := findDelivery(tx, id)
+		if !ok {
+			return nil
+		}
+		row.Attempts = append(slices.Clone(row.Attempts), result)
+		switch {
+		case result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300:
+			row.Status = StatusSucceeded
+			row.NextAttemptAt = time.Time{}
+		case len(row.Attempts) >= d.cfg.MaxAttempts:
+			row.Status = StatusDead
+			row.NextAttemptAt = time.Time{}
+			log.Printf("Webhook delivery %s to %s dead-lettered after %d attempts", id, sub.URL, len(row.Attempts))
+		default:
+			row.NextAttemptAt = time.Now().UTC().Add(d.backoff(len(row.Attempts)))
+		}
+		return deliveryTable.Put(tx, row.Key, row)
+	})
+	if err != nil {
+		log.Printf("Failed to record webhook delivery %s: %v", id, err)
+	}
+}
+
+func (d *Dispatcher) send(target, secret, id, eventType string, payload []byte) Attempt {
+	start := time.Now()
+	result := Attempt{At: start.UTC()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderSignature, Sign(secret, start, payload))
+
+	resp, err := d.client.Do(req)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	result.StatusCode = resp.StatusCode
+	result.Response = string(body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return result
+}
+
+// backoff doubles the delay after every failed attempt up to MaxBackoff
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func newSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return uuid.New()
+	}
+	return "whsec_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,289 @@
+// Package money represents amounts of money exactly as integer minor units
+// (such as cents) of an ISO 4217 currency.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed for amounts given as plain JSON numbers, the
+// format used before amounts carried a currency
+const DefaultCurrency = "USD"
+
+var (
+	// ErrCurrency is returned for unknown currency codes
+	ErrCurrency = errors.New("unknown currency")
+	// ErrCurrencyMismatch is returned when combining different currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrScale is returned for amounts more precise than the currency's
+	// minor unit
+	ErrScale = errors.New("too many decimal places")
+	// ErrOverflow is returned when a result does not fit in 64 bits
+	ErrOverflow = errors.New("amount out of range")
+)
+
+// scales maps ISO 4217 codes to the number of digits of their minor unit
+var scales = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BGN": 2, "BHD": 3, "BRL": 2, "CAD": 2,
+	"CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KES": 2, "KRW": 0, "KWD": 3,
+	"LYD": 3, "MAD": 2, "MXN": 2, "MYR": 2, "NGN": 2, "NOK": 2, "NZD": 2,
+	"OMR": 3, "PEN": 2, "PHP": 2, "PKR": 2, "PLN": 2, "QAR": 2, "RON": 2,
+	"RSD": 2, "RUB": 2, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "TND": 3,
+	"TRY": 2, "TWD": 2, "UAH": 2, "UGX": 0, "USD": 2, "VND": 0, "XAF": 0,
+	"XOF": 0, "ZAR": 2,
+}
+
+// Money is an amount in the minor unit of a currency. The zero value has
+// no currency and is treated as unset.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// Scale returns the number of minor unit digits of currency
+func Scale(currency string) (int, error) {
+	scale, ok := scales[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrCurrency, currency)
+	}
+	return scale, nil
+}
+
+// New creates an amount of minor units, such as cents, of currency
+func New(minor int64, currency string) (Money, error) {
+	if _, err := Scale(currency); err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// Zero returns no money in currency
+func Zero(currency string) (Money, error) {
+	return New(0, currency)
+}
+
+// Parse parses a decimal amount such as "-12.50" in currency. The amount
+// may not have more decimal places than the currency's minor unit.
+func Parse(amount, currency string) (Money, error) {
+	scale, err := Scale(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := amount
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || (hasFrac && frac == "") || !digits(whole) || !digits(frac) {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if trimmed := strings.TrimRight(frac, "0"); len(trimmed) > scale {
+		return Money{}, fmt.Errorf("%w for %s: %q", ErrScale, currency, amount)
+	}
+	frac += strings.Repeat("0", scale)
+
+	minor, err := strconv.ParseInt(whole+frac[:scale], 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on error
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 { return m.minor }
+
+// Currency returns the ISO 4217 currency code
+func (m Money) Currency() string { return m.currency }
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool { return m.minor == 0 }
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool { return m.minor < 0 }
+
+// Amount formats the amount as a decimal string with the currency's
+// number of decimal places, such as "12.50"
+func (m Money) Amount() string {
+	scale := scales[m.currency]
+	s := strconv.FormatInt(m.minor, 10)
+	sign := ""
+	if m.minor < 0 {
+		sign, s = "-", s[1:]
+	}
+	if scale == 0 {
+		return sign + s
+	}
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-scale] + "." + s[len(s)-scale:]
+}
+
+func (m Money) String() string {
+	return m.Amount() + " " + m.currency
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or 1
+func (m Money) Cmp(o Money) (int, error) {
+	if m.currency != o.currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	switch {
+	case m.minor < o.minor:
+		return -1, nil
+	case m.minor > o.minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	sum := m.minor + o.minor
+	if (o.minor > 0 && sum < m.minor) || (o.minor < 0 && sum > m.minor) {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: sum, currency: m.currency}, nil
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) (Money, error) {
+	if o.minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{minor: -o.minor, currency: o.currency})
+}
+
+// Mul returns m multiplied by n, such as a unit price times a quantity
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: product.Int64(), currency: m.currency}, nil
+}
+
+// Percent returns basisPoints hundredths of a percent of m, rounded half
+// away from zero to the minor unit. A 12.5% discount is Percent(1250).
+func (m Money) Percent(basisPoints int64) (Money, error) {
+	num := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(basisPoints))
+	den := big.NewInt(10000)
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero
+	if new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: q.Int64(), currency: m.currency}, nil
+}
+
+// Discount returns m reduced by basisPoints hundredths of a percent
+func (m Money) Discount(basisPoints int64) (Money, error) {
+	off, err := m.Percent(basisPoints)
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Sub(off)
+}
+
+// Sum adds amounts of the same currency. The sum of no amounts is the zero
+// value.
+func Sum(amounts ...Money) (Money, error) {
+	if len(amounts) == 0 {
+		return Money{}, nil
+	}
+	total := amounts[0]
+	for _, m := range amounts[1:] {
+		var err error
+		if total, err = total.Add(m); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes m as {"amount": "12.50", "currency": "USD"}, with
+// the amount as a string so it is never rounded by a float parser. The
+// zero value encodes as null.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.currency == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(jsonMoney{Amount: m.Amount(), Currency: m.currency})
+}
+
+// UnmarshalJSON decodes the format written by MarshalJSON. A plain number
+// is read exactly from its decimal text as an amount in DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] != '{' {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid amount: %w", err)
+		}
+		parsed, err := Parse(n.String(), DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := Parse(v.Amount, strings.ToUpper(v.Currency))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
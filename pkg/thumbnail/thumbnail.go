@@ -0,0 +1,102 @@
+// Package thumbnail scales images down to preview sizes using only the
+// standard library decoders.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // register GIF decoding
+	"image/jpeg"
+	"image/png"
+)
+
+var (
+	// ErrUnsupported is returned for data that is not a PNG, JPEG or GIF
+	// image
+	ErrUnsupported = errors.New("unsupported image format")
+	// ErrTooLarge is returned for images with more than MaxPixels pixels,
+	// which are not decoded to bound memory use
+	ErrTooLarge = errors.New("image too large")
+)
+
+// MaxPixels bounds the size of images that are decoded
+const MaxPixels = 40_000_000
+
+// Dimensions returns the width and height of an image without decoding it
+func Dimensions(data []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, ErrUnsupported
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Generate scales an image down to fit within size by size pixels, keeping
+// its aspect ratio, and returns the encoded thumbnail and its content type.
+// JPEG images stay JPEG; others are encoded as PNG.
+func Generate(data []byte, size int) ([]byte, string, error) {
+	width, height, err := Dimensions(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if width*height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+	dst := scale(src, fit(src.Bounds().Dx(), src.Bounds().Dy(), size))
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", err
+}
+
+// fit returns the largest size within size by size with the aspect ratio of
+// width by height, never enlarging
+func fit(width, height, size int) image.Point {
+	if width <= size && height <= size {
+		return image.Pt(width, height)
+	}
+	if width >= height {
+		return image.Pt(size, max(1, height*size/width))
+	}
+	return image.Pt(max(1, width*size/height), size)
+}
+
+// scale resizes src to dim by averaging the source pixels covered by each
+// destination pixel
+func scale(src image.Image, dim image.Point) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, dim.X, dim.Y))
+	for dy := 0; dy < dim.Y; dy++ {
+		y0 := b.Min.Y + dy*b.Dy()/dim.Y
+		y1 := max(y0+1, b.Min.Y+(dy+1)*b.Dy()/dim.Y)
+		for dx := 0; dx < dim.X; dx++ {
+			x0 := b.Min.X + dx*b.Dx()/dim.X
+			x1 := max(x0+1, b.Min.X+(dx+1)*b.Dx()/dim.X)
+
+			var r, g, bl, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, pa := src.At(x, y).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/notify"
+	"github.com/gostructure/app/internal/webhook"
+)
+
+func listAlerts(t *testing.T, application *app.App, query string) []model.Alert {
+	t.Helper()
+	rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/alerts?"+query, "")
+	var list struct {
+		Alerts []model.Alert `json:"alerts"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	return list.Alerts
+}
+
+func TestLowStockAlerts(t *testing.T) {
+	var mu sync.Mutex
+	var received []notify.Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify("alert-secret", r.Header.Get(webhook.HeaderSignature), body, time.Minute); err != nil {
+			t.Errorf("Expected a signed notification: %v", err)
+		}
+		var n notify.Notification
+		json.Unmarshal(body, &n)
+		mu.Lock()
+		received = append(received, n)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	notifications := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+		},
+		Scheduler: config.SchedulerConfig{Schedules: map[string]string{"alerts.evaluate": "@every 10ms"}},
+		Alerts: config.AlertsConfig{
+			Channels:      []string{"webhook"},
+			WebhookURL:    srv.URL,
+			WebhookSecret: "alert-secret",
+		},
+	}
+	application := app.New(cfg)
+	startApp(t, application)
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Filter Paper", "quantity": 5, "reorder_threshold": 3}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Mug", "quantity": 1}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/adjustments", `{"type": "sale", "quantity": 3}`)
+
+	waitFor(t, "low-stock notification", func() bool { return notifications() == 1 })
+	// Further evaluations do not raise the alert again
+	time.Sleep(50 * time.Millisecond)
+	alerts := listAlerts(t, application, "status=open")
+	if len(alerts) != 1 || alerts[0].ItemID != 1 || alerts[0].Quantity != 2 || alerts[0].Threshold != 3 || notifications() != 1 {
+		t.Fatalf("Expected one open alert for the filter paper, got %+v after %d notifications", alerts, notifications())
+	}
+	mu.Lock()
+	if n := received[0]; n.Kind != "alert.low_stock" || n.Subject != "Low stock: Filter Paper" {
+		t.Errorf("Unexpected notification %+v", n)
+	}
+	mu.Unlock()
+
+	rec := doAs(t, application, "bob", http.MethodPost, "/api/v1/alerts/1:acknowledge", "")
+	var alert model.Alert
+	json.NewDecoder(rec.Body).Decode(&alert)
+	if rec.Code != http.StatusOK || alert.Status != model.AlertAcknowledged || alert.AcknowledgedBy != "bob" {
+		t.Errorf("Expected bob's acknowledgment, got status %d: %+v", rec.Code, alert)
+	}
+	if rec := doAs(t, application, "bob", http.MethodPost, "/api/v1/alerts/1:acknowledge", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d acknowledging twice, got %d", http.StatusConflict, rec.Code)
+	}
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/adjustments", `{"type": "receipt", "quantity": 10}`)
+	waitFor(t, "alert to resolve", func() bool {
+		return len(listAlerts(t, application, "status=resolved")) == 1
+	})
+
+	// A new shortage raises a new alert
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/adjustments", `{"type": "sale", "quantity": 11}`)
+	waitFor(t, "second notification", func() bool { return notifications() == 2 })
+	if alerts := listAlerts(t, application, "item_id=1"); len(alerts) != 2 {
+		t.Errorf("Expected two alerts for the item, got %+v", alerts)
+	}
+}
+
+func TestNotifyChannelsSkipMisconfigured(t *testing.T) {
+	channels, err := notify.New(config.AlertsConfig{Channels: []string{"log", "webhook", "pager"}})
+	if err == nil {
+		t.Error("Expected an error for the misconfigured channels")
+	}
+	if len(channels) != 1 || channels[0].Name() != "log" {
+		t.Errorf("Expected the log channel to be kept, got %v", channels)
+	}
+}
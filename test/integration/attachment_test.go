@@ -0,0 +1,142 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/model"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func upload(t *testing.T, application *app.App, path, filename string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadAs(t, application, "alice", path, filename, data)
+}
+
+func uploadAs(t *testing.T, application *app.App, subject, path, filename string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", filename)
+	part.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Auth-Subject", subject)
+	rec := httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAttachments(t *testing.T) {
+	application := setupAuditApp()
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Lamp"}`)
+
+	image := testPNG(t, 600, 300)
+	rec := upload(t, application, "/api/v1/items/1/attachments", "lamp.png", image)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var attachment model.Attachment
+	json.NewDecoder(rec.Body).Decode(&attachment)
+	if attachment.ContentType != "image/png" || attachment.Width != 600 || attachment.Height != 300 || attachment.Size != int64(len(image)) {
+		t.Errorf("Unexpected attachment %+v", attachment)
+	}
+
+	// The same contents are not stored twice
+	rec = upload(t, application, "/api/v1/items/1/attachments", "copy.png", image)
+	var duplicate model.Attachment
+	json.NewDecoder(rec.Body).Decode(&duplicate)
+	if rec.Code != http.StatusOK || duplicate.ID != attachment.ID {
+		t.Errorf("Expected the existing attachment with status %d, got %d: %+v", http.StatusOK, rec.Code, duplicate)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/items/1/attachments/1/thumbnail", "")
+	thumb, err := png.DecodeConfig(rec.Body)
+	if err != nil || thumb.Width != 256 || thumb.Height != 128 {
+		t.Errorf("Expected a 256x128 thumbnail, got %dx%d (%v)", thumb.Width, thumb.Height, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/items/1/attachments/1", nil)
+	req.Header.Set("Range", "bytes=0-7")
+	rec = httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), image[:8]) {
+		t.Errorf("Expected the first 8 bytes with status %d, got %d", http.StatusPartialContent, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `inline; filename=lamp.png` {
+		t.Errorf("Unexpected Content-Disposition %q", got)
+	}
+
+	var detail struct {
+		Attachments []model.Attachment `json:"attachments"`
+	}
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/items/1", "")
+	json.NewDecoder(rec.Body).Decode(&detail)
+	if len(detail.Attachments) != 1 || detail.Attachments[0].Checksum != attachment.Checksum {
+		t.Errorf("Expected the item to list its attachment, got %+v", detail.Attachments)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/1/attachments/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d deleting, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items/1/attachments/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after deleting, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAttachmentLimits(t *testing.T) {
+	application := app.New(&config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+		},
+		Attachments: config.AttachmentsConfig{
+			MaxSize:      1024,
+			AllowedTypes: []string{"text/plain", "image/png"},
+		},
+	})
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Lamp"}`)
+
+	if rec := upload(t, application, "/api/v1/items/1/attachments", "notes.txt", []byte("care instructions")); rec.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	// The type is sniffed from the contents, not the filename
+	if rec := upload(t, application, "/api/v1/items/1/attachments", "manual.txt", []byte("%PDF-1.7\n")); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status %d for a PDF, got %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+	if rec := upload(t, application, "/api/v1/items/1/attachments", "big.txt", bytes.Repeat([]byte("x"), 2048)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for an oversized file, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	if rec := upload(t, application, "/api/v1/items/9/attachments", "notes.txt", []byte("care instructions")); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing item, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/attachments", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a JSON body, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,201 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/store"
+)
+
+func setupAuditApp(opts ...app.Option) *app.App {
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+			RolesHeader:   "X-Auth-Roles",
+			AdminSubjects: []string{"auditor"},
+		},
+	}
+	return app.New(cfg, opts...)
+}
+
+func doAs(t *testing.T, application *app.App, subject, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if subject != "" {
+		req.Header.Set("X-Auth-Subject", subject)
+	}
+	rec := httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuditTrail(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "quantity": 5}`)
+	var item struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&item); err != nil {
+		t.Fatalf("Failed to decode item: %v", err)
+	}
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+
+	doAs(t, application, "bob", http.MethodPut, path, `{"quantity": 3}`)
+	doAs(t, application, "alice", http.MethodDelete, path, "")
+
+	// Non-admins cannot read the audit log
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/audit", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	rec = doAs(t, application, "auditor", http.MethodGet, "/api/v1/audit?resource=item", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var result struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode audit: %v", err)
+	}
+	if len(result.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(result.Entries))
+	}
+
+	update := result.Entries[1]
+	if update.Action != audit.ActionUpdate || update.Actor != "bob" || update.RequestID == "" {
+		t.Errorf("Unexpected update entry: %+v", update)
+	}
+	change, ok := update.Changes["quantity"]
+	if !ok || string(change.From) != "5" || string(change.To) != "3" {
+		t.Errorf("Expected quantity change 5 -> 3, got %+v", update.Changes)
+	}
+	if _, ok := update.Changes["name"]; ok {
+		t.Error("Unchanged fields must not appear in the diff")
+	}
+	if update.PrevHash != result.Entries[0].Hash {
+		t.Error("Expected entries to be hash chained")
+	}
+
+	rec = doAs(t, application, "auditor", http.MethodGet, "/api/v1/audit?actor=alice", "")
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode audit: %v", err)
+	}
+	if len(result.Entries) != 2 {
+		t.Errorf("Expected 2 entries by alice, got %d", len(result.Entries))
+	}
+
+	rec = doAs(t, application, "auditor", http.MethodGet, "/api/v1/audit/verify", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected chain to verify, got status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuditFileTamperEvidence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := audit.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	application := setupAuditApp(app.WithAuditLog(auditLog))
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Ann", "email": "ann@example.com"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Ben", "email": "ben@example.com"}`)
+	// Reading the log moves the committed entries into it
+	doAs(t, application, "auditor", http.MethodGet, "/api/v1/audit/verify", "")
+	auditLog.Close()
+
+	// Reopening verifies the persisted chain
+	reopened, err := audit.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen audit log: %v", err)
+	}
+	if reopened.Len() != 2 {
+		t.Errorf("Expected 2 persisted entries, got %d", reopened.Len())
+	}
+	reopened.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(data), "ann@example.com", "eve@example.com", 1)
+	if err := os.WriteFile(path, []byte(tampered), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := audit.OpenFile(path); err == nil {
+		t.Error("Expected tampered audit log to fail verification")
+	}
+}
+
+func TestAuditEntryCommittedWithChange(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store.journal")
+	auditPath := filepath.Join(dir, "audit.log")
+
+	s, err := store.OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	auditLog, err := audit.OpenFile(auditPath)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	application := setupAuditApp(app.WithStore(s), app.WithAuditLog(auditLog))
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Ann", "email": "ann@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// Stop before the entry reaches the audit log, as in a crash
+	s.Close()
+	auditLog.Close()
+
+	reopenedStore, err := store.OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopenedStore.Close()
+	reopenedLog, err := audit.OpenFile(auditPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen audit log: %v", err)
+	}
+	defer reopenedLog.Close()
+	if reopenedLog.Len() != 0 {
+		t.Fatalf("Expected the entry to be pending, got %d entries in the log", reopenedLog.Len())
+	}
+
+	restarted := setupAuditApp(app.WithStore(reopenedStore), app.WithAuditLog(reopenedLog))
+	rec = doAs(t, restarted, "auditor", http.MethodGet, "/api/v1/audit", "")
+	var result struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode audit: %v", err)
+	}
+	if len(result.Entries) != 1 {
+		t.Fatalf("Expected 1 entry after restart, got %d", len(result.Entries))
+	}
+	if e := result.Entries[0]; e.Actor != "alice" || e.Action != audit.ActionCreate || e.Resource != "user" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+
+	// Flushing again does not duplicate the entry
+	doAs(t, restarted, "auditor", http.MethodGet, "/api/v1/audit", "")
+	if reopenedLog.Len() != 1 {
+		t.Errorf("Expected 1 entry after a second flush, got %d", reopenedLog.Len())
+	}
+}
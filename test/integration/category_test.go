@@ -0,0 +1,171 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/model"
+)
+
+type categoryList struct {
+	Categories []struct {
+		model.Category
+		ItemCount int `json:"item_count"`
+	} `json:"categories"`
+	Total int `json:"total"`
+}
+
+func listItemNames(t *testing.T, application *app.App, query string) []string {
+	t.Helper()
+	rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items?"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var list struct {
+		Items []model.Item `json:"items"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestCategoryTree(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/categories", "")
+	if body := rec.Body.String(); !strings.Contains(body, `"categories":[]`) {
+		t.Errorf("Expected an empty list without categories, got %s", body)
+	}
+
+	for _, body := range []string{
+		`{"name": "Kitchen & Dining"}`,
+		`{"name": "Coffee", "parent_id": 1}`,
+		`{"name": "Grinders", "slug": "grinders", "parent_id": 2}`,
+		`{"name": "Garden"}`,
+	} {
+		if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/categories", body); rec.Code != http.StatusCreated {
+			t.Fatalf("%s: expected status %d, got %d: %s", body, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/categories/kitchen-dining", "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected lookup by derived slug, got status %d", rec.Code)
+	}
+
+	for body, code := range map[string]int{
+		`{"name": "Coffee"}`:                    http.StatusConflict,
+		`{"name": "Tea", "slug": "Tea Leaves"}`: http.StatusBadRequest,
+		`{"name": "Tea", "slug": "2024"}`:       http.StatusBadRequest,
+		`{"name": "2024"}`:                      http.StatusBadRequest,
+		`{"name": "Tea", "parent_id": 99}`:      http.StatusBadRequest,
+		`{"name": ""}`:                          http.StatusBadRequest,
+	} {
+		if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/categories", body); rec.Code != code {
+			t.Errorf("%s: expected status %d, got %d", body, code, rec.Code)
+		}
+	}
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Burr Grinder"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Espresso Cup"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Rake"}`)
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/1/category", `{"category_id": 3}`)
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/2/category", `{"category_id": 2}`)
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/3/category", `{"category_id": 4}`)
+	if rec := doAs(t, application, "alice", http.MethodPut, "/api/v1/items/3/category", `{"category_id": 99}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown category, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	// Filtering by a category includes its subtree
+	if names := listItemNames(t, application, "category=kitchen-dining"); len(names) != 2 {
+		t.Errorf("Expected both kitchen items, got %v", names)
+	}
+	if names := listItemNames(t, application, "category=3"); len(names) != 1 || names[0] != "Burr Grinder" {
+		t.Errorf("Expected only the grinder, got %v", names)
+	}
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items?category=nowhere", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown category, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var list categoryList
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/categories", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	counts := make(map[string]int)
+	for _, c := range list.Categories {
+		counts[c.Slug] = c.ItemCount
+	}
+	if counts["kitchen-dining"] != 2 || counts["coffee"] != 2 || counts["grinders"] != 1 || counts["garden"] != 1 {
+		t.Errorf("Unexpected item counts %v", counts)
+	}
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/categories?parent_id=0", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 2 {
+		t.Errorf("Expected two root categories, got %+v", list.Categories)
+	}
+
+	// Moving a category into its own subtree would create a cycle
+	if rec := doAs(t, application, "alice", http.MethodPut, "/api/v1/categories/4", `{"slug": "2024"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d renaming to an all-digit slug, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/categories/1:move", `{"parent_id": 3}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a cycle, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/categories/2:move", `{"parent_id": 2}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d moving under itself, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/categories/3:move", `{"parent_id": 4}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d moving, got %d", http.StatusOK, rec.Code)
+	}
+	if names := listItemNames(t, application, "category=garden"); len(names) != 2 {
+		t.Errorf("Expected the moved subtree's items under garden, got %v", names)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodDelete, "/api/v1/categories/4", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d deleting a category with children, got %d", http.StatusConflict, rec.Code)
+	}
+	doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/1", "")
+	if rec := doAs(t, application, "alice", http.MethodDelete, "/api/v1/categories/3", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d deleting a category with only deleted items, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestItemTags(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Mug"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Teapot"}`)
+
+	rec := doAs(t, application, "alice", http.MethodPut, "/api/v1/items/1/tags", `{"tags": ["Ceramic", " blue ", "ceramic"]}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	if len(item.Tags) != 2 || item.Tags[0] != "blue" || item.Tags[1] != "ceramic" {
+		t.Errorf("Expected normalized tags, got %v", item.Tags)
+	}
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/tags", `{"tags": ["gift"]}`)
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/2/tags", `{"tags": ["ceramic", "gift"]}`)
+
+	if names := listItemNames(t, application, "tag=ceramic&tag=gift"); len(names) != 2 {
+		t.Errorf("Expected both items, got %v", names)
+	}
+	if names := listItemNames(t, application, "tag=ceramic&tag=Blue"); len(names) != 1 || names[0] != "Mug" {
+		t.Errorf("Expected every tag to be required, got %v", names)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/1/tags/blue", "")
+	json.NewDecoder(rec.Body).Decode(&item)
+	if len(item.Tags) != 2 {
+		t.Errorf("Expected tag to be removed, got %v", item.Tags)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPut, "/api/v1/items/1/tags", `{"tags": [""]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an empty tag, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPut, "/api/v1/items/9/tags", `{"tags": ["x"]}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing item, got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,214 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+)
+
+func setupCORSApp(cors config.CORSConfig) *app.App {
+	cfg := &config.Config{
+		App:  config.AppConfig{Name: "Test App", Environment: "test"},
+		CORS: cors,
+	}
+	return app.New(cfg)
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	application := setupCORSApp(config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://shop.example.org", true},
+		{"https://example.org", false},
+		{"https://evil.com", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/info", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+
+		application.Router().ServeHTTP(rec, req)
+
+		got := rec.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && got != tt.origin {
+			t.Errorf("Origin %s: expected echoed origin, got '%s'", tt.origin, got)
+		}
+		if !tt.allowed && got != "" {
+			t.Errorf("Origin %s: expected no allow header, got '%s'", tt.origin, got)
+		}
+		if rec.Header().Get("Vary") != "Origin" {
+			t.Errorf("Origin %s: expected Vary: Origin, got '%s'", tt.origin, rec.Header().Get("Vary"))
+		}
+		if tt.allowed && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("Origin %s: expected credentials header", tt.origin)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	application := setupCORSApp(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         10 * time.Minute,
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		headers string
+		status  int
+	}{
+		{"allowed", http.MethodPost, "content-type", http.StatusNoContent},
+		{"method rejected", http.MethodDelete, "", http.StatusForbidden},
+		{"header rejected", http.MethodPost, "X-Custom", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/users", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", tt.method)
+		if tt.headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", tt.headers)
+		}
+		rec := httptest.NewRecorder()
+
+		application.Router().ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+
+	if rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected max age 600, got '%s'", rec.Header().Get("Access-Control-Max-Age"))
+	}
+}
+
+func TestCORSNonPreflightOptionsReachesRouter(t *testing.T) {
+	application := setupCORSApp(config.CORSConfig{AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest(http.MethodOptions, "/does-not-exist", nil)
+	rec := httptest.NewRecorder()
+
+	application.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestCORSRouteOverride(t *testing.T) {
+	application := setupCORSApp(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		Routes: []config.CORSRouteConfig{
+			{PathPrefix: "/api/v1/info", AllowedOrigins: []string{"*"}},
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/info", nil)
+	req.Header.Set("Origin", "https://other.example.net")
+	rec := httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected wildcard origin on overridden route, got '%s'", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set("Origin", "https://other.example.net")
+	rec = httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no allow header on base route, got '%s'", got)
+	}
+}
+
+func TestCORSWildcardWithCredentials(t *testing.T) {
+	application := setupCORSApp(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		Routes: []config.CORSRouteConfig{
+			{PathPrefix: "/api/v1/info", AllowedOrigins: []string{"*", "https://app.example.com"}, AllowCredentials: true},
+		},
+	})
+
+	tests := []struct {
+		origin      string
+		allow       string
+		credentials string
+	}{
+		{"https://evil.com", "*", ""},
+		{"https://app.example.com", "https://app.example.com", "true"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/info", nil)
+		req.Header.Set("Origin", tt.origin)
+		rec := httptest.NewRecorder()
+		application.Router().ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+			t.Errorf("Origin %s: expected allow origin '%s', got '%s'", tt.origin, tt.allow, got)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+			t.Errorf("Origin %s: expected credentials '%s', got '%s'", tt.origin, tt.credentials, got)
+		}
+	}
+}
+
+func TestCORSWildcardWithCredentialsRejected(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected credentials with the wildcard origin to be rejected")
+	}
+}
+
+func TestCORSRouteSegmentMatch(t *testing.T) {
+	application := setupCORSApp(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		Routes: []config.CORSRouteConfig{
+			{PathPrefix: "/api/v1/items", AllowedOrigins: []string{"*"}},
+		},
+	})
+
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/api/v1/items", "*"},
+		{"/api/v1/items/1", "*"},
+		{"/api/v1/itemsfoo", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Origin", "https://other.example.net")
+		rec := httptest.NewRecorder()
+		application.Router().ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+			t.Errorf("Path %s: expected allow origin '%s', got '%s'", tt.path, tt.allow, got)
+		}
+	}
+}
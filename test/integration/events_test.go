@@ -0,0 +1,384 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/pkg/websocket"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readSSE returns the next event, skipping comments and retry hints
+func readSSE(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var e sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if e.event != "" {
+				return e
+			}
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func openSSE(t *testing.T, url string, lastEventID string) (*bufio.Reader, func()) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+	return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+}
+
+func postJSON(t *testing.T, url, body string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// startApp starts the application's background components, including the
+// outbox relay that feeds the event streams
+func startApp(t *testing.T, application *app.App) {
+	t.Helper()
+	if err := application.Lifecycle().Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { application.Lifecycle().Stop(context.Background()) })
+}
+
+func TestServerSentEvents(t *testing.T) {
+	application := setupTestApp()
+	startApp(t, application)
+	srv := httptest.NewServer(application.Router())
+	defer srv.Close()
+
+	stream, closeStream := openSSE(t, srv.URL+"/api/v1/events?resources=item", "")
+	defer closeStream()
+
+	postJSON(t, srv.URL+"/api/v1/users", `{"name": "Ignored", "email": "ignored@example.com"}`)
+	postJSON(t, srv.URL+"/api/v1/items", `{"name": "Streamed", "quantity": 1}`)
+
+	first := readSSE(t, stream)
+	if first.event != "item.created" {
+		t.Fatalf("Expected item.created, got %q", first.event)
+	}
+	var payload events.Event
+	if err := json.Unmarshal([]byte(first.data), &payload); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if payload.Resource != "item" || !strings.Contains(string(payload.Data), "Streamed") {
+		t.Errorf("Unexpected event payload: %s", first.data)
+	}
+
+	postJSON(t, srv.URL+"/api/v1/items", `{"name": "Missed", "quantity": 2}`)
+	second := readSSE(t, stream)
+	closeStream()
+
+	// Resuming replays everything after the last seen event
+	resumed, closeResumed := openSSE(t, srv.URL+"/api/v1/events?resources=item", first.id)
+	defer closeResumed()
+	if replayed := readSSE(t, resumed); replayed.id != second.id {
+		t.Errorf("Expected replay of event %s, got %s", second.id, replayed.id)
+	}
+}
+
+func TestServerSentEventsEndOnShutdown(t *testing.T) {
+	application := setupTestApp()
+	srv := httptest.NewServer(application.Router())
+	defer srv.Close()
+
+	if err := application.Lifecycle().Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	stream, closeStream := openSSE(t, srv.URL+"/api/v1/events", "")
+	defer closeStream()
+
+	if err := application.Lifecycle().Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if e := readSSE(t, stream); e.event != "error" {
+		t.Errorf("Expected error event on shutdown, got %q", e.event)
+	}
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Errorf("Expected stream to end cleanly, got %v", err)
+	}
+}
+
+// wsClient is a minimal client for exercising the server's WebSocket support
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, path string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocket.AcceptKey(key) {
+		t.Fatal("Unexpected Sec-WebSocket-Accept")
+	}
+	return &wsClient{conn: conn, br: br}
+}
+
+// send writes a masked text frame
+func (c *wsClient) send(t *testing.T, payload string) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | websocket.OpText, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}
+
+// read returns the next data frame, skipping pings
+func (c *wsClient) read(t *testing.T) (int, []byte) {
+	t.Helper()
+	for {
+		var header [2]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		op := int(header[0] & 0x0F)
+		length := int(header[1] & 0x7F)
+		if length == 126 {
+			var ext [2]byte
+			io.ReadFull(c.br, ext[:])
+			length = int(binary.BigEndian.Uint16(ext[:]))
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if op != websocket.OpPing {
+			return op, payload
+		}
+	}
+}
+
+func TestWebSocketEvents(t *testing.T) {
+	application := setupTestApp()
+	startApp(t, application)
+	srv := httptest.NewServer(application.Router())
+	defer srv.Close()
+
+	client := dialWS(t, srv, "/api/v1/events/ws?resources=item")
+	defer client.conn.Close()
+
+	postJSON(t, srv.URL+"/api/v1/items", `{"name": "Socket", "quantity": 1}`)
+
+	op, data := client.read(t)
+	var e events.Event
+	if op != websocket.OpText || json.Unmarshal(data, &e) != nil || e.Type != "item.created" {
+		t.Fatalf("Expected item.created text frame, got op %d: %s", op, data)
+	}
+
+	// Switch the subscription to users only
+	client.send(t, `{"action":"subscribe","resources":["user"]}`)
+	if _, data := client.read(t); !bytes.Contains(data, []byte(`"type":"subscribed"`)) {
+		t.Fatalf("Expected subscribed acknowledgement, got %s", data)
+	}
+
+	postJSON(t, srv.URL+"/api/v1/items", `{"name": "Filtered", "quantity": 1}`)
+	postJSON(t, srv.URL+"/api/v1/users", `{"name": "Wes", "email": "wes@example.com"}`)
+
+	_, data = client.read(t)
+	if json.Unmarshal(data, &e) != nil || e.Type != "user.created" {
+		t.Errorf("Expected user.created after resubscribing, got %s", data)
+	}
+}
+
+func TestWebSocketResubscribeReplaysFromConnect(t *testing.T) {
+	application := setupTestApp()
+	startApp(t, application)
+	srv := httptest.NewServer(application.Router())
+	defer srv.Close()
+
+	client := dialWS(t, srv, "/api/v1/events/ws?resources=user")
+	defer client.conn.Close()
+	probe := dialWS(t, srv, "/api/v1/events/ws")
+	defer probe.conn.Close()
+
+	// The item is published while the first client only watches users
+	postJSON(t, srv.URL+"/api/v1/items", `{"name": "Missed", "quantity": 1}`)
+	if _, data := probe.read(t); !bytes.Contains(data, []byte(`"type":"item.created"`)) {
+		t.Fatalf("Expected item.created on the probe, got %s", data)
+	}
+
+	client.send(t, `{"action":"subscribe","resources":["item"]}`)
+	if _, data := client.read(t); !bytes.Contains(data, []byte(`"type":"subscribed"`)) {
+		t.Fatalf("Expected subscribed acknowledgement, got %s", data)
+	}
+	var e events.Event
+	if _, data := client.read(t); json.Unmarshal(data, &e) != nil || e.Type != "item.created" {
+		t.Errorf("Expected item.created to be replayed after resubscribing, got %s", data)
+	}
+}
+
+func TestWebSocketClosedOnShutdown(t *testing.T) {
+	application := setupTestApp()
+	srv := httptest.NewServer(application.Router())
+	defer srv.Close()
+
+	if err := application.Lifecycle().Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	client := dialWS(t, srv, "/api/v1/events/ws")
+	defer client.conn.Close()
+
+	// Make sure the subscription is active before shutting down
+	postJSON(t, srv.URL+"/api/v1/items", `{"name": "Before shutdown", "quantity": 1}`)
+	client.read(t)
+
+	if err := application.Lifecycle().Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	op, data := client.read(t)
+	if op != websocket.OpClose || len(data) < 2 || int(binary.BigEndian.Uint16(data)) != websocket.CloseGoingAway {
+		t.Errorf("Expected going-away close frame, got op %d: %v", op, data)
+	}
+}
+
+func TestEventBusSlowConsumer(t *testing.T) {
+	bus := events.NewBus(10, 1)
+	sub, err := bus.Subscribe(events.Filter{}, 0)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	bus.Publish(events.NewEvent("item", 1, events.ActionCreated, nil))
+	bus.Publish(events.NewEvent("item", 1, events.ActionUpdated, nil))
+
+	<-sub.C
+	if _, ok := <-sub.C; ok {
+		t.Fatal("Expected slow subscriber to be disconnected")
+	}
+	if sub.Err() != events.ErrSlowConsumer {
+		t.Errorf("Expected ErrSlowConsumer, got %v", sub.Err())
+	}
+}
+
+func TestEventBusReplayGap(t *testing.T) {
+	bus := events.NewBus(2, 8)
+	var published []uint64
+	for i := int64(1); i <= 5; i++ {
+		published = append(published, bus.Publish(events.NewEvent("item", i, events.ActionCreated, nil)).ID)
+	}
+
+	sub, err := bus.Subscribe(events.Filter{}, published[0])
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+
+	if !sub.Gap {
+		t.Error("Expected a gap when resuming before the buffer")
+	}
+	var ids []uint64
+	for _, e := range sub.Replay {
+		ids = append(ids, e.ID)
+	}
+	if !slices.Equal(ids, published[3:]) {
+		t.Errorf("Expected replay of %v, got %v", published[3:], ids)
+	}
+
+	if sub, _ := bus.Subscribe(events.Filter{}, published[2]); sub.Gap {
+		t.Error("Expected no gap when resuming at the buffer edge")
+	}
+}
+
+func TestEventBusReplayAfterRestart(t *testing.T) {
+	before := events.NewBus(10, 8)
+	var lastID uint64
+	for i := int64(1); i <= 3; i++ {
+		lastID = before.Publish(events.NewEvent("item", i, events.ActionCreated, nil)).ID
+	}
+	before.Close()
+	time.Sleep(time.Millisecond)
+
+	// A client resuming from the previous process must learn it missed events
+	after := events.NewBus(10, 8)
+	after.Publish(events.NewEvent("item", 4, events.ActionCreated, nil))
+	sub, err := after.Subscribe(events.Filter{}, lastID)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+	if !sub.Gap {
+		t.Error("Expected a gap when resuming from before a restart")
+	}
+
+	// So must one whose ID the bus has not reached, as after a clock change
+	ahead, err := after.Subscribe(events.Filter{}, after.LastID()+100)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer ahead.Close()
+	if !ahead.Gap || len(ahead.Replay) != 0 {
+		t.Errorf("Expected a gap and no replay for an unknown ID, got gap %v and %d events", ahead.Gap, len(ahead.Replay))
+	}
+}
@@ -0,0 +1,130 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gostructure/app/internal/model"
+)
+
+type movementsResponse struct {
+	Movements []model.StockMovement `json:"movements"`
+	Total     int                   `json:"total"`
+	Balance   int                   `json:"balance"`
+}
+
+func TestStockAdjustments(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "quantity": 5}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+
+	rec = doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "receipt", "quantity": 10, "reason": "PO-1"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var movement model.StockMovement
+	json.NewDecoder(rec.Body).Decode(&movement)
+	if movement.Quantity != 10 || movement.Balance != 15 || movement.CreatedBy != "alice" {
+		t.Errorf("Unexpected movement %+v", movement)
+	}
+
+	doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "sale", "quantity": 4}`)
+	doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "correction", "quantity": -1, "reason": "Damaged"}`)
+
+	// Overselling is rejected without changing anything
+	rec = doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "sale", "quantity": 11}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d overselling, got %d", http.StatusConflict, rec.Code)
+	}
+
+	for _, body := range []string{
+		`{"type": "sale", "quantity": 0}`,
+		`{"type": "receipt", "quantity": -3}`,
+		`{"type": "correction", "quantity": 2}`,
+		`{"type": "theft", "quantity": 1}`,
+	} {
+		if rec := doAs(t, application, "alice", http.MethodPost, path+"/adjustments", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/999/adjustments", `{"type": "receipt", "quantity": 1}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing item, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	// Setting the quantity directly is recorded as a correction
+	doAs(t, application, "alice", http.MethodPut, path, `{"quantity": 12}`)
+	if rec := doAs(t, application, "alice", http.MethodPut, path, `{"quantity": -1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a negative quantity, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodGet, path+"/movements", "")
+	var history movementsResponse
+	json.NewDecoder(rec.Body).Decode(&history)
+	want := []struct {
+		typ               string
+		quantity, balance int
+	}{
+		{model.MovementReceipt, 5, 5},
+		{model.MovementReceipt, 10, 15},
+		{model.MovementSale, -4, 11},
+		{model.MovementCorrection, -1, 10},
+		{model.MovementCorrection, 2, 12},
+	}
+	if history.Total != len(want) || history.Balance != 12 {
+		t.Fatalf("Unexpected history %+v", history)
+	}
+	for i, w := range want {
+		if m := history.Movements[i]; m.Type != w.typ || m.Quantity != w.quantity || m.Balance != w.balance {
+			t.Errorf("Movement %d: expected %s %d -> %d, got %+v", i, w.typ, w.quantity, w.balance, m)
+		}
+	}
+
+	doAs(t, application, "alice", http.MethodDelete, path, "")
+	if rec := doAs(t, application, "alice", http.MethodGet, path+"/movements", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a deleted item, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "receipt", "quantity": 1}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d adjusting a deleted item, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestConcurrentSalesNeverOversell(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Limited Edition", "quantity": 10}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold := 0
+	for range 25 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "sale", "quantity": 1}`)
+			if rec.Code == http.StatusCreated {
+				mu.Lock()
+				sold++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sold != 10 {
+		t.Errorf("Expected exactly 10 sales, got %d", sold)
+	}
+	rec = doAs(t, application, "alice", http.MethodGet, path, "")
+	json.NewDecoder(rec.Body).Decode(&item)
+	if item.Quantity != 0 {
+		t.Errorf("Expected quantity 0, got %d", item.Quantity)
+	}
+}
@@ -0,0 +1,276 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/store"
+)
+
+var testJobsConfig = config.JobsConfig{
+	InitialBackoff: 10 * time.Millisecond,
+	PollInterval:   10 * time.Millisecond,
+}
+
+// runManager runs m until the test ends
+func runManager(t *testing.T, m *jobs.Manager) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitForJob(t *testing.T, m *jobs.Manager, id int64) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	waitFor(t, "job to finish", func() bool {
+		job, _ = m.Get(id)
+		return job.Done()
+	})
+	return job
+}
+
+func TestItemImportAndExportJobs(t *testing.T) {
+	application := setupAuditApp()
+	startApp(t, application)
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/import",
+		`{"items": [{"name": "Imported A", "quantity": 1}, {"name": "Imported B", "quantity": 2}]}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	location := rec.Header().Get("Location")
+
+	var job jobs.Job
+	waitFor(t, "import job", func() bool {
+		rec := doAs(t, application, "alice", http.MethodGet, location, "")
+		json.NewDecoder(rec.Body).Decode(&job)
+		return job.Done()
+	})
+	if job.Status != jobs.StatusSucceeded || job.Owner != "alice" {
+		t.Fatalf("Expected succeeded job owned by alice, got %+v", job)
+	}
+	var imported struct {
+		Created int `json:"created"`
+	}
+	json.Unmarshal(job.Result, &imported)
+	if imported.Created != 2 {
+		t.Errorf("Expected 2 imported items, got %d", imported.Created)
+	}
+
+	// Jobs are private to their owner and admins
+	if rec := doAs(t, application, "mallory", http.MethodGet, location, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another principal, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "auditor", http.MethodGet, location, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for an admin, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Anonymous callers share a subject, so they cannot read their jobs
+	rec = doAs(t, application, "", http.MethodPost, "/api/v1/items/export", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d for an anonymous export, got %d", http.StatusAccepted, rec.Code)
+	}
+	anonymous := rec.Header().Get("Location")
+	if rec := doAs(t, application, "", http.MethodGet, anonymous, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an anonymous job read, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "auditor", http.MethodGet, anonymous, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for an admin reading an anonymous job, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodPost, "/api/v1/items/export", "")
+	location = rec.Header().Get("Location")
+	waitFor(t, "export job", func() bool {
+		rec := doAs(t, application, "alice", http.MethodGet, location, "")
+		json.NewDecoder(rec.Body).Decode(&job)
+		return job.Done()
+	})
+	var exported struct {
+		Total int `json:"total"`
+	}
+	json.Unmarshal(job.Result, &exported)
+	if exported.Total != 2 {
+		t.Errorf("Expected export of 2 items, got %d", exported.Total)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/import", `{"items": [{"quantity": 1}]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid import, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestJobRetriesAndFailures(t *testing.T) {
+	m := jobs.NewManager(testJobsConfig, store.New())
+
+	var calls atomic.Int32
+	m.Register("flaky", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		if calls.Add(1) < 3 {
+			return nil, errors.New("temporary")
+		}
+		return "ok", nil
+	})
+	m.Register("broken", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		return nil, jobs.Permanent(errors.New("bad payload"))
+	})
+	m.Register("slow", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, jobs.WithTimeout(20*time.Millisecond), jobs.WithMaxAttempts(1))
+	runManager(t, m)
+
+	flaky, _ := m.Enqueue("flaky", nil, "test")
+	broken, _ := m.Enqueue("broken", nil, "test")
+	slow, _ := m.Enqueue("slow", nil, "test")
+
+	if job := waitForJob(t, m, flaky.ID); job.Status != jobs.StatusSucceeded || job.Attempts != 3 || string(job.Result) != `"ok"` {
+		t.Errorf("Expected success on the third attempt, got %+v", job)
+	}
+	if job := waitForJob(t, m, broken.ID); job.Status != jobs.StatusFailed || job.Attempts != 1 {
+		t.Errorf("Expected permanent failure without retries, got %+v", job)
+	}
+	if job := waitForJob(t, m, slow.ID); job.Status != jobs.StatusFailed || job.Error == "" {
+		t.Errorf("Expected timed out job to fail, got %+v", job)
+	}
+
+	if _, err := m.Enqueue("missing", nil, "test"); !errors.Is(err, jobs.ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType, got %v", err)
+	}
+}
+
+func TestJobQueueConcurrency(t *testing.T) {
+	cfg := testJobsConfig
+	cfg.Queues = map[string]int{"serial": 1}
+	m := jobs.NewManager(cfg, store.New())
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	m.Register("serial", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	}, jobs.WithQueue("serial"))
+	runManager(t, m)
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		job, _ := m.Enqueue("serial", nil, "test")
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		waitForJob(t, m, id)
+	}
+
+	if peak != 1 {
+		t.Errorf("Expected at most 1 concurrent job on the serial queue, got %d", peak)
+	}
+}
+
+func TestJobsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.journal")
+
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	m := jobs.NewManager(testJobsConfig, s)
+	m.Register("report", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		return nil, nil
+	})
+	queued, err := m.Enqueue("report", map[string]string{"format": "csv"}, "test")
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	s.Close()
+
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	restarted := jobs.NewManager(testJobsConfig, reopened)
+	var payload map[string]string
+	restarted.Register("report", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		return nil, job.Decode(&payload)
+	})
+	runManager(t, restarted)
+
+	if job := waitForJob(t, restarted, queued.ID); job.Status != jobs.StatusSucceeded {
+		t.Errorf("Expected persisted job to run after restart, got %+v", job)
+	}
+	if payload["format"] != "csv" {
+		t.Errorf("Expected payload to survive restart, got %v", payload)
+	}
+}
+
+func TestJobCompletedWithItsWorkIsNotRerun(t *testing.T) {
+	s := store.New()
+	m := jobs.NewManager(testJobsConfig, s)
+	var runs atomic.Int32
+	m.Register("import", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		runs.Add(1)
+		err := s.Update(func(tx *store.Tx) error {
+			return jobs.Complete(tx, job, "imported")
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Stop before the manager records the result
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, jobs.WithMaxAttempts(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	queued, err := m.Enqueue("import", nil, "test")
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	waitForJob(t, m, queued.ID)
+	cancel()
+	<-done
+
+	restarted := jobs.NewManager(testJobsConfig, s)
+	restarted.Register("import", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		runs.Add(1)
+		return nil, nil
+	})
+	runManager(t, restarted)
+	time.Sleep(5 * testJobsConfig.PollInterval)
+
+	job, _ := restarted.Get(queued.ID)
+	if job.Status != jobs.StatusSucceeded || string(job.Result) != `"imported"` {
+		t.Errorf("Expected the completed job to keep its result, got %+v", job)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("Expected the job to run once, ran %d times", n)
+	}
+}
@@ -0,0 +1,133 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/middleware"
+)
+
+func TestLifecycleOrdering(t *testing.T) {
+	lc := app.NewLifecycle(config.ShutdownConfig{}, nil)
+
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	lc.Append(app.Hook{Name: "server", Order: 100, OnStart: record("start server"), OnStop: record("stop server")})
+	lc.Append(app.Hook{Name: "admin", Order: 0, OnStart: record("start admin"), OnStop: record("stop admin")})
+	lc.Append(app.Hook{Name: "worker", Order: 50, OnStart: record("start worker"), OnStop: record("stop worker")})
+
+	if err := lc.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := lc.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	expected := []string{
+		"start admin", "start worker", "start server",
+		"stop server", "stop worker", "stop admin",
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
+
+func TestLifecycleStartFailureStopsStartedHooks(t *testing.T) {
+	lc := app.NewLifecycle(config.ShutdownConfig{}, nil)
+
+	stopped := false
+	lc.Append(app.Hook{Name: "first", OnStop: func(context.Context) error {
+		stopped = true
+		return nil
+	}})
+	lc.Append(app.Hook{Name: "second", Order: 1, OnStart: func(context.Context) error {
+		return errors.New("bind failed")
+	}})
+
+	if err := lc.Start(context.Background()); err == nil {
+		t.Fatal("Expected start to fail")
+	}
+	if !stopped {
+		t.Error("Expected already started hook to be stopped")
+	}
+}
+
+func TestReadinessDuringPreStopDelay(t *testing.T) {
+	cfg := &config.Config{
+		App:      config.AppConfig{Name: "Test App", Environment: "test"},
+		Shutdown: config.ShutdownConfig{PreStopDelay: 200 * time.Millisecond},
+	}
+	application := app.New(cfg)
+
+	ready := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		rec := httptest.NewRecorder()
+		application.Router().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("Expected ready before shutdown, got %d", code)
+	}
+
+	done := make(chan error)
+	go func() { done <- application.Lifecycle().Stop(context.Background()) }()
+
+	time.Sleep(50 * time.Millisecond)
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected %d while draining, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestInFlightTracker(t *testing.T) {
+	tracker := middleware.NewInFlightTracker()
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	handler := middleware.RequestID(tracker.Track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})))
+
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/items", nil)
+		req.Header.Set("X-Request-ID", "slow-request")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-entered
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].RequestID != "slow-request" || snapshot[0].Path != "/api/v1/items" {
+		t.Errorf("Unexpected in-flight snapshot: %+v", snapshot)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for tracker.Count() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if tracker.Count() != 0 {
+		t.Error("Expected request to be removed after completion")
+	}
+}
@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/server"
+)
+
+func TestListenersFromEnv(t *testing.T) {
+	t.Setenv("SERVER_LISTENERS", "tcp://127.0.0.1:0,unix:///tmp/app.sock?mode=0600&h2c=true")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if len(cfg.Server.Listeners) != 2 {
+		t.Fatalf("Expected 2 listeners, got %d", len(cfg.Server.Listeners))
+	}
+	unix := cfg.Server.Listeners[1]
+	if unix.Network != "unix" || unix.Address != "/tmp/app.sock" || unix.SocketMode != 0o600 || !unix.H2C {
+		t.Errorf("Unexpected unix listener config: %+v", unix)
+	}
+
+	t.Setenv("SERVER_LISTENERS", "udp://:53")
+	if _, err := config.Load(); err == nil {
+		t.Error("Expected unsupported network to be rejected")
+	}
+}
+
+func TestUnixSocketH2C(t *testing.T) {
+	// Socket paths are length-limited, so avoid deep temp directories
+	dir, err := os.MkdirTemp("", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "app.sock")
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Listeners: []config.ListenerConfig{
+				{Network: "unix", Address: socket, SocketMode: 0o600, H2C: true},
+				{Network: "tcp", Address: "127.0.0.1:0"},
+			},
+		},
+	}
+	srv, err := server.New(cfg, setupTestApp().Router())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("Socket not created: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected socket mode 0600, got %o", info.Mode().Perm())
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{
+		Protocols: protocols,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	resp, err := client.Get("http://unix/health")
+	if err != nil {
+		t.Fatalf("Request over unix socket failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Expected HTTP/2 over h2c, got %s", resp.Proto)
+	}
+
+	tcpAddr := srv.Addrs()[1].String()
+	resp, err = http.Get("http://" + tcpAddr + "/health")
+	if err != nil {
+		t.Fatalf("Request over tcp failed: %v", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Error("Expected socket file to be removed on shutdown")
+	}
+	if _, err := http.Get("http://" + tcpAddr + "/health"); err == nil {
+		t.Error("Expected tcp listener to be closed after shutdown")
+	}
+}
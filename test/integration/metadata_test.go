@@ -0,0 +1,94 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/model"
+)
+
+func TestChangeMetadata(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget"}`)
+	var created model.Item
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.CreatedAt.IsZero() || created.CreatedBy != "alice" || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("Expected creation metadata, got %+v", created.Meta)
+	}
+	var raw map[string]interface{}
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/items/"+strconv.FormatInt(created.ID, 10), "")
+	json.NewDecoder(rec.Body).Decode(&raw)
+	if _, err := time.Parse(time.RFC3339, raw["created_at"].(string)); err != nil {
+		t.Errorf("Expected RFC 3339 created_at, got %v", raw["created_at"])
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	rec = doAs(t, application, "bob", http.MethodPut, "/api/v1/items/"+strconv.FormatInt(created.ID, 10), `{"quantity": 2}`)
+	var updated model.Item
+	json.NewDecoder(rec.Body).Decode(&updated)
+	if !updated.CreatedAt.Equal(created.CreatedAt) || updated.CreatedBy != "alice" {
+		t.Errorf("Expected creation metadata to be kept, got %+v", updated.Meta)
+	}
+	if !updated.UpdatedAt.After(created.UpdatedAt) || updated.UpdatedBy != "bob" {
+		t.Errorf("Expected update metadata, got %+v", updated.Meta)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com"}`)
+	var user model.User
+	json.NewDecoder(rec.Body).Decode(&user)
+	path := "/api/v1/users/" + strconv.FormatInt(user.ID, 10)
+
+	rec = doAs(t, application, "alice", http.MethodGet, path, "")
+	lastModified := rec.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("Expected Last-Modified header")
+	}
+
+	get := func(since string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("If-Modified-Since", since)
+		rec := httptest.NewRecorder()
+		application.Router().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := get(lastModified); code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, code)
+	}
+	earlier := user.UpdatedAt.Add(-time.Hour).Format(http.TimeFormat)
+	if code := get(earlier); code != http.StatusOK {
+		t.Errorf("Expected status %d for a stale copy, got %d", http.StatusOK, code)
+	}
+}
+
+func TestListUpdatedSince(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Old"}`)
+	time.Sleep(5 * time.Millisecond)
+	checkpoint := time.Now().UTC()
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "New"}`)
+
+	rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items?updated_since="+url.QueryEscape(checkpoint.Format(time.RFC3339Nano)), "")
+	var list struct {
+		Items []model.Item `json:"items"`
+		Total int          `json:"total"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 || list.Items[0].Name != "New" {
+		t.Errorf("Expected only the new item, got %+v", list.Items)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items?updated_since=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/pkg/money"
+)
+
+func TestMoneyParseAndFormat(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		minor            int64
+		formatted        string
+	}{
+		{"12.5", "USD", 1250, "12.50"},
+		{"-0.05", "EUR", -5, "-0.05"},
+		{"1000", "JPY", 1000, "1000"},
+		{"1.234", "KWD", 1234, "1.234"},
+		{"3.10", "USD", 310, "3.10"},
+		{"7.000", "USD", 700, "7.00"},
+	}
+	for _, tt := range tests {
+		m, err := money.Parse(tt.amount, tt.currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %s) failed: %v", tt.amount, tt.currency, err)
+			continue
+		}
+		if m.Minor() != tt.minor || m.Amount() != tt.formatted {
+			t.Errorf("Parse(%q, %s) = %d (%s), want %d (%s)", tt.amount, tt.currency, m.Minor(), m.Amount(), tt.minor, tt.formatted)
+		}
+	}
+
+	if _, err := money.Parse("1.001", "USD"); !errors.Is(err, money.ErrScale) {
+		t.Errorf("Expected ErrScale, got %v", err)
+	}
+	if _, err := money.Parse("1.5", "JPY"); !errors.Is(err, money.ErrScale) {
+		t.Errorf("Expected ErrScale for a currency without minor units, got %v", err)
+	}
+	if _, err := money.Parse("1", "XYZ"); !errors.Is(err, money.ErrCurrency) {
+		t.Errorf("Expected ErrCurrency, got %v", err)
+	}
+	for _, amount := range []string{"", "1.", ".5", "1e3", "1,5", "--1"} {
+		if _, err := money.Parse(amount, "USD"); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", amount)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := money.MustParse("0.10", "USD")
+	b := money.MustParse("0.20", "USD")
+	if sum, _ := a.Add(b); sum.Amount() != "0.30" {
+		t.Errorf("Expected 0.10 + 0.20 = 0.30, got %s", sum)
+	}
+	if _, err := a.Add(money.MustParse("1", "EUR")); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+
+	total, _ := money.Sum(a, b, money.MustParse("9.99", "USD"))
+	if total.String() != "10.29 USD" {
+		t.Errorf("Unexpected total %s", total)
+	}
+	if line, _ := money.MustParse("19.99", "USD").Mul(3); line.Amount() != "59.97" {
+		t.Errorf("Unexpected line total %s", line)
+	}
+
+	// 15% off 9.99 is 1.4985, rounded to 1.50
+	if discounted, _ := money.MustParse("9.99", "USD").Discount(1500); discounted.Amount() != "8.49" {
+		t.Errorf("Expected 8.49 after discount, got %s", discounted)
+	}
+	if pct, _ := money.MustParse("-0.05", "USD").Percent(5000); pct.Amount() != "-0.03" {
+		t.Errorf("Expected negative halves to round away from zero, got %s", pct)
+	}
+
+	max := money.MustParse("92233720368547758.07", "USD")
+	if _, err := max.Add(money.MustParse("0.01", "USD")); !errors.Is(err, money.ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+	if _, err := max.Mul(2); !errors.Is(err, money.ErrOverflow) {
+		t.Errorf("Expected ErrOverflow, got %v", err)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, _ := json.Marshal(money.MustParse("12.5", "EUR"))
+	if string(data) != `{"amount":"12.50","currency":"EUR"}` {
+		t.Errorf("Unexpected encoding %s", data)
+	}
+
+	var m money.Money
+	if err := json.Unmarshal([]byte(`{"amount": "0.30", "currency": "usd"}`), &m); err != nil || m.String() != "0.30 USD" {
+		t.Errorf("Unexpected decoding %v (%v)", m, err)
+	}
+	// Legacy plain numbers are read exactly in the default currency
+	if err := json.Unmarshal([]byte(`0.3`), &m); err != nil || m.Minor() != 30 || m.Currency() != money.DefaultCurrency {
+		t.Errorf("Unexpected legacy decoding %v (%v)", m, err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount": "1", "currency": "ABC"}`), &m); !errors.Is(err, money.ErrCurrency) {
+		t.Errorf("Expected ErrCurrency, got %v", err)
+	}
+	if data, _ := json.Marshal(money.Money{}); string(data) != "null" {
+		t.Errorf("Expected zero value to encode as null, got %s", data)
+	}
+}
+
+func TestItemPrices(t *testing.T) {
+	application := setupAuditApp()
+	startApp(t, application)
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "price": {"amount": "19.99", "currency": "EUR"}, "quantity": 3}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	if item.Price.String() != "19.99 EUR" {
+		t.Errorf("Unexpected price %s", item.Price)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Free sample"}`)
+	json.NewDecoder(rec.Body).Decode(&item)
+	if !item.Price.IsZero() || item.Price.Currency() != money.DefaultCurrency {
+		t.Errorf("Expected zero price in the default currency, got %s", item.Price)
+	}
+
+	for _, body := range []string{
+		`{"name": "Bad", "price": {"amount": "1.999", "currency": "EUR"}}`,
+		`{"name": "Bad", "price": {"amount": "1", "currency": "EURO"}}`,
+		`{"name": "Bad", "price": {"amount": "-1", "currency": "EUR"}}`,
+	} {
+		if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Gadget", "price": {"amount": "0.01", "currency": "EUR"}, "quantity": 3}`)
+	rec = doAs(t, application, "alice", http.MethodPost, "/api/v1/items/export", "")
+	location := rec.Header().Get("Location")
+	var job jobs.Job
+	waitFor(t, "export job", func() bool {
+		rec := doAs(t, application, "alice", http.MethodGet, location, "")
+		json.NewDecoder(rec.Body).Decode(&job)
+		return job.Done()
+	})
+	var exported struct {
+		Value map[string]money.Money `json:"value"`
+	}
+	json.Unmarshal(job.Result, &exported)
+	if exported.Value["EUR"].String() != "60.00 EUR" {
+		t.Errorf("Expected stock value of 60.00 EUR, got %v", exported.Value)
+	}
+}
@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/model"
+)
+
+func createOrder(t *testing.T, application *app.App, body string) model.Order {
+	t.Helper()
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/orders", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var order model.Order
+	json.NewDecoder(rec.Body).Decode(&order)
+	return order
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "price": {"amount": "2.50", "currency": "EUR"}, "quantity": 10}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Gadget", "price": {"amount": "10", "currency": "EUR"}, "quantity": 1}`)
+
+	order := createOrder(t, application, `{"user_id": 1, "lines": [{"item_id": 1, "quantity": 4}, {"item_id": 2, "quantity": 1}]}`)
+	if order.Status != model.OrderPending || order.Total.String() != "20.00 EUR" {
+		t.Errorf("Unexpected order %+v", order)
+	}
+	if line := order.Lines[0]; line.Name != "Widget" || line.UnitPrice.Amount() != "2.50" || line.Total.Amount() != "10.00" {
+		t.Errorf("Unexpected line %+v", line)
+	}
+	path := "/api/v1/orders/" + strconv.FormatInt(order.ID, 10)
+
+	// Lines keep the price they were ordered at
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/1", `{"price": {"amount": "3", "currency": "EUR"}}`)
+	rec := doAs(t, application, "alice", http.MethodGet, path, "")
+	json.NewDecoder(rec.Body).Decode(&order)
+	if order.Total.Amount() != "20.00" {
+		t.Errorf("Expected captured prices to be kept, got %s", order.Total)
+	}
+
+	// Stock is untouched until the order is paid
+	if stock := getStock(t, application, "/api/v1/items/1"); stock.Quantity != 10 {
+		t.Errorf("Expected pending order to leave stock alone, got %d", stock.Quantity)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":ship", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d shipping a pending order, got %d", http.StatusConflict, rec.Code)
+	}
+	rec = doAs(t, application, "alice", http.MethodPost, path+":pay", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if stock := getStock(t, application, "/api/v1/items/1"); stock.Quantity != 6 {
+		t.Errorf("Expected paid order to take stock, got %d", stock.Quantity)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPut, path, `{"lines": [{"item_id": 1, "quantity": 1}]}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d changing a paid order, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodDelete, path, ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d deleting a paid order, got %d", http.StatusConflict, rec.Code)
+	}
+
+	// Cancelling a paid order returns its stock
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":cancel", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if stock := getStock(t, application, "/api/v1/items/2"); stock.Quantity != 1 {
+		t.Errorf("Expected cancelled order to return stock, got %d", stock.Quantity)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":pay", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d paying a cancelled order, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodDelete, path, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d deleting a cancelled order, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Shipped orders can be cancelled, but their stock has left
+	order = createOrder(t, application, `{"user_id": 1, "lines": [{"item_id": 2, "quantity": 1}]}`)
+	path = "/api/v1/orders/" + strconv.FormatInt(order.ID, 10)
+	doAs(t, application, "alice", http.MethodPost, path+":pay", "")
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":ship", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d shipping, got %d", http.StatusOK, rec.Code)
+	}
+
+	var list struct {
+		Orders []model.Order `json:"orders"`
+		Total  int           `json:"total"`
+	}
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/orders?user_id=1&status=shipped", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 || list.Orders[0].ID != order.ID {
+		t.Errorf("Expected the shipped order, got %+v", list.Orders)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":cancel", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d cancelling a shipped order, got %d", http.StatusOK, rec.Code)
+	}
+	if stock := getStock(t, application, "/api/v1/items/2"); stock.Quantity != 0 {
+		t.Errorf("Expected a cancelled shipped order to keep its stock taken, got %d", stock.Quantity)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":ship", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d shipping a cancelled order, got %d", http.StatusConflict, rec.Code)
+	}
+}
+
+func TestOrderInvariants(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "price": {"amount": "1", "currency": "EUR"}, "quantity": 2}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Import", "price": {"amount": "1", "currency": "USD"}, "quantity": 2}`)
+
+	for _, body := range []string{
+		`{"user_id": 9, "lines": [{"item_id": 1, "quantity": 1}]}`,
+		`{"user_id": 1, "lines": []}`,
+		`{"user_id": 1, "lines": [{"item_id": 1, "quantity": 0}]}`,
+		`{"user_id": 1, "lines": [{"item_id": 9, "quantity": 1}]}`,
+		`{"user_id": 1, "lines": [{"item_id": 1, "quantity": 1}, {"item_id": 1, "quantity": 1}]}`,
+		`{"user_id": 1, "lines": [{"item_id": 1, "quantity": 1}, {"item_id": 2, "quantity": 1}]}`,
+	} {
+		if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/orders", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+
+	// Paying fails atomically when any line lacks stock
+	order := createOrder(t, application, `{"user_id": 1, "lines": [{"item_id": 1, "quantity": 3}]}`)
+	path := "/api/v1/orders/" + strconv.FormatInt(order.ID, 10)
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":pay", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d paying without stock, got %d", http.StatusConflict, rec.Code)
+	}
+	rec := doAs(t, application, "alice", http.MethodGet, path, "")
+	json.NewDecoder(rec.Body).Decode(&order)
+	if order.Status != model.OrderPending {
+		t.Errorf("Expected order to stay pending, got %s", order.Status)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPut, path, `{"lines": [{"item_id": 1, "quantity": 2}]}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d changing a pending order, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":pay", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d paying, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":refund", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown action, got %d", http.StatusNotFound, rec.Code)
+	}
+}
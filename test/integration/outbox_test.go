@@ -0,0 +1,185 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/outbox"
+	"github.com/gostructure/app/internal/store"
+)
+
+// flakySink fails a number of times before accepting events
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	received []events.Event
+}
+
+func (s *flakySink) Name() string { return "flaky" }
+
+func (s *flakySink) Publish(_ context.Context, e events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.received = append(s.received, e)
+	return nil
+}
+
+type recordingSink struct {
+	name     string
+	received []events.Event
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(_ context.Context, e events.Event) error {
+	s.received = append(s.received, e)
+	return nil
+}
+
+func TestStoreRollsBackOutboxWithState(t *testing.T) {
+	s := store.New()
+
+	err := s.Update(func(tx *store.Tx) error {
+		user, err := store.Users.Insert(tx, func(id int64) model.User {
+			return model.User{ID: id, Name: "Rolled back"}
+		})
+		if err != nil {
+			return err
+		}
+		tx.Emit("user", user.ID, events.ActionCreated, user)
+		return errors.New("validation failed")
+	})
+	if err == nil {
+		t.Fatal("Expected the transaction to fail")
+	}
+
+	s.View(func(tx *store.Tx) error {
+		if n := store.Users.Count(tx); n != 0 {
+			t.Errorf("Expected no users after rollback, got %d", n)
+		}
+		return nil
+	})
+	if pending := s.Pending(0); len(pending) != 0 {
+		t.Errorf("Expected no outbox records after rollback, got %d", len(pending))
+	}
+
+	// IDs are not consumed by failed transactions
+	s.Update(func(tx *store.Tx) error {
+		user, _ := store.Users.Insert(tx, func(id int64) model.User { return model.User{ID: id} })
+		if user.ID != 1 {
+			t.Errorf("Expected ID 1 after rollback, got %d", user.ID)
+		}
+		return nil
+	})
+}
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "store.journal")
+	cfg := &config.Config{App: config.AppConfig{Name: "Test App", Environment: "test"}}
+
+	s, err := store.OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	// Committed without the relay running, as if the process crashed
+	// before publishing
+	application := app.New(cfg, app.WithStore(s))
+	if rec := doAs(t, application, "", http.MethodPost, "/api/v1/items", `{"name": "Durable", "quantity": 1}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	s.Close()
+
+	reopened, err := store.OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	pending := reopened.Pending(0)
+	if len(pending) != 1 || pending[0].Event.Type != "item.created" || pending[0].Event.DedupID == "" {
+		t.Fatalf("Expected the committed event to be pending, got %+v", pending)
+	}
+	reopened.View(func(tx *store.Tx) error {
+		if item, ok := store.Items.Get(tx, 1); !ok || item.Name != "Durable" {
+			t.Errorf("Expected item to be restored, got %+v", item)
+		}
+		return nil
+	})
+
+	eventsPath := filepath.Join(dir, "events.jsonl")
+	fileSink, err := outbox.OpenFileSink(eventsPath)
+	if err != nil {
+		t.Fatalf("Failed to open file sink: %v", err)
+	}
+	defer fileSink.Close()
+	flaky := &flakySink{failures: 2}
+
+	relay := outbox.NewRelay(config.OutboxConfig{}, reopened, fileSink, flaky)
+	for i := 0; i < 3; i++ {
+		relay.Flush(context.Background())
+	}
+
+	data, err := os.ReadFile(eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), pending[0].Event.DedupID); n != 1 {
+		t.Errorf("Expected the file sink to receive the event once, got %d", n)
+	}
+	if len(flaky.received) != 1 || flaky.received[0].DedupID != pending[0].Event.DedupID {
+		t.Errorf("Expected the flaky sink to receive the event after retries, got %+v", flaky.received)
+	}
+	if left := reopened.Pending(0); len(left) != 0 {
+		t.Errorf("Expected the outbox to be drained, got %d records", len(left))
+	}
+}
+
+func TestOutboxAcknowledgementsPersist(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "store.journal")
+
+	s, err := store.OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	s.Update(func(tx *store.Tx) error {
+		tx.Emit("user", 1, events.ActionDeleted, nil)
+		return nil
+	})
+
+	// One sink succeeds, the other is down when the process stops
+	delivered := &recordingSink{name: "delivered"}
+	outbox.NewRelay(config.OutboxConfig{}, s, delivered, &flakySink{failures: 1}).Flush(context.Background())
+	s.Close()
+
+	reopened, err := store.OpenFile(storePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	again := &recordingSink{name: "delivered"}
+	recovered := &flakySink{}
+	outbox.NewRelay(config.OutboxConfig{}, reopened, again, recovered).Flush(context.Background())
+
+	if len(delivered.received) != 1 || len(again.received) != 0 {
+		t.Errorf("Expected acknowledged sink not to be republished, got %d and %d", len(delivered.received), len(again.received))
+	}
+	if len(recovered.received) != 1 {
+		t.Errorf("Expected pending sink to receive the event after restart, got %d", len(recovered.received))
+	}
+}
@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/model"
+)
+
+func listOwnedItems(t *testing.T, application *app.App, subject, path string) []model.Item {
+	t.Helper()
+	rec := doAs(t, application, subject, http.MethodGet, path, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var list struct {
+		Items []model.Item `json:"items"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	return list.Items
+}
+
+func TestItemOwnership(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com", "subject": "alice"}`)
+	doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Bob", "email": "bob@example.com", "subject": "bob"}`)
+	if rec := doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Alias", "email": "alias@example.com", "subject": "alice"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a taken subject, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Nobody", "email": "nobody@example.com", "subject": "anonymous"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a reserved subject, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Kettle", "quantity": 5}`)
+	var kettle model.Item
+	json.NewDecoder(rec.Body).Decode(&kettle)
+	if kettle.OwnerID != 1 {
+		t.Errorf("Expected alice to own the kettle, got %+v", kettle)
+	}
+	doAs(t, application, "bob", http.MethodPost, "/api/v1/items", `{"name": "Mug"}`)
+	doAs(t, application, "dave", http.MethodPost, "/api/v1/items", `{"name": "Spoon"}`)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/prices", `{"price": {"amount": "20.00", "currency": "EUR"}, "effective_at": "`+future+`"}`)
+	if rec := upload(t, application, "/api/v1/items/1/attachments", "kettle.png", testPNG(t, 10, 10)); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d uploading, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if rec := uploadAs(t, application, "bob", "/api/v1/items/1/attachments", "mine.png", testPNG(t, 20, 20)); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for bob uploading to alice's item, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	for _, tc := range []struct {
+		subject, method, path, body string
+		status                      int
+	}{
+		{"bob", http.MethodPut, "/api/v1/items/1", `{"name": "Bob's Kettle"}`, http.StatusForbidden},
+		{"bob", http.MethodDelete, "/api/v1/items/1", "", http.StatusForbidden},
+		{"bob", http.MethodPut, "/api/v1/items/1/tags", `{"tags": ["mine"]}`, http.StatusForbidden},
+		{"bob", http.MethodPost, "/api/v1/items/1/adjustments", `{"type": "sale", "quantity": 1}`, http.StatusForbidden},
+		{"bob", http.MethodPost, "/api/v1/items/1/prices", `{"price": {"amount": "1.00", "currency": "EUR"}, "effective_at": "` + future + `"}`, http.StatusForbidden},
+		{"bob", http.MethodDelete, "/api/v1/items/1/prices/4", "", http.StatusForbidden},
+		{"bob", http.MethodDelete, "/api/v1/items/1/attachments/1", "", http.StatusForbidden},
+		{"bob", http.MethodPost, "/api/v1/items/3/adjustments", `{"type": "receipt", "quantity": 1}`, http.StatusCreated},
+		{"alice", http.MethodPost, "/api/v1/items/1/adjustments", `{"type": "sale", "quantity": 1}`, http.StatusCreated},
+		{"bob", http.MethodPut, "/api/v1/items/3", `{"description": "Unowned"}`, http.StatusOK},
+		{"alice", http.MethodPut, "/api/v1/items/1", `{"description": "Whistling"}`, http.StatusOK},
+		{"auditor", http.MethodPut, "/api/v1/items/1", `{"description": "Checked"}`, http.StatusOK},
+		{"alice", http.MethodDelete, "/api/v1/items/1/prices/4", "", http.StatusOK},
+		{"alice", http.MethodDelete, "/api/v1/items/1/attachments/1", "", http.StatusOK},
+	} {
+		if rec := doAs(t, application, tc.subject, tc.method, tc.path, tc.body); rec.Code != tc.status {
+			t.Errorf("Expected status %d for %s %s %s, got %d: %s", tc.status, tc.subject, tc.method, tc.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	if items := listOwnedItems(t, application, "bob", "/api/v1/users/1/items"); len(items) != 1 || items[0].Name != "Kettle" {
+		t.Errorf("Expected alice's kettle, got %+v", items)
+	}
+	if items := listOwnedItems(t, application, "bob", "/api/v1/items?owner=me"); len(items) != 1 || items[0].Name != "Mug" {
+		t.Errorf("Expected bob's mug, got %+v", items)
+	}
+	if items := listOwnedItems(t, application, "dave", "/api/v1/items?owner=me"); len(items) != 0 {
+		t.Errorf("Expected nothing for a principal without a user, got %+v", items)
+	}
+	if rec := doAs(t, application, "bob", http.MethodGet, "/api/v1/users/99/items", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown user, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "bob", http.MethodGet, "/api/v1/items?owner=someone", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid owner, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestDeleteUserWithItems(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com", "subject": "alice"}`)
+	doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Bob", "email": "bob@example.com", "subject": "bob"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Kettle"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Teapot"}`)
+	doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/2", "")
+
+	for _, tc := range []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusConflict},
+		{"?items=block", http.StatusConflict},
+		{"?items=shred", http.StatusBadRequest},
+		{"?items=reassign", http.StatusBadRequest},
+		{"?items=reassign&reassign_to=1", http.StatusBadRequest},
+		{"?items=reassign&reassign_to=99", http.StatusBadRequest},
+	} {
+		if rec := doAs(t, application, "auditor", http.MethodDelete, "/api/v1/users/1"+tc.query, ""); rec.Code != tc.status {
+			t.Errorf("Expected status %d deleting with %q, got %d", tc.status, tc.query, rec.Code)
+		}
+	}
+
+	if rec := doAs(t, application, "auditor", http.MethodDelete, "/api/v1/users/1?items=reassign&reassign_to=2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d reassigning, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if items := listOwnedItems(t, application, "bob", "/api/v1/items?owner=me"); len(items) != 1 || items[0].Name != "Kettle" {
+		t.Errorf("Expected bob to own the kettle, got %+v", items)
+	}
+	// Items in the trash move with the others
+	if items := listOwnedItems(t, application, "auditor", "/api/v1/items?owner=2&include_deleted=true"); len(items) != 2 {
+		t.Errorf("Expected bob to own both items, got %+v", items)
+	}
+
+	if rec := doAs(t, application, "auditor", http.MethodDelete, "/api/v1/users/2?items=orphan", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d orphaning, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if rec := doAs(t, application, "carol", http.MethodPut, "/api/v1/items/1", `{"description": "Anyone's now"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected orphaned items to be open to anyone, got status %d", rec.Code)
+	}
+}
+
+func TestUserChangesRequireSelfOrAdmin(t *testing.T) {
+	application := setupAuditApp()
+
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com", "subject": "alice"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d binding a subject without the admin role, got %d", http.StatusForbidden, rec.Code)
+	}
+	doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com", "subject": "alice"}`)
+	doAs(t, application, "auditor", http.MethodPost, "/api/v1/users", `{"name": "Bob", "email": "bob@example.com", "subject": "bob"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Kettle"}`)
+
+	for _, tc := range []struct {
+		subject, method, path, body string
+		status                      int
+	}{
+		// Taking over another user's identity
+		{"mallory", http.MethodPut, "/api/v1/users/1", `{"subject": "mallory"}`, http.StatusForbidden},
+		{"alice", http.MethodPut, "/api/v1/users/1", `{"subject": "alice2"}`, http.StatusForbidden},
+		{"bob", http.MethodPut, "/api/v1/users/1", `{"name": "Not Alice"}`, http.StatusForbidden},
+		{"", http.MethodPut, "/api/v1/users/1", `{"name": "Not Alice"}`, http.StatusForbidden},
+		// Deleting another user and orphaning their items
+		{"bob", http.MethodDelete, "/api/v1/users/1?items=orphan", "", http.StatusForbidden},
+		{"bob", http.MethodDelete, "/api/v1/users/1?items=reassign&reassign_to=2", "", http.StatusForbidden},
+		{"bob", http.MethodDelete, "/api/v1/users/1", "", http.StatusForbidden},
+		{"alice", http.MethodDelete, "/api/v1/users/1?items=orphan", "", http.StatusForbidden},
+		{"alice", http.MethodPut, "/api/v1/users/1", `{"name": "Alice A."}`, http.StatusOK},
+		{"auditor", http.MethodPut, "/api/v1/users/2", `{"subject": "robert"}`, http.StatusOK},
+		{"robert", http.MethodDelete, "/api/v1/users/2", "", http.StatusOK},
+		{"bob", http.MethodPost, "/api/v1/users/2:restore", "", http.StatusForbidden},
+		{"auditor", http.MethodPost, "/api/v1/users/2:restore", "", http.StatusOK},
+	} {
+		if rec := doAs(t, application, tc.subject, tc.method, tc.path, tc.body); rec.Code != tc.status {
+			t.Errorf("Expected status %d for %q %s %s, got %d: %s", tc.status, tc.subject, tc.method, tc.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	if items := listOwnedItems(t, application, "alice", "/api/v1/items?owner=me"); len(items) != 1 {
+		t.Errorf("Expected alice to keep her kettle, got %+v", items)
+	}
+}
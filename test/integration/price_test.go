@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/handler"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/pkg/money"
+)
+
+type priceList struct {
+	Prices []model.PriceChange `json:"prices"`
+	Total  int                 `json:"total"`
+}
+
+func listPrices(t *testing.T, application *app.App, query string) priceList {
+	t.Helper()
+	rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items/1/prices?"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var list priceList
+	json.NewDecoder(rec.Body).Decode(&list)
+	return list
+}
+
+func TestPriceHistory(t *testing.T) {
+	application := setupAuditApp()
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Kettle", "price": {"amount": "30.00", "currency": "EUR"}}`)
+	between := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/1", `{"price": {"amount": "35.00", "currency": "EUR"}}`)
+	// Updates that leave the price alone are not recorded
+	doAs(t, application, "alice", http.MethodPut, "/api/v1/items/1", `{"name": "Electric Kettle"}`)
+
+	list := listPrices(t, application, "")
+	if list.Total != 2 || list.Prices[0].Price.Amount() != "30.00" || list.Prices[1].Price.Amount() != "35.00" {
+		t.Fatalf("Expected two applied prices, got %+v", list.Prices)
+	}
+
+	list = listPrices(t, application, "at="+url.QueryEscape(between.Format(time.RFC3339Nano)))
+	if list.Total != 1 || list.Prices[0].Price.Amount() != "30.00" {
+		t.Errorf("Expected the initial price in effect, got %+v", list.Prices)
+	}
+	list = listPrices(t, application, "at=2000-01-01T00:00:00Z")
+	if list.Total != 0 {
+		t.Errorf("Expected no price before the item existed, got %+v", list.Prices)
+	}
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items/1/prices?at=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid time, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/prices", `{"price": {"amount": "25.00", "currency": "EUR"}, "effective_at": "`+future+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if list := listPrices(t, application, "status=scheduled"); list.Total != 1 {
+		t.Errorf("Expected one scheduled price, got %+v", list.Prices)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/1/prices", `{"price": {"amount": "25.00", "currency": "EUR"}, "effective_at": "2000-01-01T00:00:00Z"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a past time, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	if rec := doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/1/prices/1", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d cancelling an applied price, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/1/prices/3", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d cancelling, got %d", http.StatusOK, rec.Code)
+	}
+	if list := listPrices(t, application, "status=scheduled"); list.Total != 0 {
+		t.Errorf("Expected the scheduled price to be cancelled, got %+v", list.Prices)
+	}
+}
+
+func TestScheduledPriceApplied(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+			AdminSubjects: []string{"auditor"},
+		},
+		Scheduler: config.SchedulerConfig{Schedules: map[string]string{"prices.apply": "@every 10ms"}},
+	}
+	application := app.New(cfg)
+	startApp(t, application)
+
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Kettle", "price": {"amount": "30.00", "currency": "EUR"}}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Teapot", "price": {"amount": "30.00", "currency": "EUR"}}`)
+	effective := time.Now().Add(50 * time.Millisecond).UTC()
+	soon := effective.Format(time.RFC3339Nano)
+	for _, id := range []string{"1", "2"} {
+		if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/"+id+"/prices", `{"price": {"amount": "25.00", "currency": "EUR"}, "effective_at": "`+soon+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+	doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/2", "")
+
+	waitFor(t, "scheduled price to apply", func() bool {
+		return itemPrice(t, application, 1) == "25.00"
+	})
+	list := listPrices(t, application, "status=applied")
+	if list.Total != 2 || list.Prices[1].Price.Amount() != "25.00" {
+		t.Fatalf("Expected the scheduled price in the history, got %+v", list.Prices)
+	}
+	if applied := list.Prices[1]; !applied.EffectiveAt.Equal(effective) || applied.AppliedAt == nil || applied.AppliedAt.Before(effective) {
+		t.Errorf("Expected the scheduled effective time and a later applied time, got %+v", applied)
+	}
+
+	// Items in the trash keep their price until restored
+	if price := itemPrice(t, application, 2); price != "30.00" {
+		t.Errorf("Expected the deleted item's price unchanged, got %s", price)
+	}
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items/2:restore", "")
+	waitFor(t, "scheduled price to apply after restore", func() bool {
+		return itemPrice(t, application, 2) == "25.00"
+	})
+}
+
+func TestSupersededScheduledPrice(t *testing.T) {
+	s := store.New()
+	h := handler.New(&config.Config{}, handler.Services{Store: s, Audit: audit.NewLog()})
+
+	// The item's price was changed after the scheduled change fell due but
+	// before it was applied
+	now := time.Now().UTC()
+	s.Update(func(tx *store.Tx) error {
+		store.Items.Insert(tx, func(id int64) model.Item {
+			return model.Item{ID: id, Name: "Kettle", Price: money.MustParse("40.00", "EUR")}
+		})
+		store.Prices.Insert(tx, func(id int64) model.PriceChange {
+			return model.PriceChange{ID: id, ItemID: 1, Price: money.MustParse("25.00", "EUR"), Status: model.PriceScheduled, EffectiveAt: now.Add(-2 * time.Minute)}
+		})
+		store.Prices.Insert(tx, func(id int64) model.PriceChange {
+			return model.PriceChange{ID: id, ItemID: 1, Price: money.MustParse("40.00", "EUR"), Status: model.PriceApplied, EffectiveAt: now.Add(-time.Minute)}
+		})
+		return nil
+	})
+
+	if n, err := h.ApplyScheduledPrices(); err != nil || n != 0 {
+		t.Fatalf("Expected no price to be set, got %d: %v", n, err)
+	}
+	s.View(func(tx *store.Tx) error {
+		if item, _ := store.Items.Get(tx, 1); item.Price.Amount() != "40.00" {
+			t.Errorf("Expected the later price to stay, got %s", item.Price.Amount())
+		}
+		if change, _ := store.Prices.Get(tx, 1); change.Status != model.PriceApplied || !change.EffectiveAt.Equal(now.Add(-2*time.Minute)) {
+			t.Errorf("Expected the change in the history at its scheduled time, got %+v", change)
+		}
+		return nil
+	})
+}
+
+// itemPrice returns the price amount of an item, including deleted ones
+func itemPrice(t *testing.T, application *app.App, id int64) string {
+	t.Helper()
+	rec := doAs(t, application, "auditor", http.MethodGet, "/api/v1/items?include_deleted=true", "")
+	var list struct {
+		Items []model.Item `json:"items"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	for _, item := range list.Items {
+		if item.ID == id {
+			return item.Price.Amount()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/model"
+)
+
+type itemStock struct {
+	model.Item
+	Reserved  int `json:"reserved"`
+	Available int `json:"available"`
+}
+
+func getStock(t *testing.T, application *app.App, path string) itemStock {
+	t.Helper()
+	rec := doAs(t, application, "alice", http.MethodGet, path, "")
+	var stock itemStock
+	json.NewDecoder(rec.Body).Decode(&stock)
+	return stock
+}
+
+func TestReservations(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "quantity": 10}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+	reserve := func(subject string, quantity int) *model.Reservation {
+		rec := doAs(t, application, subject, http.MethodPost, "/api/v1/reservations", `{"item_id": `+strconv.FormatInt(item.ID, 10)+`, "quantity": `+strconv.Itoa(quantity)+`}`)
+		if rec.Code != http.StatusCreated {
+			return nil
+		}
+		var res model.Reservation
+		json.NewDecoder(rec.Body).Decode(&res)
+		return &res
+	}
+
+	first := reserve("alice", 6)
+	if first == nil || first.Status != model.ReservationActive || first.Owner != "alice" {
+		t.Fatalf("Unexpected reservation %+v", first)
+	}
+	if stock := getStock(t, application, path); stock.Quantity != 10 || stock.Reserved != 6 || stock.Available != 4 {
+		t.Errorf("Expected 10 on hand with 4 available, got %+v", stock)
+	}
+	if reserve("bob", 5) != nil {
+		t.Errorf("Expected reservation beyond the available stock to fail")
+	}
+	second := reserve("bob", 4)
+	if second == nil {
+		t.Fatal("Expected the remaining stock to be reservable")
+	}
+
+	// Reserved stock cannot be sold or adjusted away
+	if rec := doAs(t, application, "alice", http.MethodPost, path+"/adjustments", `{"type": "sale", "quantity": 1}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d selling reserved stock, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPut, path, `{"quantity": 9}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d reducing reserved stock, got %d", http.StatusConflict, rec.Code)
+	}
+
+	// Reservations belong to their owner
+	firstPath := "/api/v1/reservations/" + strconv.FormatInt(first.ID, 10)
+	if rec := doAs(t, application, "bob", http.MethodGet, firstPath, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another owner's reservation, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "bob", http.MethodPost, firstPath+":confirm", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d confirming another owner's reservation, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodPost, firstPath+":confirm", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if stock := getStock(t, application, path); stock.Quantity != 4 || stock.Reserved != 4 || stock.Available != 0 {
+		t.Errorf("Expected confirmed stock to be sold, got %+v", stock)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, firstPath+":release", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d releasing a confirmed reservation, got %d", http.StatusConflict, rec.Code)
+	}
+
+	secondPath := "/api/v1/reservations/" + strconv.FormatInt(second.ID, 10)
+	if rec := doAs(t, application, "bob", http.MethodPost, secondPath+":release", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d releasing, got %d", http.StatusOK, rec.Code)
+	}
+	if stock := getStock(t, application, path); stock.Quantity != 4 || stock.Available != 4 {
+		t.Errorf("Expected released stock to be available, got %+v", stock)
+	}
+
+	var list struct {
+		Reservations []model.Reservation `json:"reservations"`
+		Total        int                 `json:"total"`
+	}
+	rec = doAs(t, application, "bob", http.MethodGet, "/api/v1/reservations", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 || list.Reservations[0].Status != model.ReservationReleased {
+		t.Errorf("Expected bob to see only his released reservation, got %+v", list.Reservations)
+	}
+	rec = doAs(t, application, "auditor", http.MethodGet, "/api/v1/reservations?status=confirmed", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 || list.Reservations[0].ID != first.ID {
+		t.Errorf("Expected admins to see all reservations, got %+v", list.Reservations)
+	}
+
+	for _, body := range []string{
+		`{"item_id": 1, "quantity": 0}`,
+		`{"item_id": 1, "quantity": 1, "ttl": "soon"}`,
+		`{"item_id": 1, "quantity": 1, "ttl": "48h"}`,
+	} {
+		if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/reservations", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/reservations", `{"item_id": 999, "quantity": 1}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing item, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestReservationsExpire(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+		},
+		Scheduler: config.SchedulerConfig{Schedules: map[string]string{"reservations.expire": "@every 10ms"}},
+	}
+	application := app.New(cfg)
+	startApp(t, application)
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "quantity": 3}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+
+	rec = doAs(t, application, "alice", http.MethodPost, "/api/v1/reservations", `{"item_id": `+strconv.FormatInt(item.ID, 10)+`, "quantity": 3, "ttl": "50ms"}`)
+	var res model.Reservation
+	json.NewDecoder(rec.Body).Decode(&res)
+	if stock := getStock(t, application, path); stock.Available != 0 {
+		t.Errorf("Expected no stock available while reserved, got %+v", stock)
+	}
+
+	resPath := "/api/v1/reservations/" + strconv.FormatInt(res.ID, 10)
+	waitFor(t, "reservation to expire", func() bool {
+		rec := doAs(t, application, "alice", http.MethodGet, resPath, "")
+		json.NewDecoder(rec.Body).Decode(&res)
+		return res.Status == model.ReservationExpired
+	})
+	if stock := getStock(t, application, path); stock.Available != 3 {
+		t.Errorf("Expected expired reservation to free its stock, got %+v", stock)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, resPath+":confirm", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d confirming an expired reservation, got %d", http.StatusConflict, rec.Code)
+	}
+}
+
+func TestConcurrentReservationsNeverOversell(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Concert Ticket", "quantity": 20}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	body := `{"item_id": ` + strconv.FormatInt(item.ID, 10) + `, "quantity": 3, "ttl": "1h"}`
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	held := 0
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := doAs(t, application, "buyer"+strconv.Itoa(i), http.MethodPost, "/api/v1/reservations", body)
+			if rec.Code == http.StatusCreated {
+				mu.Lock()
+				held += 3
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if held != 18 {
+		t.Errorf("Expected 18 units reserved, got %d", held)
+	}
+	if stock := getStock(t, application, "/api/v1/items/"+strconv.FormatInt(item.ID, 10)); stock.Reserved != 18 || stock.Available != 2 {
+		t.Errorf("Unexpected stock %+v", stock)
+	}
+}
@@ -0,0 +1,253 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/scheduler"
+	"github.com/gostructure/app/internal/store"
+)
+
+// runScheduler runs s until the test ends
+func runScheduler(t *testing.T, s *scheduler.Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func scheduleStatus(s *scheduler.Scheduler, name string) scheduler.Status {
+	for _, status := range s.List() {
+		if status.Name == name {
+			return status
+		}
+	}
+	return scheduler.Status{}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 * *", time.Date(2024, time.March, 30, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Saturday, written as 6; Sunday as 7
+		{"0 12 * * 6,7", time.Date(2024, time.February, 3, 12, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 15 * 4", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		spec, err := scheduler.Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := spec.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@every -1s", "@sometimes"} {
+		if _, err := scheduler.Parse(expr); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", expr)
+		}
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	s := scheduler.New(config.SchedulerConfig{InstanceID: "a"}, nil)
+
+	release := make(chan struct{})
+	var runs atomic.Int32
+	s.Add("slow", "@every 10ms", func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			<-release
+		}
+		return errors.New("boom")
+	})
+	runScheduler(t, s)
+
+	waitFor(t, "a skipped run", func() bool {
+		return scheduleStatus(s, "slow").Skipped > 0
+	})
+	if runs.Load() != 1 {
+		t.Errorf("Expected the slow run to block further runs, got %d runs", runs.Load())
+	}
+	close(release)
+
+	waitFor(t, "runs to resume", func() bool {
+		return scheduleStatus(s, "slow").Runs >= 2
+	})
+	status := scheduleStatus(s, "slow")
+	if status.LastRun == nil || status.NextRun == nil || status.LastError != "boom" || status.Failures < 2 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestSchedulerLeaseRunsTaskOnce(t *testing.T) {
+	dir := t.TempDir()
+
+	var counts [2]atomic.Int32
+	for i, id := range []string{"replica-1", "replica-2"} {
+		lease, err := scheduler.NewFileLease(dir)
+		if err != nil {
+			t.Fatalf("Failed to create lease: %v", err)
+		}
+		s := scheduler.New(config.SchedulerConfig{InstanceID: id, LeaseTTL: time.Second}, lease)
+		s.Add("report", "@every 10ms", func(ctx context.Context) error {
+			counts[i].Add(1)
+			return nil
+		})
+		runScheduler(t, s)
+	}
+
+	waitFor(t, "several runs", func() bool {
+		return counts[0].Load()+counts[1].Load() >= 5
+	})
+	if counts[0].Load() > 0 && counts[1].Load() > 0 {
+		t.Errorf("Expected one replica to hold the lease, got %d and %d runs", counts[0].Load(), counts[1].Load())
+	}
+}
+
+func TestLeaseExpiresAndReleases(t *testing.T) {
+	lease, err := scheduler.NewFileLease(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create lease: %v", err)
+	}
+
+	if ok, _ := lease.Acquire("task", "a", 20*time.Millisecond); !ok {
+		t.Fatal("Expected first holder to acquire the lease")
+	}
+	if ok, _ := lease.Acquire("task", "b", time.Minute); ok {
+		t.Fatal("Expected a held lease to be refused")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := lease.Acquire("task", "b", time.Minute); !ok {
+		t.Fatal("Expected an expired lease to be taken over")
+	}
+	lease.Release("task", "b")
+	if ok, _ := lease.Acquire("task", "a", time.Minute); !ok {
+		t.Fatal("Expected a released lease to be free")
+	}
+}
+
+func TestAdminSchedules(t *testing.T) {
+	cfg := &config.Config{
+		App:       config.AppConfig{Name: "Test App", Environment: "test"},
+		Scheduler: config.SchedulerConfig{Schedules: map[string]string{"store.compact": "off", "jobs.purge": "*/5 * * * *"}},
+	}
+	application := app.New(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/schedules", nil)
+	rec := httptest.NewRecorder()
+	application.AdminRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var body struct {
+		Schedules []scheduler.Status `json:"schedules"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	specs := make(map[string]scheduler.Status)
+	for _, s := range body.Schedules {
+		specs[s.Name] = s
+	}
+	if !specs["store.compact"].Disabled {
+		t.Errorf("Expected store.compact to be disabled, got %+v", specs["store.compact"])
+	}
+	if specs["jobs.purge"].Spec != "*/5 * * * *" {
+		t.Errorf("Expected overridden jobs.purge schedule, got %+v", specs["jobs.purge"])
+	}
+}
+
+func TestMaintenanceTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.journal")
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	cfg := testJobsConfig
+	cfg.Retention = time.Millisecond
+	m := jobs.NewManager(cfg, s)
+	m.Register("noop", func(ctx context.Context, job jobs.Job) (interface{}, error) {
+		return nil, nil
+	})
+	runManager(t, m)
+
+	job, _ := m.Enqueue("noop", nil, "test")
+	waitForJob(t, m, job.ID)
+	for i := 0; i < 3; i++ {
+		s.Update(func(tx *store.Tx) error {
+			_, err := store.Items.Insert(tx, func(id int64) model.Item {
+				return model.Item{ID: id, Name: "Item"}
+			})
+			return err
+		})
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if n, err := m.Purge(); err != nil || n != 1 {
+		t.Errorf("Expected 1 purged job, got %d (%v)", n, err)
+	}
+	if _, ok := m.Get(job.ID); ok {
+		t.Error("Expected purged job to be gone")
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := bytes.Count(data, []byte("\n")); lines != 1 {
+		t.Errorf("Expected a single snapshot line after compaction, got %d", lines)
+	}
+
+	// Writes after compaction are journaled to the new file
+	s.Update(func(tx *store.Tx) error {
+		_, err := store.Items.Insert(tx, func(id int64) model.Item {
+			return model.Item{ID: id, Name: "After"}
+		})
+		return err
+	})
+	s.Close()
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	reopened.View(func(tx *store.Tx) error {
+		if n := len(store.Items.List(tx)); n != 4 {
+			t.Errorf("Expected 4 items after reopening, got %d", n)
+		}
+		return nil
+	})
+}
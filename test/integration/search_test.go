@@ -0,0 +1,143 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/model"
+	"github.com/gostructure/app/internal/search"
+	"github.com/gostructure/app/internal/store"
+)
+
+type searchResponse struct {
+	Results []struct {
+		search.Hit
+		Data json.RawMessage `json:"data"`
+	} `json:"results"`
+	Total int `json:"total"`
+}
+
+func searchFor(t *testing.T, application *app.App, query string) searchResponse {
+	t.Helper()
+	rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/search?"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var result searchResponse
+	json.NewDecoder(rec.Body).Decode(&result)
+	return result
+}
+
+func TestSearchIndex(t *testing.T) {
+	ix := search.NewIndex()
+	ix.Put("item", 1, []search.Field{{Name: "name", Text: "Blue Widget", Weight: 2}, {Name: "description", Text: "A small widget"}})
+	ix.Put("item", 2, []search.Field{{Name: "name", Text: "Gadget", Weight: 2}, {Name: "description", Text: "Works with any widget & <gizmo>"}})
+	ix.Put("user", 1, []search.Field{{Name: "name", Text: "ΟΔΥΣΣΕΥΣ"}})
+
+	hits := ix.Search(search.Query{Text: "WIDG"})
+	if len(hits) != 2 || hits[0].ID != 1 {
+		t.Fatalf("Expected both widgets with the name match first, got %+v", hits)
+	}
+	if got := hits[0].Highlights["name"]; got != "Blue <mark>Widget</mark>" {
+		t.Errorf("Unexpected highlight %q", got)
+	}
+	if got := hits[1].Highlights["description"]; got != "Works with any <mark>widget</mark> &amp; &lt;gizmo&gt;" {
+		t.Errorf("Expected escaped highlight, got %q", got)
+	}
+
+	if hits := ix.Search(search.Query{Text: "widget gadget"}); len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("Expected every term to be required, got %+v", hits)
+	}
+	if hits := ix.Search(search.Query{Text: "widget", Resource: "user"}); len(hits) != 0 {
+		t.Errorf("Expected type filter to apply, got %+v", hits)
+	}
+	if hits := ix.Search(search.Query{Text: "οδυσσευς"}); len(hits) != 1 {
+		t.Errorf("Expected case-folded match, got %+v", hits)
+	}
+
+	ix.Remove("item", 1)
+	if hits := ix.Search(search.Query{Text: "blue"}); len(hits) != 0 {
+		t.Errorf("Expected removed document to be gone, got %+v", hits)
+	}
+}
+
+func TestSearchEndpoint(t *testing.T) {
+	application := setupAuditApp()
+	startApp(t, application)
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Espresso Machine", "description": "Brews espresso and steams milk"}`)
+	var item struct {
+		ID int64 `json:"id"`
+	}
+	json.NewDecoder(rec.Body).Decode(&item)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Milk Jug", "description": "Stainless steel"}`)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Milka Smith", "email": "milka@example.com"}`)
+
+	var result searchResponse
+	waitFor(t, "items to be indexed", func() bool {
+		result = searchFor(t, application, "q=milk")
+		return result.Total == 3
+	})
+	if result.Results[0].Resource != "item" || result.Results[0].Data == nil {
+		t.Errorf("Expected exact item matches to rank first with their data, got %+v", result.Results[0])
+	}
+
+	result = searchFor(t, application, "q="+url.QueryEscape("milk steel")+"&type=item")
+	if result.Total != 1 {
+		t.Fatalf("Expected one item matching both terms, got %+v", result.Results)
+	}
+	if got := result.Results[0].Highlights; got["name"] != "<mark>Milk</mark> Jug" || got["description"] != "Stainless <mark>steel</mark>" {
+		t.Errorf("Unexpected highlights %+v", got)
+	}
+
+	// Updates and deletes are reflected
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+	doAs(t, application, "alice", http.MethodPut, path, `{"description": "Pulls shots"}`)
+	doAs(t, application, "alice", http.MethodDelete, "/api/v1/items/2", "")
+	waitFor(t, "index to catch up", func() bool {
+		return searchFor(t, application, "q=milk&type=item").Total == 0
+	})
+	if result := searchFor(t, application, "q=shots"); result.Total != 1 {
+		t.Errorf("Expected updated description to be indexed, got %+v", result.Results)
+	}
+
+	for _, query := range []string{"", "q=milk&type=order", "q=milk&limit=0"} {
+		if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/search?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Query %q: expected status %d, got %d", query, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestSearchIndexRebuiltOnStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.journal")
+	cfg := &config.Config{
+		App:   config.AppConfig{Name: "Test App", Environment: "test"},
+		Store: config.StoreConfig{File: path},
+	}
+
+	// Write directly to the store so no events reach the index
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	s.Update(func(tx *store.Tx) error {
+		return store.Items.Put(tx, 1, model.Item{ID: 1, Name: "Persisted Teapot"})
+	})
+	s.Close()
+
+	application, err := app.Open(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open app: %v", err)
+	}
+	startApp(t, application)
+
+	if result := searchFor(t, application, "q=teapot"); result.Total != 1 {
+		t.Errorf("Expected rebuilt index to find the persisted item, got %+v", result.Results)
+	}
+}
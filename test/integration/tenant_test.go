@@ -0,0 +1,296 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/events"
+	"github.com/gostructure/app/internal/jobs"
+	"github.com/gostructure/app/internal/model"
+)
+
+func setupTenantApp() *app.App {
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+			TenantHeader:  "X-Auth-Tenant",
+			AdminSubjects: []string{"auditor"},
+		},
+		Tenancy: config.TenancyConfig{
+			Enabled:    true,
+			Header:     "X-Tenant-ID",
+			BaseDomain: "example.com",
+			Tenants:    []string{"acme", "globex", "initech"},
+			MaxItems:   2,
+			Overrides: map[string]config.TenantOverrides{
+				"initech": {MaxItems: 1, AttachmentTypes: []string{"application/pdf"}},
+			},
+		},
+	}
+	return app.New(cfg)
+}
+
+func doTenant(t *testing.T, application *app.App, tenant, subject, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("X-Auth-Subject", subject)
+	if tenant != "" {
+		req.Header.Set("X-Tenant-ID", tenant)
+	}
+	rec := httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, req)
+	return rec
+}
+
+func tenantItemNames(t *testing.T, application *app.App, tenant string) []string {
+	t.Helper()
+	rec := doTenant(t, application, tenant, "alice", http.MethodGet, "/api/v1/items", "")
+	var list struct {
+		Items []model.Item `json:"items"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestTenantIsolation(t *testing.T) {
+	application := setupTenantApp()
+	startApp(t, application)
+
+	rec := doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items", `{"name": "Anvil", "quantity": 3}`)
+	var anvil model.Item
+	json.NewDecoder(rec.Body).Decode(&anvil)
+	if rec.Code != http.StatusCreated || anvil.ID != 1 || anvil.Tenant != "acme" {
+		t.Fatalf("Expected acme's item, got status %d: %+v", rec.Code, anvil)
+	}
+	doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/users", `{"name": "Wile", "email": "wile@acme.example"}`)
+	doTenant(t, application, "globex", "alice", http.MethodPost, "/api/v1/items", `{"name": "Gizmo", "quantity": 1}`)
+
+	// Another tenant can neither read nor change acme's records
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/api/v1/items/1", ""},
+		{http.MethodPut, "/api/v1/items/1", `{"name": "Stolen"}`},
+		{http.MethodDelete, "/api/v1/items/1", ""},
+		{http.MethodPost, "/api/v1/items/1/adjustments", `{"type": "sale", "quantity": 1}`},
+		{http.MethodGet, "/api/v1/items/1/movements", ""},
+		{http.MethodGet, "/api/v1/users/1", ""},
+		{http.MethodPut, "/api/v1/users/1", `{"name": "Stolen"}`},
+	} {
+		if rec := doTenant(t, application, "globex", "alice", tc.method, tc.path, tc.body); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for globex %s %s, got %d", http.StatusNotFound, tc.method, tc.path, rec.Code)
+		}
+	}
+	rec = doTenant(t, application, "acme", "alice", http.MethodGet, "/api/v1/items/1", "")
+	json.NewDecoder(rec.Body).Decode(&anvil)
+	if rec.Code != http.StatusOK || anvil.Name != "Anvil" || anvil.Quantity != 3 {
+		t.Errorf("Expected acme's item unchanged, got status %d: %+v", rec.Code, anvil)
+	}
+
+	if names := tenantItemNames(t, application, "acme"); len(names) != 1 || names[0] != "Anvil" {
+		t.Errorf("Expected only acme's items, got %v", names)
+	}
+	if names := tenantItemNames(t, application, "globex"); len(names) != 1 || names[0] != "Gizmo" {
+		t.Errorf("Expected only globex's items, got %v", names)
+	}
+
+	waitFor(t, "items to be indexed", func() bool {
+		rec := doTenant(t, application, "globex", "alice", http.MethodGet, "/api/v1/search?q=gizmo", "")
+		return bytes.Contains(rec.Body.Bytes(), []byte(`"total":1`))
+	})
+	if rec := doTenant(t, application, "globex", "alice", http.MethodGet, "/api/v1/search?q=anvil", ""); !bytes.Contains(rec.Body.Bytes(), []byte(`"total":0`)) {
+		t.Errorf("Expected no search results from acme, got %s", rec.Body.String())
+	}
+
+	var entries struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	rec = doTenant(t, application, "globex", "auditor", http.MethodGet, "/api/v1/audit", "")
+	json.NewDecoder(rec.Body).Decode(&entries)
+	if len(entries.Entries) != 1 || entries.Entries[0].Tenant != "globex" {
+		t.Errorf("Expected globex's audit entry only, got %+v", entries.Entries)
+	}
+
+	rec = doTenant(t, application, "globex", "alice", http.MethodPost, "/api/v1/items/export", "")
+	location := rec.Header().Get("Location")
+	if rec := doTenant(t, application, "acme", "alice", http.MethodGet, location, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for another tenant's job, got %d", http.StatusNotFound, rec.Code)
+	}
+	var job jobs.Job
+	waitFor(t, "export job", func() bool {
+		rec := doTenant(t, application, "globex", "alice", http.MethodGet, location, "")
+		json.NewDecoder(rec.Body).Decode(&job)
+		return job.Done()
+	})
+	var export struct {
+		Total int `json:"total"`
+	}
+	json.Unmarshal(job.Result, &export)
+	if job.Status != jobs.StatusSucceeded || export.Total != 1 {
+		t.Errorf("Expected globex's export to hold one item, got %+v", job)
+	}
+}
+
+func TestTenantResolution(t *testing.T) {
+	application := setupTenantApp()
+
+	for _, tc := range []struct {
+		name    string
+		host    string
+		headers map[string]string
+		status  int
+	}{
+		{"header", "", map[string]string{"X-Tenant-ID": "acme"}, http.StatusOK},
+		{"subdomain", "acme.example.com", nil, http.StatusOK},
+		{"claim", "", map[string]string{"X-Auth-Tenant": "acme"}, http.StatusOK},
+		{"claim and matching header", "", map[string]string{"X-Auth-Tenant": "acme", "X-Tenant-ID": "acme"}, http.StatusOK},
+		{"missing", "", nil, http.StatusBadRequest},
+		{"invalid", "", map[string]string{"X-Tenant-ID": "Acme!"}, http.StatusBadRequest},
+		{"unknown", "", map[string]string{"X-Tenant-ID": "umbrella"}, http.StatusNotFound},
+		{"header and subdomain differ", "globex.example.com", map[string]string{"X-Tenant-ID": "acme"}, http.StatusBadRequest},
+		{"header differs from claim", "", map[string]string{"X-Auth-Tenant": "acme", "X-Tenant-ID": "globex"}, http.StatusForbidden},
+		{"subdomain differs from claim", "globex.example.com", map[string]string{"X-Auth-Tenant": "acme"}, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/items", nil)
+		if tc.host != "" {
+			req.Host = tc.host
+		}
+		req.Header.Set("X-Auth-Subject", "alice")
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		application.Router().ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tc.name, tc.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Endpoints outside the API need no tenant
+	rec := httptest.NewRecorder()
+	application.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for the health check, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestTenantQuotas(t *testing.T) {
+	application := setupTenantApp()
+
+	for _, name := range []string{"One", "Two"} {
+		if rec := doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items", `{"name": "`+name+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items", `{"name": "Three"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d past the quota, got %d", http.StatusForbidden, rec.Code)
+	}
+	if rec := doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items/import", `{"items": [{"name": "Three"}]}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d importing past the quota, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	// Deleting frees quota and restoring takes it again
+	doTenant(t, application, "acme", "alice", http.MethodDelete, "/api/v1/items/1", "")
+	if rec := doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items", `{"name": "Three"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected status %d after a delete, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec := doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items/1:restore", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d restoring past the quota, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	// Quotas are per tenant, with overrides
+	if rec := doTenant(t, application, "globex", "alice", http.MethodPost, "/api/v1/items", `{"name": "One"}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected globex to have its own quota, got status %d", rec.Code)
+	}
+	doTenant(t, application, "initech", "alice", http.MethodPost, "/api/v1/items", `{"name": "One"}`)
+	if rec := doTenant(t, application, "initech", "alice", http.MethodPost, "/api/v1/items", `{"name": "Two"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d past initech's override, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestTenantAttachmentTypes(t *testing.T) {
+	application := setupTenantApp()
+	image := testPNG(t, 10, 10)
+
+	for _, tc := range []struct {
+		tenant string
+		code   int
+	}{
+		{"acme", http.StatusCreated},
+		{"initech", http.StatusUnsupportedMediaType},
+	} {
+		var item model.Item
+		rec := doTenant(t, application, tc.tenant, "alice", http.MethodPost, "/api/v1/items", `{"name": "Lamp"}`)
+		json.NewDecoder(rec.Body).Decode(&item)
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "lamp.png")
+		part.Write(image)
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/items/"+strconv.FormatInt(item.ID, 10)+"/attachments", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("X-Auth-Subject", "alice")
+		req.Header.Set("X-Tenant-ID", tc.tenant)
+		rec = httptest.NewRecorder()
+		application.Router().ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("Expected status %d uploading an image to %s, got %d: %s", tc.code, tc.tenant, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestTenantMetricsRoute(t *testing.T) {
+	application := setupTenantApp()
+	doTenant(t, application, "acme", "alice", http.MethodGet, "/api/v1/items/42", "")
+
+	rec := httptest.NewRecorder()
+	application.AdminRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	expected := `http_requests_total{method="GET",route="GET /api/v1/items/{id}",status="404"} 1`
+	if !strings.Contains(rec.Body.String(), expected) {
+		t.Errorf("Expected metrics to contain %q, got:\n%s", expected, rec.Body.String())
+	}
+}
+
+func TestTenantEvents(t *testing.T) {
+	application := setupTenantApp()
+	startApp(t, application)
+	srv := httptest.NewServer(application.Router())
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/events?resources=item", nil)
+	req.Header.Set("X-Tenant-ID", "globex")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+
+	doTenant(t, application, "acme", "alice", http.MethodPost, "/api/v1/items", `{"name": "Anvil"}`)
+	doTenant(t, application, "globex", "alice", http.MethodPost, "/api/v1/items", `{"name": "Gizmo"}`)
+
+	var e events.Event
+	json.Unmarshal([]byte(readSSE(t, stream).data), &e)
+	var item model.Item
+	json.Unmarshal(e.Data, &item)
+	if e.Type != "item.created" || e.Tenant != "globex" || item.Name != "Gizmo" {
+		t.Errorf("Expected only globex's event, got %+v", e)
+	}
+}
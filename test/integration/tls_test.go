@@ -0,0 +1,243 @@
+package integration
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/server"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if isCA {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	if err != nil {
+		t.Fatalf("Failed to build key pair: %v", err)
+	}
+	return pair
+}
+
+func writeServerCert(t *testing.T, dir string, cert *testCert) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "server.crt"), cert.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "server.key"), cert.keyPEM(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTLSServer serves the application over TLS using the server package
+func startTLSServer(t *testing.T, tlsCfg config.TLSConfig) (string, *server.CertReloader) {
+	t.Helper()
+
+	reloader, err := server.NewCertReloader(tlsCfg)
+	if err != nil {
+		t.Fatalf("Failed to create reloader: %v", err)
+	}
+	serverTLS, err := server.NewTLSConfig(tlsCfg, reloader)
+	if err != nil {
+		t.Fatalf("Failed to create TLS config: %v", err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: setupTestApp().Router()}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	return "https://" + ln.Addr().String(), reloader
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "Test CA", nil, true)
+	writeServerCert(t, dir, newTestCert(t, "localhost", ca, false))
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	url, _ := startTLSServer(t, config.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		MinVersion:   "1.2",
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// Without a client certificate the handshake must fail
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if resp, err := client.Get(url + "/api/v1/info"); err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected request without client certificate to fail")
+	}
+
+	clientCert := newTestCert(t, "billing-service", ca, false)
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert.tlsCertificate(t)},
+	}}}
+
+	resp, err := client.Get(url + "/api/v1/info")
+	if err != nil {
+		t.Fatalf("Request with client certificate failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		Client struct {
+			CommonName string `json:"common_name"`
+		} `json:"client"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Client.CommonName != "billing-service" {
+		t.Errorf("Expected client identity 'billing-service', got '%s'", info.Client.CommonName)
+	}
+}
+
+func TestTLSCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "Test CA", nil, true)
+	writeServerCert(t, dir, newTestCert(t, "first", ca, false))
+
+	url, reloader := startTLSServer(t, config.TLSConfig{
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	servedCN := func() string {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(url + "/health")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	if cn := servedCN(); cn != "first" {
+		t.Fatalf("Expected certificate 'first', got '%s'", cn)
+	}
+
+	writeServerCert(t, dir, newTestCert(t, "second", ca, false))
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if cn := servedCN(); cn != "second" {
+		t.Errorf("Expected rotated certificate 'second', got '%s'", cn)
+	}
+}
+
+func TestTLSConfigRejectsUnknownCipher(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "Test CA", nil, true)
+	writeServerCert(t, dir, newTestCert(t, "localhost", ca, false))
+
+	tlsCfg := config.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"},
+	}
+	reloader, err := server.NewCertReloader(tlsCfg)
+	if err != nil {
+		t.Fatalf("Failed to create reloader: %v", err)
+	}
+	if _, err := server.NewTLSConfig(tlsCfg, reloader); err == nil {
+		t.Error("Expected insecure cipher suite to be rejected")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	handler := server.RedirectHandler(":8443")
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com:8080/api/v1/items?page=2", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Errorf("Expected status %d, got %d", http.StatusPermanentRedirect, rec.Code)
+	}
+	if got := rec.Header().Get("Location"); got != "https://api.example.com:8443/api/v1/items?page=2" {
+		t.Errorf("Unexpected redirect location '%s'", got)
+	}
+}
@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/audit"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/model"
+)
+
+func TestSoftDeleteAndRestoreItem(t *testing.T) {
+	application := setupAuditApp()
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Widget", "quantity": 5}`)
+	var item model.Item
+	json.NewDecoder(rec.Body).Decode(&item)
+	path := "/api/v1/items/" + strconv.FormatInt(item.ID, 10)
+
+	if rec := doAs(t, application, "alice", http.MethodDelete, path, ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	// Deleted items are hidden from everyone by default
+	if rec := doAs(t, application, "alice", http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a deleted item, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPut, path, `{"quantity": 1}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d updating a deleted item, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodDelete, path, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d deleting twice, got %d", http.StatusNotFound, rec.Code)
+	}
+	var list struct {
+		Items []model.Item `json:"items"`
+		Total int          `json:"total"`
+	}
+	rec = doAs(t, application, "alice", http.MethodGet, "/api/v1/items", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 0 {
+		t.Errorf("Expected deleted item to be excluded from the list, got %d", list.Total)
+	}
+
+	// Only admins may see the trash
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items?include_deleted=true", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	rec = doAs(t, application, "auditor", http.MethodGet, "/api/v1/items?include_deleted=true", "")
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 || list.Items[0].DeletedAt == nil {
+		t.Errorf("Expected deleted item with deleted_at for admins, got %+v", list.Items)
+	}
+	if rec := doAs(t, application, "auditor", http.MethodGet, path+"?include_deleted=true", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = doAs(t, application, "alice", http.MethodPost, path+":restore", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var restored model.Item
+	json.NewDecoder(rec.Body).Decode(&restored)
+	if restored.DeletedAt != nil || restored.Quantity != 5 {
+		t.Errorf("Expected restored item, got %+v", restored)
+	}
+	if rec := doAs(t, application, "alice", http.MethodGet, path, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected restored item to be visible, got status %d", rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":restore", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d restoring a live item, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, path+":archive", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown action, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/items/999:restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing item, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestPurgeDeletedRecords(t *testing.T) {
+	cfg := &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+			AdminSubjects: []string{"auditor"},
+		},
+		Store:     config.StoreConfig{TrashRetention: time.Millisecond},
+		Scheduler: config.SchedulerConfig{Schedules: map[string]string{"trash.purge": "@every 10ms"}},
+	}
+	application := app.New(cfg)
+	startApp(t, application)
+
+	rec := doAs(t, application, "alice", http.MethodPost, "/api/v1/users", `{"name": "Alice", "email": "alice@example.com"}`)
+	var user model.User
+	json.NewDecoder(rec.Body).Decode(&user)
+	userPath := "/api/v1/users/" + strconv.FormatInt(user.ID, 10)
+	doAs(t, application, "alice", http.MethodPost, "/api/v1/items", `{"name": "Kept"}`)
+
+	doAs(t, application, "alice", http.MethodDelete, userPath, "")
+
+	waitFor(t, "deleted user to be purged", func() bool {
+		rec := doAs(t, application, "auditor", http.MethodGet, userPath+"?include_deleted=true", "")
+		return rec.Code == http.StatusNotFound
+	})
+	if rec := doAs(t, application, "alice", http.MethodGet, "/api/v1/items", ""); !strings.Contains(rec.Body.String(), "Kept") {
+		t.Errorf("Expected live items to survive the purge, got %s", rec.Body.String())
+	}
+	if rec := doAs(t, application, "auditor", http.MethodPost, userPath+":restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected purged user not to be restorable, got status %d", rec.Code)
+	}
+
+	// The purge is audited once its transaction commits
+	waitFor(t, "purge audit entry", func() bool {
+		rec := doAs(t, application, "auditor", http.MethodGet, "/api/v1/audit?resource=user&actor=system", "")
+		var result struct {
+			Entries []audit.Entry `json:"entries"`
+		}
+		json.NewDecoder(rec.Body).Decode(&result)
+		return len(result.Entries) == 1 && result.Entries[0].Action == audit.ActionPurge
+	})
+}
@@ -0,0 +1,309 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gostructure/app/internal/app"
+	"github.com/gostructure/app/internal/config"
+	"github.com/gostructure/app/internal/store"
+	"github.com/gostructure/app/internal/webhook"
+)
+
+func webhookTestConfig() *config.Config {
+	return &config.Config{
+		App: config.AppConfig{Name: "Test App", Environment: "test"},
+		Auth: config.AuthConfig{
+			TrustHeaders:  true,
+			SubjectHeader: "X-Auth-Subject",
+			RolesHeader:   "X-Auth-Roles",
+			AdminSubjects: []string{"ops"},
+		},
+		Webhooks: config.WebhookConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+		},
+	}
+}
+
+func setupWebhookApp(t *testing.T) *app.App {
+	t.Helper()
+	application := app.New(webhookTestConfig())
+	if err := application.Lifecycle().Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { application.Lifecycle().Stop(context.Background()) })
+	return application
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func listDeliveries(t *testing.T, application *app.App, subID, query string) []webhook.Delivery {
+	t.Helper()
+	rec := doAs(t, application, "ops", http.MethodGet, "/api/v1/webhooks/"+subID+"/deliveries"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var result struct {
+		Deliveries []webhook.Delivery `json:"deliveries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode deliveries: %v", err)
+	}
+	return result.Deliveries
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	application := setupWebhookApp(t)
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var mu sync.Mutex
+	var requests []received
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, received{header: r.Header, body: body})
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	rec := doAs(t, application, "ops", http.MethodPost, "/api/v1/webhooks",
+		`{"url": "`+receiver.URL+`", "events": ["item.*"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var sub webhook.Subscription
+	if err := json.NewDecoder(rec.Body).Decode(&sub); err != nil {
+		t.Fatalf("Failed to decode subscription: %v", err)
+	}
+	if sub.Secret == "" || !sub.Active {
+		t.Fatalf("Expected an active subscription with a generated secret, got %+v", sub)
+	}
+
+	// The secret is only disclosed on creation
+	rec = doAs(t, application, "ops", http.MethodGet, "/api/v1/webhooks/"+sub.ID, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var fetched webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&fetched)
+	if fetched.Secret != "" {
+		t.Error("Expected secret to be withheld")
+	}
+
+	doAs(t, application, "ops", http.MethodPost, "/api/v1/users", `{"name": "Not delivered", "email": "nd@example.com"}`)
+	doAs(t, application, "ops", http.MethodPost, "/api/v1/items", `{"name": "Hooked", "quantity": 1}`)
+
+	waitFor(t, "webhook delivery", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) > 0
+	})
+
+	mu.Lock()
+	got := requests[0]
+	mu.Unlock()
+	if got.header.Get(webhook.HeaderEvent) != "item.created" {
+		t.Errorf("Expected item.created event, got %q", got.header.Get(webhook.HeaderEvent))
+	}
+	if err := webhook.Verify(sub.Secret, got.header.Get(webhook.HeaderSignature), got.body, time.Minute); err != nil {
+		t.Errorf("Expected a valid signature: %v", err)
+	}
+	if err := webhook.Verify("wrong-secret", got.header.Get(webhook.HeaderSignature), got.body, time.Minute); err == nil {
+		t.Error("Expected signature check with the wrong secret to fail")
+	}
+
+	waitFor(t, "succeeded delivery", func() bool {
+		deliveries := listDeliveries(t, application, sub.ID, "?status=succeeded")
+		return len(deliveries) == 1 && deliveries[0].ID == got.header.Get(webhook.HeaderID)
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 {
+		t.Errorf("Expected only the item event to be delivered, got %d requests", len(requests))
+	}
+}
+
+func TestWebhookRetriesAndDeadLetter(t *testing.T) {
+	application := setupWebhookApp(t)
+
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	rec := doAs(t, application, "ops", http.MethodPost, "/api/v1/webhooks",
+		`{"url": "`+receiver.URL+`", "events": ["user.created"]}`)
+	var sub webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&sub)
+
+	doAs(t, application, "ops", http.MethodPost, "/api/v1/users", `{"name": "Retry", "email": "retry@example.com"}`)
+
+	var dead webhook.Delivery
+	waitFor(t, "dead-lettered delivery", func() bool {
+		deliveries := listDeliveries(t, application, sub.ID, "?status=dead")
+		if len(deliveries) == 1 {
+			dead = deliveries[0]
+			return true
+		}
+		return false
+	})
+	if len(dead.Attempts) != 3 || calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d (%d calls)", len(dead.Attempts), calls.Load())
+	}
+	if dead.Attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected attempts to record the response status, got %+v", dead.Attempts[0])
+	}
+	if gap := dead.Attempts[2].At.Sub(dead.Attempts[1].At); gap < 20*time.Millisecond {
+		t.Errorf("Expected exponential backoff between attempts, got %v", gap)
+	}
+
+	// Manual redelivery once the receiver recovers
+	healthy.Store(true)
+	rec = doAs(t, application, "ops", http.MethodPost, "/api/v1/webhooks/"+sub.ID+"/deliveries/"+dead.ID+"/redeliver", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+
+	waitFor(t, "redelivery", func() bool {
+		deliveries := listDeliveries(t, application, sub.ID, "?status=succeeded")
+		return len(deliveries) == 1 && deliveries[0].RedeliveryOf == dead.ID
+	})
+}
+
+func TestWebhookDeliverySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.journal")
+	cfg := webhookTestConfig()
+	cfg.Webhooks.MaxAttempts = 1000
+
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	var delivered atomic.Value
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		delivered.Store(string(body))
+	}))
+	defer receiver.Close()
+
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	application := app.New(cfg, app.WithStore(s))
+	if err := application.Lifecycle().Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	rec := doAs(t, application, "ops", http.MethodPost, "/api/v1/webhooks",
+		`{"url": "`+receiver.URL+`", "events": ["item.created"]}`)
+	var sub webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&sub)
+	doAs(t, application, "ops", http.MethodPost, "/api/v1/items", `{"name": "Durable"}`)
+
+	// The relay has acknowledged the event but the receiver keeps failing
+	waitFor(t, "failed attempt", func() bool {
+		return calls.Load() > 0 && len(s.Pending(0)) == 0
+	})
+	if err := application.Lifecycle().Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	healthy.Store(true)
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	restarted := app.New(cfg, app.WithStore(reopened))
+	if err := restarted.Lifecycle().Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer restarted.Lifecycle().Stop(context.Background())
+
+	waitFor(t, "delivery after restart", func() bool {
+		deliveries := listDeliveries(t, restarted, sub.ID, "?status=succeeded")
+		return len(deliveries) == 1
+	})
+	if body, _ := delivered.Load().(string); !strings.Contains(body, "Durable") {
+		t.Errorf("Expected the item event to be delivered, got %q", body)
+	}
+}
+
+func TestWebhookManagement(t *testing.T) {
+	application := setupWebhookApp(t)
+
+	if rec := doAs(t, application, "someone", http.MethodGet, "/api/v1/webhooks", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	if rec := doAs(t, application, "ops", http.MethodPost, "/api/v1/webhooks", `{"url": "ftp://example.com"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid URL, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec := doAs(t, application, "ops", http.MethodPost, "/api/v1/webhooks", `{"url": "https://example.com/hook", "secret": "s3cret"}`)
+	var sub webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&sub)
+
+	rec = doAs(t, application, "ops", http.MethodPut, "/api/v1/webhooks/"+sub.ID, `{"events": ["item.deleted"], "active": false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var updated webhook.Subscription
+	json.NewDecoder(rec.Body).Decode(&updated)
+	if updated.Active || len(updated.Events) != 1 || updated.URL != "https://example.com/hook" {
+		t.Errorf("Unexpected updated subscription: %+v", updated)
+	}
+
+	if rec := doAs(t, application, "ops", http.MethodDelete, "/api/v1/webhooks/"+sub.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := doAs(t, application, "ops", http.MethodGet, "/api/v1/webhooks/"+sub.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestWebhookSignatureExpiry(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	header := webhook.Sign("secret", time.Now().Add(-10*time.Minute), body)
+
+	if err := webhook.Verify("secret", header, body, 0); err != nil {
+		t.Errorf("Expected signature to verify without tolerance: %v", err)
+	}
+	if err := webhook.Verify("secret", header, body, 5*time.Minute); err != webhook.ErrSignatureExpired {
+		t.Errorf("Expected ErrSignatureExpired, got %v", err)
+	}
+	if err := webhook.Verify("secret", header, []byte(`{"id":2}`), 0); err != webhook.ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature for a modified body, got %v", err)
+	}
+}